@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/iimeta/fastapi/internal/model"
+)
+
+// Create接口请求参数
+type CreateReq struct {
+	g.Meta `path:"/batches" tags:"batch" method:"post" summary:"创建批处理接口"`
+	model.BatchCreateReq
+}
+
+// Create接口响应参数
+type CreateRes struct {
+	g.Meta `mime:"application/json" example:"json"`
+}
+
+// Retrieve接口请求参数
+type RetrieveReq struct {
+	g.Meta  `path:"/batches/{batch_id}" tags:"batch" method:"get" summary:"批处理详情接口"`
+	BatchId string `json:"batch_id" in:"path"`
+}
+
+// Retrieve接口响应参数
+type RetrieveRes struct {
+	g.Meta `mime:"application/json" example:"json"`
+}
+
+// Cancel接口请求参数
+type CancelReq struct {
+	g.Meta  `path:"/batches/{batch_id}/cancel" tags:"batch" method:"post" summary:"取消批处理接口"`
+	BatchId string `json:"batch_id" in:"path"`
+}
+
+// Cancel接口响应参数
+type CancelRes struct {
+	g.Meta `mime:"application/json" example:"json"`
+}
+
+// List接口请求参数
+type ListReq struct {
+	g.Meta `path:"/batches" tags:"batch" method:"get" summary:"批处理列表接口"`
+	After  string `json:"after"`
+	Limit  int    `json:"limit" d:"20"`
+}
+
+// List接口响应参数
+type ListRes struct {
+	g.Meta `mime:"application/json" example:"json"`
+}
@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+// Upload接口请求参数
+type UploadReq struct {
+	g.Meta  `path:"/files" tags:"file" method:"post" summary:"上传文件接口"`
+	File    *ghttp.UploadFile `json:"file" type:"file" v:"required"`
+	Purpose string            `json:"purpose" v:"required"`
+}
+
+// Upload接口响应参数
+type UploadRes struct {
+	g.Meta `mime:"application/json" example:"json"`
+}
+
+// List接口请求参数
+type ListReq struct {
+	g.Meta  `path:"/files" tags:"file" method:"get" summary:"文件列表接口"`
+	Purpose string `json:"purpose"`
+}
+
+// List接口响应参数
+type ListRes struct {
+	g.Meta `mime:"application/json" example:"json"`
+}
+
+// Retrieve接口请求参数
+type RetrieveReq struct {
+	g.Meta `path:"/files/{file_id}" tags:"file" method:"get" summary:"文件详情接口"`
+	FileId string `json:"file_id" in:"path"`
+}
+
+// Retrieve接口响应参数
+type RetrieveRes struct {
+	g.Meta `mime:"application/json" example:"json"`
+}
+
+// Delete接口请求参数
+type DeleteReq struct {
+	g.Meta `path:"/files/{file_id}" tags:"file" method:"delete" summary:"删除文件接口"`
+	FileId string `json:"file_id" in:"path"`
+}
+
+// Delete接口响应参数
+type DeleteRes struct {
+	g.Meta `mime:"application/json" example:"json"`
+}
+
+// Content接口请求参数
+type ContentReq struct {
+	g.Meta `path:"/files/{file_id}/content" tags:"file" method:"get" summary:"文件内容接口"`
+	FileId string `json:"file_id" in:"path"`
+}
+
+// Content接口响应参数
+type ContentRes struct {
+	g.Meta `mime:"application/octet-stream" example:"string"`
+}
@@ -0,0 +1,30 @@
+package v1
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/iimeta/fastapi/internal/model"
+)
+
+// GenerateContent接口请求参数
+type GenerateContentReq struct {
+	g.Meta `path:"/models/{model}:generateContent" tags:"gemini" method:"post" summary:"GenerateContent接口"`
+	Model  string `json:"model" in:"path"`
+	model.GeminiGenerateContentReq
+}
+
+// GenerateContent接口响应参数
+type GenerateContentRes struct {
+	g.Meta `mime:"application/json" example:"json"`
+}
+
+// StreamGenerateContent接口请求参数
+type StreamGenerateContentReq struct {
+	g.Meta `path:"/models/{model}:streamGenerateContent" tags:"gemini" method:"post" summary:"StreamGenerateContent接口"`
+	Model  string `json:"model" in:"path"`
+	model.GeminiGenerateContentReq
+}
+
+// StreamGenerateContent接口响应参数
+type StreamGenerateContentRes struct {
+	g.Meta `mime:"text/event-stream" example:"string"`
+}
@@ -15,4 +15,5 @@ type IMidjourneyV1 interface {
 	ModelSubmit(ctx context.Context, req *v1.ModelSubmitReq) (res *v1.ModelSubmitRes, err error)
 	Task(ctx context.Context, req *v1.TaskReq) (res *v1.TaskRes, err error)
 	ModelTask(ctx context.Context, req *v1.ModelTaskReq) (res *v1.ModelTaskRes, err error)
+	Fetch(ctx context.Context, req *v1.FetchReq) (res *v1.FetchRes, err error)
 }
@@ -35,3 +35,12 @@ type ModelTaskReq struct {
 type ModelTaskRes struct {
 	g.Meta `mime:"application/json" example:"json"`
 }
+
+type FetchReq struct {
+	g.Meta `path:"/task/{taskId}/fetch" tags:"midjourney" method:"get" summary:"midjourney task fetch"`
+	TaskId string `json:"taskId" in:"path"`
+}
+
+type FetchRes struct {
+	g.Meta `mime:"application/json" example:"json"`
+}
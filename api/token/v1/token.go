@@ -0,0 +1,29 @@
+package v1
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/model"
+)
+
+// Tokenize接口请求参数
+type TokenizeReq struct {
+	g.Meta `path:"/tokenize" tags:"token" method:"post" summary:"计算令牌数接口"`
+	sdkm.ChatCompletionRequest
+}
+
+// Tokenize接口响应参数
+type TokenizeRes struct {
+	g.Meta `mime:"application/json" example:"json"`
+}
+
+// CountTokens接口请求参数
+type CountTokensReq struct {
+	g.Meta `path:"/messages/count_tokens" tags:"token" method:"post" summary:"Anthropic格式计算令牌数接口"`
+	model.CountTokensReq
+}
+
+// CountTokens接口响应参数
+type CountTokensRes struct {
+	g.Meta `mime:"application/json" example:"json"`
+}
@@ -10,16 +10,23 @@ import (
 	"github.com/iimeta/fastapi/internal/config"
 	"github.com/iimeta/fastapi/internal/consts"
 	"github.com/iimeta/fastapi/internal/controller/audio"
+	"github.com/iimeta/fastapi/internal/controller/batch"
 	"github.com/iimeta/fastapi/internal/controller/chat"
 	"github.com/iimeta/fastapi/internal/controller/dashboard"
 	"github.com/iimeta/fastapi/internal/controller/embedding"
+	"github.com/iimeta/fastapi/internal/controller/file"
+	"github.com/iimeta/fastapi/internal/controller/gemini"
 	"github.com/iimeta/fastapi/internal/controller/health"
 	"github.com/iimeta/fastapi/internal/controller/image"
 	"github.com/iimeta/fastapi/internal/controller/midjourney"
+	"github.com/iimeta/fastapi/internal/controller/rerank"
+	"github.com/iimeta/fastapi/internal/controller/response"
+	"github.com/iimeta/fastapi/internal/controller/token"
 	"github.com/iimeta/fastapi/internal/errors"
 	"github.com/iimeta/fastapi/internal/model"
 	"github.com/iimeta/fastapi/internal/service"
 	"github.com/iimeta/fastapi/utility/logger"
+	"github.com/iimeta/fastapi/utility/util"
 	"net/http"
 	"runtime"
 	"strings"
@@ -66,6 +73,29 @@ var (
 				}
 			})
 
+			// Midjourney任务状态回调
+			s.BindHandler("POST:/mj/notify", func(r *ghttp.Request) {
+				if err := service.Midjourney().Notify(r.GetCtx(), r); err != nil {
+					err := errors.Error(r.GetCtx(), err)
+					r.Response.Header().Set("Content-Type", "application/json")
+					r.Response.WriteStatus(err.Status(), gjson.MustEncodeString(err))
+					r.Exit()
+				}
+			})
+
+			// 对象存储签名地址访问
+			s.BindHandler("GET:/storage/{objectId}", func(r *ghttp.Request) {
+				contentType, data, err := service.Storage().Download(r.GetCtx(), r.Get("objectId").String(), r.GetQuery("expires").Int64(), r.GetQuery("signature").String())
+				if err != nil {
+					err := errors.Error(r.GetCtx(), err)
+					r.Response.Header().Set("Content-Type", "application/json")
+					r.Response.WriteStatus(err.Status(), gjson.MustEncodeString(err))
+					r.Exit()
+				}
+				r.Response.Header().Set("Content-Type", contentType)
+				r.Response.Write(data)
+			})
+
 			s.Group("/v1", func(v1 *ghttp.RouterGroup) {
 
 				v1.Middleware(middlewareHandlerResponse)
@@ -75,6 +105,11 @@ var (
 					g.Bind(
 						dashboard.NewV1(),
 						embedding.NewV1(),
+						file.NewV1(),
+						batch.NewV1(),
+						response.NewV1(),
+						rerank.NewV1(),
+						token.NewV1(),
 					)
 				})
 
@@ -103,6 +138,14 @@ var (
 				})
 			})
 
+			s.Group("/v1beta", func(v1beta *ghttp.RouterGroup) {
+				v1beta.Middleware(middlewareHandlerResponse)
+				v1beta.Middleware(middleware)
+				v1beta.Bind(
+					gemini.NewV1(),
+				)
+			})
+
 			s.Group("/mj**", func(v1 *ghttp.RouterGroup) {
 				v1.Middleware(middlewareHandlerResponse)
 				v1.Middleware(middleware)
@@ -134,6 +177,21 @@ func middleware(r *ghttp.Request) {
 		secretKey = r.GetHeader(config.Cfg.Midjourney.MidjourneyProxy.ApiSecretHeader)
 	}
 
+	// Gemini原生格式密钥
+	if secretKey == "" {
+		secretKey = r.GetHeader("x-goog-api-key")
+	}
+
+	// 仅Gemini原生接口支持URL参数传递密钥, 避免密钥出现在其它接口的访问日志中
+	if secretKey == "" && gstr.HasPrefix(r.URL.Path, "/v1beta/") {
+		secretKey = r.GetQuery("key").String()
+	}
+
+	// Anthropic格式密钥
+	if secretKey == "" {
+		secretKey = r.GetHeader("x-api-key")
+	}
+
 	if secretKey == "" {
 		err := errors.Error(r.GetCtx(), errors.ERR_NOT_API_KEY)
 		r.Response.Header().Set("Content-Type", "application/json")
@@ -216,6 +274,13 @@ func middlewareHandlerResponse(r *ghttp.Request) {
 
 	if err != nil {
 		err := errors.Error(r.GetCtx(), err)
+		// 流式响应已输出过数据或心跳, 响应头已发送, 以SSE事件返回错误
+		if gstr.HasPrefix(r.Response.Header().Get("Content-Type"), "text/event-stream") {
+			if err := util.SSEServerEvent(r.GetCtx(), "error", gjson.MustEncodeString(err)); err != nil {
+				logger.Error(r.GetCtx(), err)
+			}
+			return
+		}
 		r.Response.Header().Set("Content-Type", "application/json")
 		r.Response.WriteStatus(err.Status(), gjson.MustEncodeString(err))
 	} else {
@@ -41,16 +41,20 @@ func init() {
 
 // 配置信息
 type Config struct {
-	Core             Core       `json:"core"`
-	ApiServerAddress string     `json:"api_server_address"`
-	Http             Http       `json:"http"`
-	Local            Local      `json:"local"`
-	Api              Api        `json:"api"`
-	Midjourney       Midjourney `json:"midjourney"`
-	Gcp              Gcp        `json:"gcp"`
-	RecordLogs       []string   `json:"record_logs"`
-	Error            Error      `json:"error"`
-	Debug            bool       `json:"debug"`
+	Core             Core          `json:"core"`
+	ApiServerAddress string        `json:"api_server_address"`
+	Http             Http          `json:"http"`
+	Local            Local         `json:"local"`
+	Api              Api           `json:"api"`
+	Midjourney       Midjourney    `json:"midjourney"`
+	Gcp              Gcp           `json:"gcp"`
+	Batch            Batch         `json:"batch"`
+	Storage          Storage       `json:"storage"`
+	SemanticCache    SemanticCache `json:"semantic_cache"`
+	Tokenizer        Tokenizer     `json:"tokenizer"`
+	RecordLogs       []string      `json:"record_logs"`
+	Error            Error         `json:"error"`
+	Debug            bool          `json:"debug"`
 }
 
 type Core struct {
@@ -65,8 +69,10 @@ type Api struct {
 }
 
 type Http struct {
-	Timeout  time.Duration `json:"timeout"`
-	ProxyUrl string        `json:"proxy_url"`
+	Timeout      time.Duration `json:"timeout"`
+	ProxyUrl     string        `json:"proxy_url"`
+	PingInterval time.Duration `json:"ping_interval"`
+	IdleTimeout  time.Duration `json:"idle_timeout"`
 }
 
 type Local struct {
@@ -75,6 +81,10 @@ type Local struct {
 
 type Midjourney struct {
 	CdnUrl          string          `json:"cdn_url"`
+	NotifyUrl       string          `json:"notify_url"`
+	NotifySecret    string          `json:"notify_secret"`
+	SyncInterval    time.Duration   `json:"sync_interval"`
+	SyncAfter       time.Duration   `json:"sync_after"`
 	MidjourneyProxy MidjourneyProxy `json:"midjourney_proxy"`
 }
 
@@ -89,6 +99,46 @@ type Gcp struct {
 	GetTokenUrl string `json:"get_token_url" d:"https://www.googleapis.com/oauth2/v4/token"`
 }
 
+type Batch struct {
+	Storage     string        `json:"storage"`
+	LocalPath   string        `json:"local_path"`
+	Concurrency int           `json:"concurrency"`
+	Interval    time.Duration `json:"interval"`
+	Ratio       float64       `json:"ratio"`
+	MaxFileSize int64         `json:"max_file_size"`
+}
+
+type Storage struct {
+	Open      bool          `json:"open"`
+	Type      string        `json:"type"`
+	LocalPath string        `json:"local_path"`
+	BaseUrl   string        `json:"base_url"`
+	Secret    string        `json:"secret"`
+	Expires   time.Duration `json:"expires"`
+	Retention int           `json:"retention"`
+	Interval  time.Duration `json:"interval"`
+	MaxSize   int64         `json:"max_size"`
+	S3        S3            `json:"s3"`
+}
+
+type S3 struct {
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	PathStyle bool   `json:"path_style"`
+}
+
+type SemanticCache struct {
+	Store   string `json:"store"`
+	MaxSize int    `json:"max_size"`
+}
+
+type Tokenizer struct {
+	Vocabs map[string]string `json:"vocabs"`
+}
+
 type Error struct {
 	AutoDisabled []string `json:"auto_disabled"`
 }
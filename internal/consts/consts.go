@@ -11,6 +11,14 @@ const (
 	SECRET_KEY             = "sk"
 	APP_IS_LIMIT_QUOTA_KEY = "app_is_limit_quota"
 	KEY_IS_LIMIT_QUOTA_KEY = "key_is_limit_quota"
+	PROTOCOL_KEY           = "protocol"
+	BATCH_ID_KEY           = "batch_id"
+	RESPONSES_STREAM_KEY   = "responses_stream"
+	IS_SET_TEMPERATURE_KEY = "is_set_temperature"
+	SEMANTIC_VECTOR_KEY    = "semantic_vector"
+
+	PROTOCOL_GEMINI    = "gemini"
+	PROTOCOL_RESPONSES = "responses"
 
 	CORP_OPENAI     = "OpenAI"
 	CORP_AZURE      = "Azure"
@@ -21,7 +29,12 @@ const (
 	CORP_GOOGLE     = "Google"
 	CORP_DEEPSEEK   = "DeepSeek"
 	CORP_MIDJOURNEY = "Midjourney"
+	CORP_ANTHROPIC  = "Anthropic"
 	CORP_GCP_CLAUDE = "GCPClaude"
+	CORP_AWS_CLAUDE = "AWSClaude"
+	CORP_360AI      = "360AI"
+	CORP_JINA       = "Jina"
+	CORP_COHERE     = "Cohere"
 
 	ROLE_SYSTEM    = "system"
 	ROLE_USER      = "user"
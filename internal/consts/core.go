@@ -23,6 +23,20 @@ const (
 
 	ACCESS_TOKEN_KEY = "api:baidu:access_token:%s"
 	GCP_TOKEN_KEY    = "api:gcp:token:%s"
+
+	BATCH_LOCK_KEY   = "api:batch:lock:%s"
+	BATCH_CANCEL_KEY = "api:batch:cancel:%s"
+
+	MIDJOURNEY_REFUND_KEY = "api:midjourney:refund:%s"
+
+	EMBEDDING_CACHE_KEY       = "api:embedding:cache:%s:%d:%s"
+	EMBEDDING_CACHE_INDEX_KEY = "api:embedding:cache:index:%s"
+
+	CHAT_CACHE_KEY        = "api:chat:cache:%s"
+	SEMANTIC_CACHE_KEY    = "api:semantic:cache:%s"
+	SEMANTIC_VERSION_KEY  = "api:semantic:version:%s"
+	SEMANTIC_RESPONSE_KEY = "api:semantic:response:%s:%s"
+	COMPACTION_KEY        = "api:chat:compaction:%d:%s"
 )
 
 const (
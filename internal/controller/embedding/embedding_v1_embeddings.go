@@ -22,7 +22,11 @@ func (c *ControllerV1) Embeddings(ctx context.Context, req *v1.EmbeddingsReq) (r
 		return nil, err
 	}
 
-	g.RequestFromCtx(ctx).Response.WriteJson(response)
+	if req.EncodingFormat == "base64" {
+		g.RequestFromCtx(ctx).Response.WriteJson(service.Embedding().ConvBase64(ctx, response))
+	} else {
+		g.RequestFromCtx(ctx).Response.WriteJson(response)
+	}
 
 	return
 }
@@ -0,0 +1,28 @@
+package gemini
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/logger"
+
+	"github.com/iimeta/fastapi/api/gemini/v1"
+)
+
+func (c *ControllerV1) GenerateContent(ctx context.Context, req *v1.GenerateContentReq) (res *v1.GenerateContentRes, err error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "Controller GenerateContent time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	response, err := service.Gemini().GenerateContent(ctx, req.Model, req.GeminiGenerateContentReq)
+	if err != nil {
+		return nil, err
+	}
+
+	g.RequestFromCtx(ctx).Response.WriteJson(response)
+
+	return
+}
@@ -0,0 +1,27 @@
+package gemini
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/logger"
+
+	"github.com/iimeta/fastapi/api/gemini/v1"
+)
+
+func (c *ControllerV1) StreamGenerateContent(ctx context.Context, req *v1.StreamGenerateContentReq) (res *v1.StreamGenerateContentRes, err error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "Controller StreamGenerateContent time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	if err = service.Gemini().StreamGenerateContent(ctx, req.Model, req.GeminiGenerateContentReq); err != nil {
+		return nil, err
+	}
+
+	g.RequestFromCtx(ctx).SetCtxVar("stream", true)
+
+	return
+}
@@ -0,0 +1,28 @@
+package rerank
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/logger"
+
+	"github.com/iimeta/fastapi/api/rerank/v1"
+)
+
+func (c *ControllerV1) Rerank(ctx context.Context, req *v1.RerankReq) (res *v1.RerankRes, err error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "Controller Rerank time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	response, err := service.Rerank().Rerank(ctx, req.RerankReq, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	g.RequestFromCtx(ctx).Response.WriteJson(response)
+
+	return
+}
@@ -0,0 +1,15 @@
+// =================================================================================
+// This is auto-generated by GoFrame CLI tool only once. Fill this file as you wish.
+// =================================================================================
+
+package response
+
+import (
+	"github.com/iimeta/fastapi/api/response"
+)
+
+type ControllerV1 struct{}
+
+func NewV1() response.IResponseV1 {
+	return &ControllerV1{}
+}
@@ -0,0 +1,34 @@
+package response
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/logger"
+
+	"github.com/iimeta/fastapi/api/response/v1"
+)
+
+func (c *ControllerV1) Create(ctx context.Context, req *v1.CreateReq) (res *v1.CreateRes, err error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "Controller Create time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	if req.Stream {
+		if err = service.Response().CreateStream(ctx, req.ResponsesReq); err != nil {
+			return nil, err
+		}
+		g.RequestFromCtx(ctx).SetCtxVar("stream", req.Stream)
+	} else {
+		response, err := service.Response().Create(ctx, req.ResponsesReq)
+		if err != nil {
+			return nil, err
+		}
+		g.RequestFromCtx(ctx).Response.WriteJson(response)
+	}
+
+	return
+}
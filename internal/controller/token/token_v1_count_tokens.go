@@ -0,0 +1,28 @@
+package token
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/logger"
+
+	"github.com/iimeta/fastapi/api/token/v1"
+)
+
+func (c *ControllerV1) CountTokens(ctx context.Context, req *v1.CountTokensReq) (res *v1.CountTokensRes, err error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "Controller CountTokens time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	response, err := service.Token().CountTokens(ctx, req.CountTokensReq)
+	if err != nil {
+		return nil, err
+	}
+
+	g.RequestFromCtx(ctx).Response.WriteJson(response)
+
+	return
+}
@@ -0,0 +1,28 @@
+package token
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/logger"
+
+	"github.com/iimeta/fastapi/api/token/v1"
+)
+
+func (c *ControllerV1) Tokenize(ctx context.Context, req *v1.TokenizeReq) (res *v1.TokenizeRes, err error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "Controller Tokenize time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	response, err := service.Token().Tokenize(ctx, req.ChatCompletionRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	g.RequestFromCtx(ctx).Response.WriteJson(response)
+
+	return
+}
@@ -226,4 +226,13 @@ func init() {
 	}, nil); err != nil {
 		panic(err)
 	}
+
+	// 启动批处理任务
+	service.Batch().Start(ctx)
+
+	// 启动过期对象清理任务
+	service.Storage().Start(ctx)
+
+	// 启动Midjourney未结束任务同步
+	service.Midjourney().Start(ctx)
 }
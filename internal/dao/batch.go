@@ -0,0 +1,24 @@
+package dao
+
+import (
+	"github.com/iimeta/fastapi/internal/model/do"
+	"github.com/iimeta/fastapi/internal/model/entity"
+	"github.com/iimeta/fastapi/utility/db"
+)
+
+var Batch = NewBatchDao()
+
+type BatchDao struct {
+	*MongoDB[entity.Batch]
+}
+
+func NewBatchDao(database ...string) *BatchDao {
+
+	if len(database) == 0 {
+		database = append(database, db.DefaultDatabase)
+	}
+
+	return &BatchDao{
+		MongoDB: NewMongoDB[entity.Batch](database[0], do.BATCH_COLLECTION),
+	}
+}
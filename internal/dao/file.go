@@ -0,0 +1,24 @@
+package dao
+
+import (
+	"github.com/iimeta/fastapi/internal/model/do"
+	"github.com/iimeta/fastapi/internal/model/entity"
+	"github.com/iimeta/fastapi/utility/db"
+)
+
+var File = NewFileDao()
+
+type FileDao struct {
+	*MongoDB[entity.File]
+}
+
+func NewFileDao(database ...string) *FileDao {
+
+	if len(database) == 0 {
+		database = append(database, db.DefaultDatabase)
+	}
+
+	return &FileDao{
+		MongoDB: NewMongoDB[entity.File](database[0], do.FILE_COLLECTION),
+	}
+}
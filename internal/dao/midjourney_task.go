@@ -0,0 +1,24 @@
+package dao
+
+import (
+	"github.com/iimeta/fastapi/internal/model/do"
+	"github.com/iimeta/fastapi/internal/model/entity"
+	"github.com/iimeta/fastapi/utility/db"
+)
+
+var MidjourneyTask = NewMidjourneyTaskDao()
+
+type MidjourneyTaskDao struct {
+	*MongoDB[entity.MidjourneyTask]
+}
+
+func NewMidjourneyTaskDao(database ...string) *MidjourneyTaskDao {
+
+	if len(database) == 0 {
+		database = append(database, db.DefaultDatabase)
+	}
+
+	return &MidjourneyTaskDao{
+		MongoDB: NewMongoDB[entity.MidjourneyTask](database[0], do.MIDJOURNEY_TASK_COLLECTION),
+	}
+}
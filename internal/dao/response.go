@@ -0,0 +1,24 @@
+package dao
+
+import (
+	"github.com/iimeta/fastapi/internal/model/do"
+	"github.com/iimeta/fastapi/internal/model/entity"
+	"github.com/iimeta/fastapi/utility/db"
+)
+
+var Response = NewResponseDao()
+
+type ResponseDao struct {
+	*MongoDB[entity.Response]
+}
+
+func NewResponseDao(database ...string) *ResponseDao {
+
+	if len(database) == 0 {
+		database = append(database, db.DefaultDatabase)
+	}
+
+	return &ResponseDao{
+		MongoDB: NewMongoDB[entity.Response](database[0], do.RESPONSE_COLLECTION),
+	}
+}
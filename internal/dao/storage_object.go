@@ -0,0 +1,24 @@
+package dao
+
+import (
+	"github.com/iimeta/fastapi/internal/model/do"
+	"github.com/iimeta/fastapi/internal/model/entity"
+	"github.com/iimeta/fastapi/utility/db"
+)
+
+var StorageObject = NewStorageObjectDao()
+
+type StorageObjectDao struct {
+	*MongoDB[entity.StorageObject]
+}
+
+func NewStorageObjectDao(database ...string) *StorageObjectDao {
+
+	if len(database) == 0 {
+		database = append(database, db.DefaultDatabase)
+	}
+
+	return &StorageObjectDao{
+		MongoDB: NewMongoDB[entity.StorageObject](database[0], do.STORAGE_OBJECT_COLLECTION),
+	}
+}
@@ -45,6 +45,12 @@ var (
 	ERR_PATH_NOT_FOUND               = NewError(404, "path_not_found", "The path does not exist or you do not have access to it.", "fastapi_request_error")
 	ERR_MODEL_DISABLED               = NewError(401, "model_disabled", "Model has been disabled.", "fastapi_request_error")
 	ERR_INSUFFICIENT_QUOTA           = NewError(429, "insufficient_quota", "You exceeded your current quota.", "insufficient_quota")
+	ERR_FILE_NOT_FOUND               = NewError(404, "file_not_found", "The file does not exist or you do not have access to it.", "invalid_request_error")
+	ERR_BATCH_NOT_FOUND              = NewError(404, "batch_not_found", "The batch does not exist or you do not have access to it.", "invalid_request_error")
+	ERR_BATCH_CANNOT_CANCEL          = NewError(400, "batch_cannot_cancel", "The batch cannot be cancelled in its current status.", "invalid_request_error")
+	ERR_RESPONSE_NOT_FOUND           = NewError(404, "response_not_found", "The response does not exist or you do not have access to it.", "invalid_request_error")
+	ERR_TASK_NOT_FOUND               = NewError(404, "task_not_found", "The task does not exist or you do not have access to it.", "fastapi_request_error")
+	ERR_STREAM_IDLE_TIMEOUT          = NewError(504, "stream_idle_timeout", "No data was received from upstream within the idle timeout.", "fastapi_error")
 )
 
 func New(text string) error {
@@ -52,19 +52,29 @@ func (s *sApp) GetApp(ctx context.Context, appId int) (*model.App, error) {
 	}
 
 	return &model.App{
-		Id:             app.Id,
-		AppId:          app.AppId,
-		Name:           app.Name,
-		Models:         app.Models,
-		IsLimitQuota:   app.IsLimitQuota,
-		Quota:          app.Quota,
-		UsedQuota:      app.UsedQuota,
-		QuotaExpiresAt: app.QuotaExpiresAt,
-		IpWhitelist:    app.IpWhitelist,
-		IpBlacklist:    app.IpBlacklist,
-		Remark:         app.Remark,
-		Status:         app.Status,
-		UserId:         app.UserId,
+		Id:                    app.Id,
+		AppId:                 app.AppId,
+		Name:                  app.Name,
+		Models:                app.Models,
+		IsLimitQuota:          app.IsLimitQuota,
+		Quota:                 app.Quota,
+		UsedQuota:             app.UsedQuota,
+		QuotaExpiresAt:        app.QuotaExpiresAt,
+		IpWhitelist:           app.IpWhitelist,
+		IpBlacklist:           app.IpBlacklist,
+		NotifyHook:            app.NotifyHook,
+		Retention:             app.Retention,
+		IsEnableCache:         app.IsEnableCache,
+		CacheConfig:           app.CacheConfig,
+		IsEnableSemanticCache: app.IsEnableSemanticCache,
+		SemanticCacheConfig:   app.SemanticCacheConfig,
+		IsEnableContext:       app.IsEnableContext,
+		ContextConfig:         app.ContextConfig,
+		IsEnableCompaction:    app.IsEnableCompaction,
+		CompactionConfig:      app.CompactionConfig,
+		Remark:                app.Remark,
+		Status:                app.Status,
+		UserId:                app.UserId,
 	}, nil
 }
 
@@ -87,19 +97,29 @@ func (s *sApp) List(ctx context.Context) ([]*model.App, error) {
 	items := make([]*model.App, 0)
 	for _, result := range results {
 		items = append(items, &model.App{
-			Id:             result.Id,
-			AppId:          result.AppId,
-			Name:           result.Name,
-			Models:         result.Models,
-			IsLimitQuota:   result.IsLimitQuota,
-			Quota:          result.Quota,
-			UsedQuota:      result.UsedQuota,
-			QuotaExpiresAt: result.QuotaExpiresAt,
-			IpWhitelist:    result.IpWhitelist,
-			IpBlacklist:    result.IpBlacklist,
-			Remark:         result.Remark,
-			Status:         result.Status,
-			UserId:         result.UserId,
+			Id:                    result.Id,
+			AppId:                 result.AppId,
+			Name:                  result.Name,
+			Models:                result.Models,
+			IsLimitQuota:          result.IsLimitQuota,
+			Quota:                 result.Quota,
+			UsedQuota:             result.UsedQuota,
+			QuotaExpiresAt:        result.QuotaExpiresAt,
+			IpWhitelist:           result.IpWhitelist,
+			IpBlacklist:           result.IpBlacklist,
+			NotifyHook:            result.NotifyHook,
+			Retention:             result.Retention,
+			IsEnableCache:         result.IsEnableCache,
+			CacheConfig:           result.CacheConfig,
+			IsEnableSemanticCache: result.IsEnableSemanticCache,
+			SemanticCacheConfig:   result.SemanticCacheConfig,
+			IsEnableContext:       result.IsEnableContext,
+			ContextConfig:         result.ContextConfig,
+			IsEnableCompaction:    result.IsEnableCompaction,
+			CompactionConfig:      result.CompactionConfig,
+			Remark:                result.Remark,
+			Status:                result.Status,
+			UserId:                result.UserId,
 		})
 	}
 
@@ -234,18 +254,28 @@ func (s *sApp) UpdateCacheApp(ctx context.Context, app *entity.App) {
 	}()
 
 	if err := s.SaveCacheApp(ctx, &model.App{
-		Id:             app.Id,
-		AppId:          app.AppId,
-		Name:           app.Name,
-		Models:         app.Models,
-		IsLimitQuota:   app.IsLimitQuota,
-		Quota:          app.Quota,
-		UsedQuota:      app.UsedQuota,
-		QuotaExpiresAt: app.QuotaExpiresAt,
-		IpWhitelist:    app.IpWhitelist,
-		IpBlacklist:    app.IpBlacklist,
-		Status:         app.Status,
-		UserId:         app.UserId,
+		Id:                    app.Id,
+		AppId:                 app.AppId,
+		Name:                  app.Name,
+		Models:                app.Models,
+		IsLimitQuota:          app.IsLimitQuota,
+		Quota:                 app.Quota,
+		UsedQuota:             app.UsedQuota,
+		QuotaExpiresAt:        app.QuotaExpiresAt,
+		IpWhitelist:           app.IpWhitelist,
+		IpBlacklist:           app.IpBlacklist,
+		NotifyHook:            app.NotifyHook,
+		Retention:             app.Retention,
+		IsEnableCache:         app.IsEnableCache,
+		CacheConfig:           app.CacheConfig,
+		IsEnableSemanticCache: app.IsEnableSemanticCache,
+		SemanticCacheConfig:   app.SemanticCacheConfig,
+		IsEnableContext:       app.IsEnableContext,
+		ContextConfig:         app.ContextConfig,
+		IsEnableCompaction:    app.IsEnableCompaction,
+		CompactionConfig:      app.CompactionConfig,
+		Status:                app.Status,
+		UserId:                app.UserId,
 	}); err != nil {
 		logger.Error(ctx, err)
 	}
@@ -0,0 +1,716 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/gogf/gf/v2/os/grpool"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/util/gconv"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/config"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/dao"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/internal/logic/common"
+	"github.com/iimeta/fastapi/internal/model"
+	mcommon "github.com/iimeta/fastapi/internal/model/common"
+	"github.com/iimeta/fastapi/internal/model/do"
+	"github.com/iimeta/fastapi/internal/model/entity"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/db"
+	"github.com/iimeta/fastapi/utility/logger"
+	"github.com/iimeta/fastapi/utility/redis"
+	"go.mongodb.org/mongo-driver/bson"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+type sBatch struct{}
+
+func init() {
+	service.RegisterBatch(New())
+}
+
+func New() service.IBatch {
+	return &sBatch{}
+}
+
+const (
+	STATUS_VALIDATING  = "validating"
+	STATUS_FAILED      = "failed"
+	STATUS_IN_PROGRESS = "in_progress"
+	STATUS_FINALIZING  = "finalizing"
+	STATUS_COMPLETED   = "completed"
+	STATUS_EXPIRED     = "expired"
+	STATUS_CANCELLING  = "cancelling"
+	STATUS_CANCELLED   = "cancelled"
+
+	LOCK_EXPIRE = 60 // 任务锁过期时间, 单位秒
+)
+
+// 支持批处理的接口
+var endpoints = []string{"/v1/chat/completions", "/v1/embeddings"}
+
+// 创建批处理
+func (s *sBatch) Create(ctx context.Context, params model.BatchCreateReq) (*model.Batch, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sBatch Create time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	if !slices.Contains(endpoints, params.Endpoint) {
+		return nil, errors.NewError(400, "invalid_request_error", fmt.Sprintf("Unsupported endpoint: %s", params.Endpoint), "invalid_request_error")
+	}
+
+	if params.CompletionWindow == "" {
+		params.CompletionWindow = "24h"
+	}
+
+	if params.CompletionWindow != "24h" {
+		return nil, errors.NewError(400, "invalid_request_error", "Only 24h completion_window is supported.", "invalid_request_error")
+	}
+
+	file, err := service.File().Retrieve(ctx, params.InputFileId)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	if file.Purpose != "batch" {
+		return nil, errors.NewError(400, "invalid_request_error", "The input file must be uploaded with purpose batch.", "invalid_request_error")
+	}
+
+	id, err := dao.Batch.Insert(ctx, &do.Batch{
+		UserId:           service.Session().GetUserId(ctx),
+		AppId:            service.Session().GetAppId(ctx),
+		Endpoint:         params.Endpoint,
+		InputFileId:      params.InputFileId,
+		CompletionWindow: params.CompletionWindow,
+		Status:           STATUS_VALIDATING,
+		Metadata:         params.Metadata,
+		ExpiresAt:        now + (24 * time.Hour).Milliseconds(),
+		Creator:          service.Session().GetSecretKey(ctx),
+	})
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	return s.Retrieve(ctx, id)
+}
+
+// 批处理详情
+func (s *sBatch) Retrieve(ctx context.Context, batchId string) (*model.Batch, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sBatch Retrieve time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	result, err := dao.Batch.FindById(ctx, batchId)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, errors.ERR_BATCH_NOT_FOUND
+	}
+
+	if result.UserId != service.Session().GetUserId(ctx) || result.AppId != service.Session().GetAppId(ctx) {
+		return nil, errors.ERR_BATCH_NOT_FOUND
+	}
+
+	return toModel(result), nil
+}
+
+// 取消批处理
+func (s *sBatch) Cancel(ctx context.Context, batchId string) (*model.Batch, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sBatch Cancel time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	batch, err := s.Retrieve(ctx, batchId)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	if batch.Status != STATUS_VALIDATING && batch.Status != STATUS_IN_PROGRESS {
+		return nil, errors.ERR_BATCH_CANNOT_CANCEL
+	}
+
+	if err = dao.Batch.UpdateOne(ctx, bson.M{"_id": batchId, "status": batch.Status}, bson.M{
+		"status":        STATUS_CANCELLING,
+		"cancelling_at": now,
+	}); err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	// 通知执行中的任务停止分发
+	if err = redis.SetEX(ctx, fmt.Sprintf(consts.BATCH_CANCEL_KEY, batchId), now, 25*60*60); err != nil {
+		logger.Error(ctx, err)
+	}
+
+	return s.Retrieve(ctx, batchId)
+}
+
+// 批处理列表
+func (s *sBatch) List(ctx context.Context, after string, limit int) (*model.BatchListRes, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sBatch List time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filter := bson.M{
+		"user_id": service.Session().GetUserId(ctx),
+		"app_id":  service.Session().GetAppId(ctx),
+	}
+
+	if after != "" {
+		cursor, err := s.Retrieve(ctx, after)
+		if err != nil {
+			logger.Error(ctx, err)
+			return nil, err
+		}
+		filter["created_at"] = bson.M{"$lt": cursor.CreatedAt * 1000}
+	}
+
+	paging := &db.Paging{
+		Page:     1,
+		PageSize: int64(limit),
+	}
+
+	results, err := dao.Batch.FindByPage(ctx, paging, filter, "-created_at")
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	res := &model.BatchListRes{
+		Object:  "list",
+		Data:    make([]*model.Batch, 0),
+		HasMore: paging.Total > int64(len(results)),
+	}
+
+	for _, result := range results {
+		res.Data = append(res.Data, toModel(result))
+	}
+
+	if len(res.Data) > 0 {
+		res.FirstId = res.Data[0].Id
+		res.LastId = res.Data[len(res.Data)-1].Id
+	}
+
+	return res, nil
+}
+
+// 启动批处理任务
+func (s *sBatch) Start(ctx context.Context) {
+
+	interval := config.Cfg.Batch.Interval * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	if err := grpool.AddWithRecover(ctx, func(ctx context.Context) {
+		for {
+
+			time.Sleep(interval)
+
+			results, err := dao.Batch.Find(ctx, bson.M{"status": bson.M{"$in": []string{STATUS_VALIDATING, STATUS_IN_PROGRESS, STATUS_CANCELLING}}}, "created_at")
+			if err != nil {
+				logger.Error(ctx, err)
+				continue
+			}
+
+			for _, result := range results {
+
+				if !s.lock(ctx, result.Id) {
+					continue
+				}
+
+				// 执行中却能拿到锁, 说明处理该任务的实例已退出
+				if result.Status == STATUS_IN_PROGRESS {
+					s.finish(ctx, result, bson.M{
+						"status":    STATUS_FAILED,
+						"failed_at": gtime.TimestampMilli(),
+						"errors":    []mcommon.BatchError{{Code: "server_error", Message: "The batch was interrupted."}},
+					})
+					s.unlock(ctx, result.Id)
+					continue
+				}
+
+				batchId := result.Id
+				if err = grpool.AddWithRecover(gctx.NeverDone(ctx), func(ctx context.Context) {
+					defer s.unlock(ctx, batchId)
+					if err := s.Process(ctx, batchId); err != nil {
+						logger.Error(ctx, err)
+					}
+				}, nil); err != nil {
+					logger.Error(ctx, err)
+					s.unlock(ctx, batchId)
+				}
+			}
+		}
+	}, nil); err != nil {
+		panic(err)
+	}
+}
+
+// 执行批处理
+func (s *sBatch) Process(ctx context.Context, batchId string) error {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sBatch Process batchId: %s, time: %d", batchId, gtime.TimestampMilli()-now)
+	}()
+
+	batch, err := dao.Batch.FindById(ctx, batchId)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	if batch.Status == STATUS_CANCELLING {
+		return s.finish(ctx, batch, bson.M{
+			"status":       STATUS_CANCELLED,
+			"cancelled_at": gtime.TimestampMilli(),
+		})
+	}
+
+	if batch.Status != STATUS_VALIDATING {
+		return nil
+	}
+
+	lines, batchErrors := s.parse(ctx, batch)
+	if len(batchErrors) > 0 {
+		return s.finish(ctx, batch, bson.M{
+			"status":    STATUS_FAILED,
+			"failed_at": gtime.TimestampMilli(),
+			"errors":    batchErrors,
+		})
+	}
+
+	if err = dao.Batch.UpdateOne(ctx, bson.M{"_id": batch.Id, "status": STATUS_VALIDATING}, bson.M{
+		"status":               STATUS_IN_PROGRESS,
+		"in_progress_at":       gtime.TimestampMilli(),
+		"request_counts.total": len(lines),
+		"updater":              batch.Creator,
+	}); err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	// 定时续期任务锁, 直至任务结束
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(LOCK_EXPIRE / 3 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if _, err := redis.Expire(ctx, fmt.Sprintf(consts.BATCH_LOCK_KEY, batch.Id), LOCK_EXPIRE); err != nil {
+					logger.Error(ctx, err)
+				}
+			}
+		}
+	}()
+
+	var (
+		outputs     = make([]*model.BatchResponseLine, len(lines))
+		concurrency = config.Cfg.Batch.Concurrency
+		wg          sync.WaitGroup
+		status      = STATUS_COMPLETED
+	)
+
+	if concurrency < 1 {
+		concurrency = 10
+	}
+
+	semaphore := make(chan struct{}, concurrency)
+
+dispatch:
+	for i, line := range lines {
+
+		if s.isCancelled(ctx, batch.Id) {
+			status = STATUS_CANCELLED
+			break dispatch
+		}
+
+		if gtime.TimestampMilli() > batch.ExpiresAt {
+			status = STATUS_EXPIRED
+			break dispatch
+		}
+
+		semaphore <- struct{}{}
+		wg.Add(1)
+
+		go func(i int, line *model.BatchRequestLine) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			outputs[i] = s.dispatch(ctx, batch, line)
+		}(i, line)
+	}
+
+	wg.Wait()
+
+	if err = dao.Batch.UpdateById(ctx, batch.Id, bson.M{
+		"status":        STATUS_FINALIZING,
+		"finalizing_at": gtime.TimestampMilli(),
+		"updater":       batch.Creator,
+	}); err != nil {
+		logger.Error(ctx, err)
+	}
+
+	var (
+		output    bytes.Buffer
+		errOutput bytes.Buffer
+		counts    = mcommon.BatchRequestCounts{Total: len(lines)}
+	)
+
+	for _, line := range outputs {
+
+		if line == nil {
+			continue
+		}
+
+		if line.Error == nil && line.Response != nil && line.Response.StatusCode == http.StatusOK {
+			counts.Completed++
+			output.WriteString(gjson.MustEncodeString(line) + "\n")
+		} else {
+			counts.Failed++
+			errOutput.WriteString(gjson.MustEncodeString(line) + "\n")
+		}
+	}
+
+	update := bson.M{
+		"status":         status,
+		"request_counts": counts,
+	}
+
+	if output.Len() > 0 {
+		if file, err := s.createFile(ctx, batch, "batch_output.jsonl", output.Bytes()); err != nil {
+			logger.Error(ctx, err)
+		} else {
+			update["output_file_id"] = file.Id
+		}
+	}
+
+	if errOutput.Len() > 0 {
+		if file, err := s.createFile(ctx, batch, "batch_error.jsonl", errOutput.Bytes()); err != nil {
+			logger.Error(ctx, err)
+		} else {
+			update["error_file_id"] = file.Id
+		}
+	}
+
+	switch status {
+	case STATUS_COMPLETED:
+		update["completed_at"] = gtime.TimestampMilli()
+	case STATUS_CANCELLED:
+		update["cancelled_at"] = gtime.TimestampMilli()
+	case STATUS_EXPIRED:
+		update["expired_at"] = gtime.TimestampMilli()
+	}
+
+	return s.finish(ctx, batch, update)
+}
+
+// 解析输入文件
+func (s *sBatch) parse(ctx context.Context, batch *entity.Batch) ([]*model.BatchRequestLine, []mcommon.BatchError) {
+
+	file, err := service.File().GetFile(ctx, batch.InputFileId)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, []mcommon.BatchError{{Code: "invalid_file", Message: err.Error()}}
+	}
+
+	data, err := service.File().ReadContent(ctx, file)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, []mcommon.BatchError{{Code: "invalid_file", Message: err.Error()}}
+	}
+
+	var (
+		lines       = make([]*model.BatchRequestLine, 0)
+		batchErrors = make([]mcommon.BatchError, 0)
+		customIds   = make(map[string]bool)
+		lineNum     = 0
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+
+		lineNum++
+
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		line := new(model.BatchRequestLine)
+		if err := gjson.Unmarshal(text, line); err != nil {
+			batchErrors = append(batchErrors, mcommon.BatchError{Code: "invalid_json_line", Message: "This line is not parseable as valid JSON.", Line: lineNum})
+			continue
+		}
+
+		if line.CustomId == "" || customIds[line.CustomId] {
+			batchErrors = append(batchErrors, mcommon.BatchError{Code: "duplicate_custom_id", Message: "The custom_id for this request is empty or a duplicate of another request.", Line: lineNum})
+			continue
+		}
+
+		if line.Method != http.MethodPost {
+			batchErrors = append(batchErrors, mcommon.BatchError{Code: "invalid_method", Message: "The method must be POST.", Line: lineNum})
+			continue
+		}
+
+		if line.Url != batch.Endpoint {
+			batchErrors = append(batchErrors, mcommon.BatchError{Code: "mismatched_endpoint", Message: "The url must match the batch endpoint.", Line: lineNum})
+			continue
+		}
+
+		customIds[line.CustomId] = true
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		batchErrors = append(batchErrors, mcommon.BatchError{Code: "invalid_file", Message: err.Error()})
+	}
+
+	if len(lines) == 0 && len(batchErrors) == 0 {
+		batchErrors = append(batchErrors, mcommon.BatchError{Code: "empty_file", Message: "The input file is empty."})
+	}
+
+	return lines, batchErrors
+}
+
+// 在服务内部直接调用对应服务分发单个请求, 以创建者身份计费及记录日志
+func (s *sBatch) dispatch(ctx context.Context, batch *entity.Batch, line *model.BatchRequestLine) *model.BatchResponseLine {
+
+	// 批处理不支持流式
+	delete(line.Body, "stream")
+	delete(line.Body, "stream_options")
+
+	lineCtx, err := s.newLineCtx(batch, line)
+	requestId := gctx.CtxId(lineCtx)
+
+	result := &model.BatchResponseLine{
+		Id:       fmt.Sprintf("batch_req_%s", requestId),
+		CustomId: line.CustomId,
+	}
+
+	var body interface{}
+
+	if err == nil {
+		switch line.Url {
+		case "/v1/chat/completions":
+
+			params := sdkm.ChatCompletionRequest{}
+			if err = gjson.New(line.Body).Scan(&params); err == nil {
+				body, err = service.Chat().Completions(lineCtx, params, nil)
+			}
+
+		case "/v1/embeddings":
+
+			params := sdkm.EmbeddingRequest{}
+			if err = gjson.New(line.Body).Scan(&params); err == nil {
+				var response sdkm.EmbeddingResponse
+				if response, err = service.Embedding().Embeddings(lineCtx, params, nil); err == nil {
+					if params.EncodingFormat == "base64" {
+						body = common.ConvEmbeddingBase64(response)
+					} else {
+						body = response
+					}
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		logger.Error(lineCtx, err)
+		apiErr := errors.Error(lineCtx, err)
+		result.Response = &model.BatchResponseBody{
+			StatusCode: apiErr.Status(),
+			RequestId:  requestId,
+			Body:       apiErr,
+		}
+		return result
+	}
+
+	result.Response = &model.BatchResponseBody{
+		StatusCode: http.StatusOK,
+		RequestId:  requestId,
+		Body:       body,
+	}
+
+	return result
+}
+
+// 构造批处理请求上下文并恢复创建者会话, 不经过HTTP中间件, 不受IP白名单及速率限制影响
+func (s *sBatch) newLineCtx(batch *entity.Batch, line *model.BatchRequestLine) (context.Context, error) {
+
+	r, err := common.NewInternalRequest(context.WithValue(gctx.New(), consts.BATCH_ID_KEY, batch.Id), http.MethodPost, line.Url, bytes.NewReader(gjson.MustEncode(line.Body)))
+	if err != nil {
+		return gctx.New(), err
+	}
+
+	ctx := r.GetCtx()
+
+	if err = service.Session().Save(ctx, batch.Creator); err != nil {
+		return ctx, err
+	}
+
+	user, err := service.User().GetCacheUser(ctx, batch.UserId)
+	if err != nil || user == nil {
+		if user, err = service.User().GetUser(ctx, batch.UserId); err != nil {
+			return ctx, errors.ERR_INVALID_USER
+		}
+	}
+
+	app, err := service.App().GetCacheApp(ctx, batch.AppId)
+	if err != nil || app == nil {
+		if app, err = service.App().GetApp(ctx, batch.AppId); err != nil {
+			return ctx, errors.ERR_INVALID_APP
+		}
+	}
+
+	key, err := service.App().GetCacheAppKey(ctx, batch.Creator)
+	if err != nil || key == nil {
+		if key, err = service.Key().GetKey(ctx, batch.Creator); err != nil {
+			return ctx, errors.ERR_INVALID_API_KEY
+		}
+	}
+
+	switch {
+	case user.Status == 2:
+		return ctx, errors.ERR_USER_DISABLED
+	case app.Status == 2:
+		return ctx, errors.ERR_APP_DISABLED
+	case key.Status == 2:
+		return ctx, errors.ERR_API_KEY_DISABLED
+	}
+
+	service.Session().SaveUser(ctx, user)
+	service.Session().SaveIsLimitQuota(ctx, app.IsLimitQuota, key.IsLimitQuota)
+
+	return r.GetCtx(), nil
+}
+
+// 创建结果文件
+func (s *sBatch) createFile(ctx context.Context, batch *entity.Batch, filename string, data []byte) (*model.File, error) {
+	return service.File().Create(ctx, &model.File{
+		Filename: filename,
+		Purpose:  "batch_output",
+		UserId:   batch.UserId,
+		AppId:    batch.AppId,
+		Creator:  batch.Creator,
+	}, data)
+}
+
+// 更新任务最终状态
+func (s *sBatch) finish(ctx context.Context, batch *entity.Batch, update bson.M) error {
+
+	update["updater"] = batch.Creator
+
+	if err := dao.Batch.UpdateById(ctx, batch.Id, update); err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	if _, err := redis.Del(ctx, fmt.Sprintf(consts.BATCH_CANCEL_KEY, batch.Id)); err != nil {
+		logger.Error(ctx, err)
+	}
+
+	return nil
+}
+
+func (s *sBatch) isCancelled(ctx context.Context, batchId string) bool {
+
+	reply, err := redis.Get(ctx, fmt.Sprintf(consts.BATCH_CANCEL_KEY, batchId))
+	if err != nil {
+		logger.Error(ctx, err)
+		return false
+	}
+
+	return !reply.IsNil()
+}
+
+func (s *sBatch) lock(ctx context.Context, batchId string) bool {
+
+	key := fmt.Sprintf(consts.BATCH_LOCK_KEY, batchId)
+
+	reply, err := redis.Set(ctx, key, gtime.TimestampMilli(), gredis.SetOption{
+		TTLOption: gredis.TTLOption{EX: gconv.PtrInt64(LOCK_EXPIRE)},
+		NX:        true,
+	})
+	if err != nil {
+		logger.Error(ctx, err)
+		return false
+	}
+
+	return !reply.IsNil()
+}
+
+func (s *sBatch) unlock(ctx context.Context, batchId string) {
+	if _, err := redis.Del(ctx, fmt.Sprintf(consts.BATCH_LOCK_KEY, batchId)); err != nil {
+		logger.Error(ctx, err)
+	}
+}
+
+func toModel(result *entity.Batch) *model.Batch {
+
+	batch := &model.Batch{
+		Id:               result.Id,
+		Object:           "batch",
+		Endpoint:         result.Endpoint,
+		InputFileId:      result.InputFileId,
+		CompletionWindow: result.CompletionWindow,
+		Status:           result.Status,
+		OutputFileId:     result.OutputFileId,
+		ErrorFileId:      result.ErrorFileId,
+		CreatedAt:        result.CreatedAt / 1000,
+		InProgressAt:     result.InProgressAt / 1000,
+		ExpiresAt:        result.ExpiresAt / 1000,
+		FinalizingAt:     result.FinalizingAt / 1000,
+		CompletedAt:      result.CompletedAt / 1000,
+		FailedAt:         result.FailedAt / 1000,
+		ExpiredAt:        result.ExpiredAt / 1000,
+		CancellingAt:     result.CancellingAt / 1000,
+		CancelledAt:      result.CancelledAt / 1000,
+		RequestCounts:    result.RequestCounts,
+		Metadata:         result.Metadata,
+		UserId:           result.UserId,
+		AppId:            result.AppId,
+		Creator:          result.Creator,
+	}
+
+	if len(result.Errors) > 0 {
+		batch.Errors = &model.BatchErrors{
+			Object: "list",
+			Data:   result.Errors,
+		}
+	}
+
+	return batch
+}
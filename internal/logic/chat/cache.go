@@ -0,0 +1,261 @@
+package chat
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/util/gconv"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/logic/common"
+	"github.com/iimeta/fastapi/internal/model"
+	mcommon "github.com/iimeta/fastapi/internal/model/common"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/logger"
+	"github.com/iimeta/fastapi/utility/redis"
+	"github.com/iimeta/fastapi/utility/util"
+	"math"
+)
+
+// 缓存默认时长(秒)
+const DEFAULT_CACHE_TTL = 60 * 60
+
+// 缓存命中响应头
+const CACHE_HEADER = "X-Fastapi-Cache"
+
+// 获取响应缓存配置, 应用配置优先于模型配置, 未启用或请求结果不确定时返回空
+func (s *sChat) getCacheConfig(ctx context.Context, realModel *model.Model, params sdkm.ChatCompletionRequest) (string, *mcommon.CacheConfig) {
+
+	if !isDeterministic(ctx, params) {
+		return "", nil
+	}
+
+	var (
+		cacheConfig *mcommon.CacheConfig
+		appId       = service.Session().GetAppId(ctx)
+	)
+
+	if app, err := service.App().GetCacheApp(ctx, appId); err == nil && app.IsEnableCache {
+		cacheConfig = &app.CacheConfig
+	} else if realModel.IsEnableCache {
+		cacheConfig = &realModel.CacheConfig
+	}
+
+	if cacheConfig == nil {
+		return "", nil
+	}
+
+	// 流式与非流式请求共用缓存
+	request := params
+	request.Model = realModel.Model
+	request.Stream = false
+	request.StreamOptions = nil
+	request.User = ""
+
+	hash := sha256.Sum256(gjson.MustEncode(request))
+
+	// 缓存仅在同一应用内共用, 避免不同租户间互相命中
+	return fmt.Sprintf(consts.CHAT_CACHE_KEY, fmt.Sprintf("%d:%s", appId, hex.EncodeToString(hash[:]))), cacheConfig
+}
+
+// 仅缓存temperature为0或指定了seed的请求
+func isDeterministic(ctx context.Context, params sdkm.ChatCompletionRequest) bool {
+
+	if params.Seed != nil {
+		return true
+	}
+
+	if params.Temperature != 0 {
+		return false
+	}
+
+	// temperature未传时上游使用默认值, 结果不确定
+	return common.IsSetTemperature(ctx)
+}
+
+func (s *sChat) getCache(ctx context.Context, cacheKey string) *sdkm.ChatCompletionResponse {
+
+	reply, err := redis.GetStr(ctx, cacheKey)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil
+	}
+
+	if reply == "" {
+		return nil
+	}
+
+	response := new(sdkm.ChatCompletionResponse)
+	if err = gjson.Unmarshal([]byte(reply), response); err != nil {
+		logger.Error(ctx, err)
+		return nil
+	}
+
+	if len(response.Choices) == 0 {
+		return nil
+	}
+
+	setCacheHeader(ctx, "HIT")
+
+	return response
+}
+
+func (s *sChat) setCache(ctx context.Context, cacheKey string, cacheConfig *mcommon.CacheConfig, response *sdkm.ChatCompletionResponse) {
+
+	if len(response.Choices) == 0 {
+		return
+	}
+
+	ttl := int64(cacheConfig.Ttl)
+	if ttl <= 0 {
+		ttl = DEFAULT_CACHE_TTL
+	}
+
+	if err := redis.SetEX(ctx, cacheKey, gjson.MustEncodeString(response), ttl); err != nil {
+		logger.Error(ctx, err)
+	}
+}
+
+// 命中缓存时按计费策略计算额度
+func getCacheQuota(cacheConfig *mcommon.CacheConfig, totalTokens int) int {
+
+	switch cacheConfig.BillingPolicy {
+	case 2:
+		return int(math.Ceil(float64(totalTokens) * cacheConfig.Ratio))
+	case 3:
+		return 0
+	}
+
+	return totalTokens
+}
+
+// 以流式分片回放缓存的响应, 与上游流式输出格式保持一致
+func (s *sChat) replayStream(ctx context.Context, reqModel *model.Model, response *sdkm.ChatCompletionResponse, includeUsage bool) error {
+
+	var (
+		geminiStream    *common.GeminiStream
+		responsesStream *common.ResponsesStream
+	)
+
+	switch g.RequestFromCtx(ctx).GetCtxVar(consts.PROTOCOL_KEY).String() {
+	case consts.PROTOCOL_GEMINI:
+		geminiStream = common.NewGeminiStream()
+	case consts.PROTOCOL_RESPONSES:
+		responsesStream, _ = g.RequestFromCtx(ctx).GetCtxVar(consts.RESPONSES_STREAM_KEY).Val().(*common.ResponsesStream)
+	}
+
+	for _, chunk := range getStreamChunks(reqModel.Model, response) {
+
+		if geminiStream != nil {
+			if res := geminiStream.Conv(chunk); res != nil {
+				if err := util.SSEServer(ctx, gjson.MustEncodeString(res)); err != nil {
+					logger.Error(ctx, err)
+					return err
+				}
+			}
+		} else if responsesStream != nil {
+			for _, event := range responsesStream.Conv(chunk) {
+				if err := util.SSEServerEvent(ctx, event.Type, gjson.MustEncodeString(event)); err != nil {
+					logger.Error(ctx, err)
+					return err
+				}
+			}
+		} else if err := util.SSEServer(ctx, gjson.MustEncodeString(chunk)); err != nil {
+			logger.Error(ctx, err)
+			return err
+		}
+	}
+
+	if geminiStream != nil {
+		if response.Usage != nil {
+			if res := geminiStream.Conv(&sdkm.ChatCompletionResponse{Usage: response.Usage}); res != nil {
+				if err := util.SSEServer(ctx, gjson.MustEncodeString(res)); err != nil {
+					logger.Error(ctx, err)
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if responsesStream != nil {
+		for _, event := range responsesStream.Done(ctx, response.Usage) {
+			if err := util.SSEServerEvent(ctx, event.Type, gjson.MustEncodeString(event)); err != nil {
+				logger.Error(ctx, err)
+				return err
+			}
+		}
+		return nil
+	}
+
+	if includeUsage && response.Usage != nil {
+		if err := util.SSEServer(ctx, gjson.MustEncodeString(sdkm.ChatCompletionResponse{
+			ID:      response.ID,
+			Object:  "chat.completion.chunk",
+			Created: response.Created,
+			Model:   reqModel.Model,
+			Choices: make([]sdkm.ChatCompletionChoice, 0),
+			Usage:   response.Usage,
+		})); err != nil {
+			logger.Error(ctx, err)
+			return err
+		}
+	}
+
+	if err := util.SSEServer(ctx, "[DONE]"); err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+// 将缓存的响应拆分为流式分片
+func getStreamChunks(model string, response *sdkm.ChatCompletionResponse) []*sdkm.ChatCompletionResponse {
+
+	chunks := make([]*sdkm.ChatCompletionResponse, 0, 2*len(response.Choices))
+
+	for _, choice := range response.Choices {
+
+		if choice.Message == nil {
+			continue
+		}
+
+		chunks = append(chunks, &sdkm.ChatCompletionResponse{
+			ID:      response.ID,
+			Object:  "chat.completion.chunk",
+			Created: response.Created,
+			Model:   model,
+			Choices: []sdkm.ChatCompletionChoice{{
+				Index: choice.Index,
+				Delta: &sdkm.ChatCompletionStreamChoiceDelta{
+					Role:      choice.Message.Role,
+					Content:   gconv.String(choice.Message.Content),
+					ToolCalls: choice.Message.ToolCalls,
+				},
+			}},
+		}, &sdkm.ChatCompletionResponse{
+			ID:      response.ID,
+			Object:  "chat.completion.chunk",
+			Created: response.Created,
+			Model:   model,
+			Choices: []sdkm.ChatCompletionChoice{{
+				Index:        choice.Index,
+				Delta:        &sdkm.ChatCompletionStreamChoiceDelta{},
+				FinishReason: choice.FinishReason,
+			}},
+		})
+	}
+
+	return chunks
+}
+
+// 设置缓存命中响应头, 批处理等内部调用没有响应对象
+func setCacheHeader(ctx context.Context, value string) {
+	if r := g.RequestFromCtx(ctx); r != nil && r.Response != nil && r.Response.BufferWriter != nil {
+		r.Response.Header().Set(CACHE_HEADER, value)
+	}
+}
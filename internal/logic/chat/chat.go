@@ -23,7 +23,6 @@ import (
 	"github.com/iimeta/fastapi/internal/service"
 	"github.com/iimeta/fastapi/utility/logger"
 	"github.com/iimeta/fastapi/utility/util"
-	"github.com/iimeta/tiktoken-go"
 	"io"
 	"math"
 	"slices"
@@ -55,10 +54,23 @@ func (s *sChat) Completions(ctx context.Context, params sdkm.ChatCompletionReque
 		}
 	}
 
+	// 结构化输出校验, 由jsonSchemaCompletions按次调用
+	if schema := getJsonSchema(params); schema != nil && getJsonSchemaAttempt(ctx) == nil && fallbackModel == nil && len(retry) == 0 {
+		if reqModel, err := service.Model().GetModelBySecretKey(ctx, params.Model, service.Session().GetSecretKey(ctx)); err == nil && reqModel.IsEnableJsonSchema {
+			return s.jsonSchemaCompletions(ctx, params, reqModel.JsonSchemaConfig, schema)
+		}
+	}
+
+	// 对话压缩, 重试时沿用已压缩的消息
+	if fallbackModel == nil && len(retry) == 0 {
+		params = s.compact(ctx, params)
+	}
+
 	var (
 		client      sdk.Client
 		reqModel    *model.Model
 		realModel   = new(model.Model)
+		request     = params // 实际发送的请求, 上游未返回用量时按其估算
 		k           *model.Key
 		modelAgent  *model.ModelAgent
 		key         string
@@ -71,6 +83,14 @@ func (s *sChat) Completions(ctx context.Context, params sdkm.ChatCompletionReque
 		imageTokens int
 		totalTokens int
 		projectId   string
+		cacheKey    string
+		cacheConfig *mcommon.CacheConfig
+		isCacheHit  bool
+		cacheType   int
+		ruleParams  map[string]interface{}
+		semantic    *semanticQuery
+		similarity  float64
+		savedQuota  int
 	)
 
 	defer func() {
@@ -84,26 +104,17 @@ func (s *sChat) Completions(ctx context.Context, params sdkm.ChatCompletionReque
 			response.Model = reqModel.Model
 			model := reqModel.Model
 
-			if !tiktoken.IsEncodingForModel(model) {
-				model = consts.DEFAULT_MODEL
-			}
-
 			if reqModel.Type == 100 { // 多模态
 				if response.Usage == nil {
 
 					response.Usage = new(sdkm.Usage)
 
-					if content, ok := params.Messages[len(params.Messages)-1].Content.([]interface{}); ok {
-						textTokens, imageTokens = common.GetMultimodalTokens(ctx, model, content, reqModel)
-						response.Usage.PromptTokens = textTokens + imageTokens
-					} else {
-						if response.Usage.PromptTokens == 0 {
-							response.Usage.PromptTokens = common.GetPromptTokens(ctx, model, params.Messages)
-						}
-					}
+					textTokens, imageTokens = common.GetMultimodalTokens(ctx, model, request.Messages, reqModel)
+					textTokens += common.GetToolsTokens(ctx, model, request)
+					response.Usage.PromptTokens = textTokens + imageTokens
 
-					if response.Usage.CompletionTokens == 0 && len(response.Choices) > 0 && response.Choices[0].Message != nil {
-						response.Usage.CompletionTokens = common.GetCompletionTokens(ctx, model, gconv.String(response.Choices[0].Message.Content))
+					if len(response.Choices) > 0 && response.Choices[0].Message != nil {
+						response.Usage.CompletionTokens = common.GetCompletionTokens(ctx, model, common.GetMessageCompletion(response.Choices[0].Message))
 					}
 
 					response.Usage.TotalTokens = response.Usage.PromptTokens + response.Usage.CompletionTokens
@@ -117,10 +128,10 @@ func (s *sChat) Completions(ctx context.Context, params sdkm.ChatCompletionReque
 
 				response.Usage = new(sdkm.Usage)
 
-				response.Usage.PromptTokens = common.GetPromptTokens(ctx, model, params.Messages)
+				response.Usage.PromptTokens = common.GetPromptTokens(ctx, model, request.Messages) + common.GetToolsTokens(ctx, model, request)
 
 				if len(response.Choices) > 0 && response.Choices[0].Message != nil {
-					response.Usage.CompletionTokens = common.GetCompletionTokens(ctx, model, gconv.String(response.Choices[0].Message.Content))
+					response.Usage.CompletionTokens = common.GetCompletionTokens(ctx, model, common.GetMessageCompletion(response.Choices[0].Message))
 				}
 
 				response.Usage.TotalTokens = response.Usage.PromptTokens + response.Usage.CompletionTokens
@@ -137,6 +148,16 @@ func (s *sChat) Completions(ctx context.Context, params sdkm.ChatCompletionReque
 			}
 		}
 
+		// 批处理折扣
+		totalTokens = common.GetBatchQuota(ctx, reqModel, totalTokens)
+
+		// 命中缓存按计费策略计费
+		if isCacheHit {
+			savedQuota = totalTokens
+			totalTokens = getCacheQuota(cacheConfig, totalTokens)
+			savedQuota -= totalTokens
+		}
+
 		if retryInfo == nil && (err == nil || common.IsAborted(err)) {
 			if err := grpool.Add(gctx.NeverDone(ctx), func(ctx context.Context) {
 				if err := service.Common().RecordUsage(ctx, totalTokens, k.Key); err != nil {
@@ -159,6 +180,18 @@ func (s *sChat) Completions(ctx context.Context, params sdkm.ChatCompletionReque
 				TotalTime:    response.TotalTime,
 				InternalTime: internalTime,
 				EnterTime:    enterTime,
+				IsCacheHit:   isCacheHit,
+				CacheType:    cacheType,
+				Similarity:   similarity,
+				SavedQuota:   savedQuota,
+				Params:       ruleParams,
+			}
+
+			if attempt := getJsonSchemaAttempt(ctx); attempt != nil && retryInfo == nil {
+				completionsRes.SchemaAttempt = attempt.attempt
+				if attempt.err != nil {
+					completionsRes.SchemaError = attempt.err.Error()
+				}
 			}
 
 			if retryInfo == nil && response.Usage != nil {
@@ -195,6 +228,34 @@ func (s *sChat) Completions(ctx context.Context, params sdkm.ChatCompletionReque
 		}
 	}
 
+	// 命中缓存直接返回, 不请求上游
+	if cacheKey, cacheConfig = s.getCacheConfig(ctx, realModel, params); cacheKey != "" {
+		if cacheResponse := s.getCache(ctx, cacheKey); cacheResponse != nil {
+			// 命中缓存时未选取密钥
+			k = new(model.Key)
+			isCacheHit = true
+			cacheType = 1
+			return *cacheResponse, nil
+		}
+	}
+
+	// 语义缓存, 重试时不再重复查询
+	if len(retry) == 0 {
+
+		var cacheResponse *sdkm.ChatCompletionResponse
+
+		if semantic, cacheResponse, similarity = s.getSemanticCache(ctx, realModel, params); cacheResponse != nil {
+			k = new(model.Key)
+			isCacheHit = true
+			cacheType = 2
+			cacheConfig = &mcommon.CacheConfig{
+				BillingPolicy: semantic.config.BillingPolicy,
+				Ratio:         semantic.config.Ratio,
+			}
+			return *cacheResponse, nil
+		}
+	}
+
 	baseUrl = realModel.BaseUrl
 	path = realModel.Path
 
@@ -264,7 +325,6 @@ func (s *sChat) Completions(ctx context.Context, params sdkm.ChatCompletionReque
 		}
 	}
 
-	request := params
 	key = k.Key
 
 	if !gstr.Contains(realModel.Model, "*") {
@@ -355,6 +415,28 @@ func (s *sChat) Completions(ctx context.Context, params sdkm.ChatCompletionReque
 		}
 	}
 
+	// 参数规则
+	if request, ruleParams, err = common.ApplyParamRules(ctx, realModel, request); err != nil {
+		logger.Error(ctx, err)
+		return response, err
+	}
+
+	// 旧版functions转换为tools
+	request = common.NormalizeFunctions(ctx, realModel, request)
+
+	// 模拟工具调用和JSON模式
+	isEmulateTools, isEmulateJson := common.IsEmulateTools(realModel, request), common.IsEmulateJson(realModel, request)
+	request = common.EmulateRequest(ctx, realModel, request)
+
+	// 上下文管理, 超出模型上下文长度时按应用策略处理, 需在规范消息格式前执行, 裁剪后的连续同角色消息由规范时合并
+	if request, err = common.FitContext(ctx, realModel, request); err != nil {
+		logger.Error(ctx, err)
+		return response, err
+	}
+
+	// 按公司规范消息格式
+	request = common.NormalizeRequest(ctx, realModel, request)
+
 	client, err = common.NewClient(ctx, realModel, key, baseUrl, path)
 	if err != nil {
 		logger.Error(ctx, err)
@@ -421,6 +503,23 @@ func (s *sChat) Completions(ctx context.Context, params sdkm.ChatCompletionReque
 		return response, err
 	}
 
+	response = common.EmulateResponse(isEmulateTools, isEmulateJson, response)
+
+	// 未通过结构化输出校验的响应不写入缓存
+	if attempt := getJsonSchemaAttempt(ctx); attempt != nil {
+		if attempt.err = attempt.validate(response); attempt.err != nil {
+			return response, nil
+		}
+	}
+
+	if cacheKey != "" {
+		s.setCache(ctx, cacheKey, cacheConfig, &response)
+	}
+
+	if semantic != nil {
+		s.setSemanticCache(ctx, semantic, &response)
+	}
+
 	return response, nil
 }
 
@@ -439,57 +538,81 @@ func (s *sChat) CompletionsStream(ctx context.Context, params sdkm.ChatCompletio
 		}
 	}
 
+	// 对话压缩, 重试时沿用已压缩的消息
+	if fallbackModel == nil && len(retry) == 0 {
+		params = s.compact(ctx, params)
+	}
+
 	var (
-		client      sdk.Client
-		reqModel    *model.Model
-		realModel   = new(model.Model)
-		k           *model.Key
-		modelAgent  *model.ModelAgent
-		key         string
-		baseUrl     string
-		path        string
-		completion  string
-		agentTotal  int
-		keyTotal    int
-		connTime    int64
-		duration    int64
-		totalTime   int64
-		textTokens  int
-		imageTokens int
-		totalTokens int
-		usage       *sdkm.Usage
-		retryInfo   *mcommon.Retry
-		projectId   string
+		client       sdk.Client
+		reqModel     *model.Model
+		realModel    = new(model.Model)
+		request      = params // 实际发送的请求, 上游未返回用量时按其估算
+		k            *model.Key
+		modelAgent   *model.ModelAgent
+		key          string
+		baseUrl      string
+		path         string
+		completion   string
+		agentTotal   int
+		keyTotal     int
+		connTime     int64
+		duration     int64
+		totalTime    int64
+		textTokens   int
+		imageTokens  int
+		totalTokens  int
+		usage        *sdkm.Usage
+		retryInfo    *mcommon.Retry
+		projectId    string
+		cacheKey     string
+		cacheConfig  *mcommon.CacheConfig
+		isCacheHit   bool
+		cacheType    int
+		ruleParams   map[string]interface{}
+		semantic     *semanticQuery
+		similarity   float64
+		savedQuota   int
+		isToolCalls  bool
+		finishChoice sdkm.ChatCompletionChoice
+		isUsageSent  bool
+		isEstimated  bool
 	)
 
+	// 上游未返回用量时按令牌数估算, 计费与返回给客户端的用量保持一致
+	estimateUsage := func(ctx context.Context) {
+
+		if usage == nil {
+			usage = new(sdkm.Usage)
+		}
+
+		model := reqModel.Model
+
+		// 多模态按文本和图片分别计费, 需拆分令牌数
+		if reqModel.Type == 100 || usage.PromptTokens == 0 {
+			textTokens, imageTokens = common.GetMultimodalTokens(ctx, model, request.Messages, reqModel)
+			textTokens += common.GetToolsTokens(ctx, model, request)
+			usage.PromptTokens = textTokens + imageTokens
+		}
+
+		if usage.CompletionTokens == 0 {
+			usage.CompletionTokens = common.GetCompletionTokens(ctx, model, completion)
+		}
+
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		isEstimated = true
+	}
+
 	defer func() {
 
 		enterTime := g.RequestFromCtx(ctx).EnterTime.TimestampMilli()
 		internalTime := gtime.TimestampMilli() - enterTime - totalTime
 
 		if err := grpool.Add(gctx.NeverDone(ctx), func(ctx context.Context) {
-			if retryInfo == nil && completion != "" && (usage == nil || usage.PromptTokens == 0 || usage.CompletionTokens == 0) {
-
-				if usage == nil {
-					usage = new(sdkm.Usage)
-				}
+			if retryInfo == nil && completion != "" && (isEstimated || usage == nil || usage.PromptTokens == 0 || usage.CompletionTokens == 0) {
 
-				model := reqModel.Model
-				if !tiktoken.IsEncodingForModel(model) {
-					model = consts.DEFAULT_MODEL
-				}
-
-				if content, ok := params.Messages[len(params.Messages)-1].Content.([]interface{}); ok {
-					textTokens, imageTokens = common.GetMultimodalTokens(ctx, model, content, reqModel)
-					usage.PromptTokens = textTokens + imageTokens
-				} else {
-					if usage.PromptTokens == 0 {
-						usage.PromptTokens = common.GetPromptTokens(ctx, model, params.Messages)
-					}
-				}
-
-				if usage.CompletionTokens == 0 {
-					usage.CompletionTokens = common.GetCompletionTokens(ctx, model, completion)
+				if !isEstimated {
+					estimateUsage(ctx)
 				}
 
 				if reqModel.Type == 100 { // 多模态
@@ -521,6 +644,13 @@ func (s *sChat) CompletionsStream(ctx context.Context, params sdkm.ChatCompletio
 				}
 			}
 
+			// 命中缓存按计费策略计费
+			if isCacheHit {
+				savedQuota = totalTokens
+				totalTokens = getCacheQuota(cacheConfig, totalTokens)
+				savedQuota -= totalTokens
+			}
+
 			if retryInfo == nil && (err == nil || common.IsAborted(err)) {
 				if err := grpool.Add(ctx, func(ctx context.Context) {
 					if err := service.Common().RecordUsage(ctx, totalTokens, k.Key); err != nil {
@@ -544,6 +674,11 @@ func (s *sChat) CompletionsStream(ctx context.Context, params sdkm.ChatCompletio
 					TotalTime:    totalTime,
 					InternalTime: internalTime,
 					EnterTime:    enterTime,
+					IsCacheHit:   isCacheHit,
+					CacheType:    cacheType,
+					Similarity:   similarity,
+					SavedQuota:   savedQuota,
+					Params:       ruleParams,
 				}
 
 				if usage != nil {
@@ -581,6 +716,48 @@ func (s *sChat) CompletionsStream(ctx context.Context, params sdkm.ChatCompletio
 		}
 	}
 
+	// 命中缓存以流式分片回放, 不请求上游
+	if cacheKey, cacheConfig = s.getCacheConfig(ctx, realModel, params); cacheKey != "" {
+		if cacheResponse := s.getCache(ctx, cacheKey); cacheResponse != nil {
+
+			// 命中缓存时未选取密钥
+			k = new(model.Key)
+			isCacheHit = true
+			cacheType = 1
+			usage = cacheResponse.Usage
+
+			if cacheResponse.Choices[0].Message != nil {
+				completion = gconv.String(cacheResponse.Choices[0].Message.Content)
+			}
+
+			return s.replayStream(ctx, reqModel, cacheResponse, params.StreamOptions != nil && params.StreamOptions.IncludeUsage)
+		}
+	}
+
+	// 语义缓存, 重试时不再重复查询
+	if len(retry) == 0 {
+
+		var cacheResponse *sdkm.ChatCompletionResponse
+
+		if semantic, cacheResponse, similarity = s.getSemanticCache(ctx, realModel, params); cacheResponse != nil {
+
+			k = new(model.Key)
+			isCacheHit = true
+			cacheType = 2
+			usage = cacheResponse.Usage
+			cacheConfig = &mcommon.CacheConfig{
+				BillingPolicy: semantic.config.BillingPolicy,
+				Ratio:         semantic.config.Ratio,
+			}
+
+			if cacheResponse.Choices[0].Message != nil {
+				completion = gconv.String(cacheResponse.Choices[0].Message.Content)
+			}
+
+			return s.replayStream(ctx, reqModel, cacheResponse, params.StreamOptions != nil && params.StreamOptions.IncludeUsage)
+		}
+	}
+
 	baseUrl = realModel.BaseUrl
 	path = realModel.Path
 
@@ -650,7 +827,6 @@ func (s *sChat) CompletionsStream(ctx context.Context, params sdkm.ChatCompletio
 		}
 	}
 
-	request := params
 	key = k.Key
 
 	if !gstr.Contains(realModel.Model, "*") {
@@ -728,6 +904,31 @@ func (s *sChat) CompletionsStream(ctx context.Context, params sdkm.ChatCompletio
 		}
 	}
 
+	// 参数规则
+	if request, ruleParams, err = common.ApplyParamRules(ctx, realModel, request); err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	// 旧版functions转换为tools
+	request = common.NormalizeFunctions(ctx, realModel, request)
+
+	// 模拟工具调用和JSON模式, 缓冲分片后转换输出
+	var emulateStream *common.EmulateStream
+	if isEmulateTools, isEmulateJson := common.IsEmulateTools(realModel, request), common.IsEmulateJson(realModel, request); isEmulateTools || isEmulateJson {
+		emulateStream = common.NewEmulateStream(isEmulateTools, isEmulateJson)
+		request = common.EmulateRequest(ctx, realModel, request)
+	}
+
+	// 上下文管理, 超出模型上下文长度时按应用策略处理, 需在规范消息格式前执行, 裁剪后的连续同角色消息由规范时合并
+	if request, err = common.FitContext(ctx, realModel, request); err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	// 按公司规范消息格式
+	request = common.NormalizeRequest(ctx, realModel, request)
+
 	client, err = common.NewClient(ctx, realModel, key, baseUrl, path)
 	if err != nil {
 		logger.Error(ctx, err)
@@ -746,7 +947,11 @@ func (s *sChat) CompletionsStream(ctx context.Context, params sdkm.ChatCompletio
 		return err
 	}
 
-	response, err := client.ChatCompletionStream(ctx, request)
+	// 上游空闲超时需中断读取, 单独使用可取消的上下文
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	response, err := client.ChatCompletionStream(streamCtx, request)
 	if err != nil {
 		logger.Error(ctx, err)
 
@@ -794,11 +999,83 @@ func (s *sChat) CompletionsStream(ctx context.Context, params sdkm.ChatCompletio
 		return err
 	}
 
-	defer close(response)
+	// 上游已返回结束或错误时直接关闭, 否则取消读取并在后台接收剩余数据, 待上游退出后再关闭, 避免向已关闭的通道写入
+	var isStreamDone bool
+	defer func() {
+
+		if isStreamDone {
+			close(response)
+			return
+		}
+
+		cancel()
+
+		go func() {
+			for chunk := range response {
+				if chunk == nil || chunk.Error != nil {
+					break
+				}
+			}
+			close(response)
+		}()
+	}()
+
+	var (
+		geminiStream    *common.GeminiStream
+		responsesStream *common.ResponsesStream
+	)
+
+	switch g.RequestFromCtx(ctx).GetCtxVar(consts.PROTOCOL_KEY).String() {
+	case consts.PROTOCOL_GEMINI:
+		geminiStream = common.NewGeminiStream()
+	case consts.PROTOCOL_RESPONSES:
+		responsesStream, _ = g.RequestFromCtx(ctx).GetCtxVar(consts.RESPONSES_STREAM_KEY).Val().(*common.ResponsesStream)
+	}
+
+	var (
+		pingInterval = config.Cfg.Http.PingInterval * time.Second
+		idleTimeout  = config.Cfg.Http.IdleTimeout * time.Second
+		lastTime     = time.Now()
+		lastPingTime = time.Now()
+		isSent       bool
+		ticker       = time.NewTicker(time.Second)
+	)
+
+	defer ticker.Stop()
 
 	for {
 
-		response := <-response
+		var chunk *sdkm.ChatCompletionResponse
+
+		select {
+		case chunk = <-response:
+			lastTime = time.Now()
+		case <-ticker.C:
+
+			// 上游长时间无数据, 未输出过内容时重试或使用后备模型
+			if idleTimeout > 0 && time.Since(lastTime) >= idleTimeout {
+				chunk = &sdkm.ChatCompletionResponse{Error: errors.ERR_STREAM_IDLE_TIMEOUT}
+				if isSent {
+					logger.Errorf(ctx, "sChat CompletionsStream model: %s, idle timeout after output", realModel.Model)
+					return chunk.Error
+				}
+				cancel()
+				break
+			}
+
+			// 上游无数据时发送心跳, 避免连接被中间代理断开
+			if pingInterval > 0 && time.Since(lastTime) >= pingInterval && time.Since(lastPingTime) >= pingInterval {
+				if err = util.SSEPing(ctx); err != nil {
+					logger.Error(ctx, err)
+					return err
+				}
+				lastPingTime = time.Now()
+			}
+
+			continue
+		}
+
+		response := chunk
 
 		connTime = response.ConnTime
 		duration = response.Duration
@@ -806,6 +1083,8 @@ func (s *sChat) CompletionsStream(ctx context.Context, params sdkm.ChatCompletio
 
 		if response.Error != nil {
 
+			isStreamDone = response.Error != errors.ERR_STREAM_IDLE_TIMEOUT
+
 			if errors.Is(response.Error, io.EOF) {
 
 				if response.Usage != nil {
@@ -829,6 +1108,98 @@ func (s *sChat) CompletionsStream(ctx context.Context, params sdkm.ChatCompletio
 					}
 				}
 
+				// 上游未返回结束原因时, 输出模拟缓冲的剩余内容
+				if emulateStream != nil {
+					if res := emulateStream.Flush(); res != nil {
+
+						if len(res.Choices[0].Delta.ToolCalls) > 0 {
+							for _, toolCall := range res.Choices[0].Delta.ToolCalls {
+								completion += toolCall.Function.Name + toolCall.Function.Arguments
+							}
+							isToolCalls = true
+						}
+
+						completion += res.Choices[0].Delta.Content
+						finishChoice.FinishReason = res.Choices[0].FinishReason
+						res.Model = reqModel.Model
+
+						if err = s.sendStreamChunk(ctx, geminiStream, responsesStream, res); err != nil {
+							logger.Error(ctx, err)
+							return err
+						}
+					}
+				}
+
+				// 仅缓存单个回答的文本结果
+				if (cacheKey != "" || semantic != nil) && !isToolCalls && completion != "" && request.N <= 1 {
+
+					finishChoice.Message = &sdkm.ChatCompletionMessage{
+						Role:    consts.ROLE_ASSISTANT,
+						Content: completion,
+					}
+
+					cacheResponse := &sdkm.ChatCompletionResponse{
+						ID:      response.ID,
+						Object:  "chat.completion",
+						Created: response.Created,
+						Model:   realModel.Model,
+						Choices: []sdkm.ChatCompletionChoice{finishChoice},
+						Usage:   usage,
+					}
+
+					if cacheKey != "" {
+						s.setCache(ctx, cacheKey, cacheConfig, cacheResponse)
+					}
+
+					if semantic != nil {
+						s.setSemanticCache(ctx, semantic, cacheResponse)
+					}
+				}
+
+				// Gemini原生格式无结束标识, 仅补充输出用量
+				if geminiStream != nil {
+					if response.Usage != nil {
+						if res := geminiStream.Conv(response); res != nil {
+							if err = util.SSEServer(ctx, gjson.MustEncodeString(res)); err != nil {
+								logger.Error(ctx, err)
+								return err
+							}
+						}
+					}
+					return nil
+				}
+
+				// Responses格式以完成事件结束
+				if responsesStream != nil {
+					for _, event := range responsesStream.Done(ctx, usage) {
+						if err = util.SSEServerEvent(ctx, event.Type, gjson.MustEncodeString(event)); err != nil {
+							logger.Error(ctx, err)
+							return err
+						}
+					}
+					return nil
+				}
+
+				// 客户端要求返回用量而上游未返回时, 补充最后的用量分片
+				if request.StreamOptions != nil && request.StreamOptions.IncludeUsage && !isUsageSent {
+
+					if usage == nil || usage.PromptTokens == 0 || usage.CompletionTokens == 0 {
+						estimateUsage(ctx)
+					}
+
+					if err = util.SSEServer(ctx, gjson.MustEncodeString(sdkm.ChatCompletionResponse{
+						ID:      response.ID,
+						Object:  "chat.completion.chunk",
+						Created: response.Created,
+						Model:   reqModel.Model,
+						Choices: make([]sdkm.ChatCompletionChoice, 0),
+						Usage:   usage,
+					})); err != nil {
+						logger.Error(ctx, err)
+						return err
+					}
+				}
+
 				if err = util.SSEServer(ctx, "[DONE]"); err != nil {
 					logger.Error(ctx, err)
 					return err
@@ -883,15 +1254,35 @@ func (s *sChat) CompletionsStream(ctx context.Context, params sdkm.ChatCompletio
 			return err
 		}
 
+		if emulateStream != nil {
+			if response = emulateStream.Conv(response); response == nil {
+				continue
+			}
+		}
+
 		if len(response.Choices) > 0 && response.Choices[0].Delta != nil {
 			completion += response.Choices[0].Delta.Content
 		}
 
 		if len(response.Choices) > 0 && response.Choices[0].Delta != nil && len(response.Choices[0].Delta.ToolCalls) > 0 {
-			completion += response.Choices[0].Delta.ToolCalls[0].Function.Arguments
+			for _, toolCall := range response.Choices[0].Delta.ToolCalls {
+				completion += toolCall.Function.Name + toolCall.Function.Arguments
+			}
+			isToolCalls = true
+		}
+
+		if len(response.Choices) > 0 && response.Choices[0].Delta != nil && response.Choices[0].Delta.FunctionCall != nil {
+			completion += response.Choices[0].Delta.FunctionCall.Name + response.Choices[0].Delta.FunctionCall.Arguments
+		}
+
+		if len(response.Choices) > 0 && response.Choices[0].FinishReason != "" {
+			finishChoice.FinishReason = response.Choices[0].FinishReason
 		}
 
 		if response.Usage != nil {
+
+			isUsageSent = true
+
 			if usage == nil {
 				usage = response.Usage
 			} else {
@@ -911,33 +1302,63 @@ func (s *sChat) CompletionsStream(ctx context.Context, params sdkm.ChatCompletio
 
 		// 替换成调用的模型
 		response.Model = reqModel.Model
+		isSent = true
+
+		if err = s.sendStreamChunk(ctx, geminiStream, responsesStream, response); err != nil {
+			logger.Error(ctx, err)
+			return err
+		}
+	}
+}
 
-		// OpenAI官方格式
-		if len(response.ResponseBytes) > 0 {
+// 按请求协议输出流式分片
+func (s *sChat) sendStreamChunk(ctx context.Context, geminiStream *common.GeminiStream, responsesStream *common.ResponsesStream, response *sdkm.ChatCompletionResponse) (err error) {
 
-			data := make(map[string]interface{})
-			if err = gjson.Unmarshal(response.ResponseBytes, &data); err != nil {
+	// Gemini原生格式
+	if geminiStream != nil {
+
+		if res := geminiStream.Conv(response); res != nil {
+			if err = util.SSEServer(ctx, gjson.MustEncodeString(res)); err != nil {
 				logger.Error(ctx, err)
 				return err
 			}
+		}
 
-			// 替换成调用的模型
-			if _, ok := data["model"]; ok {
-				data["model"] = reqModel.Model
-			}
+	} else if responsesStream != nil { // Responses格式
 
-			if err = util.SSEServer(ctx, gjson.MustEncodeString(data)); err != nil {
+		for _, event := range responsesStream.Conv(response) {
+			if err = util.SSEServerEvent(ctx, event.Type, gjson.MustEncodeString(event)); err != nil {
 				logger.Error(ctx, err)
 				return err
 			}
+		}
 
-		} else {
-			if err = util.SSEServer(ctx, gjson.MustEncodeString(response)); err != nil {
-				logger.Error(ctx, err)
-				return err
-			}
+	} else if len(response.ResponseBytes) > 0 { // OpenAI官方格式
+
+		data := make(map[string]interface{})
+		if err = gjson.Unmarshal(response.ResponseBytes, &data); err != nil {
+			logger.Error(ctx, err)
+			return err
+		}
+
+		// 替换成调用的模型
+		if _, ok := data["model"]; ok {
+			data["model"] = response.Model
+		}
+
+		if err = util.SSEServer(ctx, gjson.MustEncodeString(data)); err != nil {
+			logger.Error(ctx, err)
+			return err
+		}
+
+	} else {
+		if err = util.SSEServer(ctx, gjson.MustEncodeString(response)); err != nil {
+			logger.Error(ctx, err)
+			return err
 		}
 	}
+
+	return nil
 }
 
 // 保存日志
@@ -1018,6 +1439,13 @@ func (s *sChat) SaveLog(ctx context.Context, reqModel, realModel, fallbackModel
 	chat.PromptTokens = completionsRes.Usage.PromptTokens
 	chat.CompletionTokens = completionsRes.Usage.CompletionTokens
 	chat.TotalTokens = completionsRes.Usage.TotalTokens
+	chat.IsCacheHit = completionsRes.IsCacheHit
+	chat.CacheType = completionsRes.CacheType
+	chat.Similarity = completionsRes.Similarity
+	chat.SavedQuota = completionsRes.SavedQuota
+	chat.Params = completionsRes.Params
+	chat.SchemaAttempt = completionsRes.SchemaAttempt
+	chat.SchemaError = completionsRes.SchemaError
 
 	if fallbackModel != nil {
 		chat.IsEnableFallback = true
@@ -0,0 +1,229 @@
+package chat
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/gogf/gf/v2/os/grpool"
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/gogf/gf/v2/util/gconv"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/internal/logic/common"
+	"github.com/iimeta/fastapi/internal/model"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/logger"
+	"github.com/iimeta/fastapi/utility/redis"
+	"math"
+	"slices"
+)
+
+// 压缩默认保留的最近对话轮数, 一问一答为一轮
+const DEFAULT_COMPACTION_KEEP_TURNS = 4
+
+// 摘要缓存默认时长(秒)
+const DEFAULT_COMPACTION_TTL = 24 * 60 * 60
+
+// 摘要模型的系统提示词
+const COMPACTION_PROMPT = "You are a conversation summarizer. Summarize the following conversation between a user and an assistant concisely, preserving all facts, decisions, names, numbers, code and open questions that later turns may rely on. Reply with the summary only."
+
+// 摘要替换历史对话时的前缀
+const COMPACTION_SUMMARY_PREFIX = "Summary of the earlier conversation:\n"
+
+// 对话压缩, 提示令牌数超过阈值时将较早的对话替换为摘要模型生成的摘要
+func (s *sChat) compact(ctx context.Context, params sdkm.ChatCompletionRequest) sdkm.ChatCompletionRequest {
+
+	app, err := service.App().GetCacheApp(ctx, service.Session().GetAppId(ctx))
+	if err != nil || !app.IsEnableCompaction || app.CompactionConfig.Model == "" || app.CompactionConfig.Threshold <= 0 {
+		return params
+	}
+
+	config := app.CompactionConfig
+
+	promptTokens := common.GetPromptTokens(ctx, params.Model, params.Messages) + common.GetToolsTokens(ctx, params.Model, params)
+	if promptTokens <= config.Threshold {
+		return params
+	}
+
+	keepTurns := config.KeepTurns
+	if keepTurns <= 0 {
+		keepTurns = DEFAULT_COMPACTION_KEEP_TURNS
+	}
+
+	turns := common.SplitTurns(params.Messages)
+
+	// 开头的系统消息始终保留
+	first := slices.IndexFunc(turns, func(turn []sdkm.ChatCompletionMessage) bool { return turn[0].Role != consts.ROLE_SYSTEM })
+	if first == -1 {
+		return params
+	}
+
+	keepStart := getKeepStart(turns, first, keepTurns)
+	if keepStart-first < 2 {
+		return params
+	}
+
+	// 按前缀逐条累计计算缓存键, 查找已摘要的最长前缀, 仅对其后新增的对话增量摘要
+	keys := make([]string, keepStart+1)
+	digest := config.Model
+	for i := first; i < keepStart; i++ {
+		hash := sha256.Sum256([]byte(digest + gjson.MustEncodeString(turns[i])))
+		digest = hex.EncodeToString(hash[:])
+		keys[i+1] = fmt.Sprintf(consts.COMPACTION_KEY, app.AppId, digest)
+	}
+
+	values, err := redis.MGet(ctx, keys[first+1:]...)
+	if err != nil {
+		logger.Error(ctx, err)
+	}
+
+	var (
+		summary string
+		end     = first
+	)
+
+	for i := keepStart; i > first; i-- {
+		if value := values[keys[i]]; value != nil && !value.IsEmpty() {
+			summary = value.String()
+			end = i
+			break
+		}
+	}
+
+	// 已摘要之后新增的对话未达到保留轮数时原样保留, 避免每轮都重新摘要
+	if summary == "" || getUserTurns(turns[end:keepStart]) >= keepTurns {
+
+		messages := slices.Concat(turns[end:keepStart]...)
+		if summary != "" {
+			messages = append([]sdkm.ChatCompletionMessage{{
+				Role:    consts.ROLE_SYSTEM,
+				Content: COMPACTION_SUMMARY_PREFIX + summary,
+			}}, messages...)
+		}
+
+		if summary, err = s.summarize(ctx, config.Model, messages); err != nil {
+			logger.Error(ctx, err)
+			return params
+		}
+
+		ttl := int64(config.Ttl)
+		if ttl <= 0 {
+			ttl = DEFAULT_COMPACTION_TTL
+		}
+
+		if err = redis.SetEX(ctx, keys[keepStart], summary, ttl); err != nil {
+			logger.Error(ctx, err)
+		}
+
+		end = keepStart
+	}
+
+	messages := make([]sdkm.ChatCompletionMessage, 0, len(params.Messages))
+	messages = append(messages, slices.Concat(turns[:first]...)...)
+	messages = append(messages, sdkm.ChatCompletionMessage{
+		Role:    consts.ROLE_SYSTEM,
+		Content: COMPACTION_SUMMARY_PREFIX + summary,
+	})
+	messages = append(messages, slices.Concat(turns[end:]...)...)
+
+	logger.Infof(ctx, "sChat compact model: %s, promptTokens: %d, compacted messages: %d", params.Model, promptTokens, len(slices.Concat(turns[first:end]...)))
+
+	params.Messages = messages
+
+	return params
+}
+
+// 保留最近keepTurns轮对话的起始位置, 以用户消息开始一轮, 包含其后的助手回复和工具调用
+func getKeepStart(turns [][]sdkm.ChatCompletionMessage, first, keepTurns int) int {
+
+	count := 0
+	for i := len(turns) - 1; i >= first; i-- {
+		if turns[i][0].Role == consts.ROLE_USER {
+			if count++; count == keepTurns {
+				return i
+			}
+		}
+	}
+
+	return first
+}
+
+// 对话轮数, 即用户消息数
+func getUserTurns(turns [][]sdkm.ChatCompletionMessage) int {
+
+	count := 0
+	for _, turn := range turns {
+		if turn[0].Role == consts.ROLE_USER {
+			count++
+		}
+	}
+
+	return count
+}
+
+// 调用摘要模型生成摘要, 摘要费用单独计费和记录日志
+func (s *sChat) summarize(ctx context.Context, modelId string, messages []sdkm.ChatCompletionMessage) (string, error) {
+
+	summaryModel, err := service.Model().GetCacheModel(ctx, modelId)
+	if err != nil || summaryModel == nil {
+		if summaryModel, err = service.Model().GetModelAndSaveCache(ctx, modelId); err != nil {
+			logger.Error(ctx, err)
+			return "", err
+		}
+	}
+
+	transcript := make([]string, 0, len(messages))
+	for _, message := range messages {
+		if len(message.ToolCalls) > 0 {
+			transcript = append(transcript, fmt.Sprintf("%s: %s", message.Role, gjson.MustEncodeString(message.ToolCalls)))
+		} else {
+			transcript = append(transcript, fmt.Sprintf("%s: %s", message.Role, gconv.String(message.Content)))
+		}
+	}
+
+	response, err := s.SmartCompletions(ctx, sdkm.ChatCompletionRequest{
+		Model: summaryModel.Model,
+		Messages: []sdkm.ChatCompletionMessage{{
+			Role:    consts.ROLE_SYSTEM,
+			Content: COMPACTION_PROMPT,
+		}, {
+			Role:    consts.ROLE_USER,
+			Content: gstr.Join(transcript, "\n\n"),
+		}},
+	}, summaryModel, nil)
+	if err != nil {
+		logger.Error(ctx, err)
+		return "", err
+	}
+
+	if len(response.Choices) == 0 || response.Choices[0].Message == nil || gconv.String(response.Choices[0].Message.Content) == "" {
+		return "", errors.Newf("summary model %s returned empty content", summaryModel.Model)
+	}
+
+	if response.Usage != nil {
+		if err := grpool.Add(gctx.NeverDone(ctx), func(ctx context.Context) {
+			if err := service.Common().RecordUsage(ctx, getSummaryQuota(summaryModel, response.Usage), ""); err != nil {
+				logger.Error(ctx, err)
+				panic(err)
+			}
+		}); err != nil {
+			logger.Error(ctx, err)
+		}
+	}
+
+	return gconv.String(response.Choices[0].Message.Content), nil
+}
+
+// 摘要模型按文本额度计费
+func getSummaryQuota(summaryModel *model.Model, usage *sdkm.Usage) int {
+
+	if summaryModel.TextQuota.BillingMethod != 1 {
+		return summaryModel.TextQuota.FixedQuota
+	}
+
+	return int(math.Ceil(float64(usage.PromptTokens)*summaryModel.TextQuota.PromptRatio + float64(usage.CompletionTokens)*summaryModel.TextQuota.CompletionRatio))
+}
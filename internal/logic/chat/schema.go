@@ -0,0 +1,120 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/util/gconv"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/internal/logic/common"
+	"github.com/iimeta/fastapi/internal/model"
+	mcommon "github.com/iimeta/fastapi/internal/model/common"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/logger"
+	"slices"
+)
+
+// 结构化输出校验失败默认重试次数
+const DEFAULT_JSON_SCHEMA_RETRIES = 2
+
+// 校验失败时追加的纠正提示
+const JSON_SCHEMA_PROMPT = "Your previous reply does not conform to the required JSON schema: %s. Reply again with only a valid JSON object that strictly matches the schema, without any explanation or markdown."
+
+type jsonSchemaAttemptKey struct{}
+
+// 单次校验尝试, 由Completions写入校验结果
+type jsonSchemaAttempt struct {
+	attempt int
+	schema  interface{}
+	err     error
+}
+
+// 获取请求中的JSON Schema, 未指定时返回空
+func getJsonSchema(params sdkm.ChatCompletionRequest) interface{} {
+
+	if params.ResponseFormat == nil || params.ResponseFormat.Type != "json_schema" || params.ResponseFormat.JSONSchema == nil {
+		return nil
+	}
+
+	return params.ResponseFormat.JSONSchema.Schema
+}
+
+func getJsonSchemaAttempt(ctx context.Context) *jsonSchemaAttempt {
+
+	if attempt, ok := ctx.Value(jsonSchemaAttemptKey{}).(*jsonSchemaAttempt); ok {
+		return attempt
+	}
+
+	return nil
+}
+
+// 校验响应内容是否符合请求的JSON Schema
+func (a *jsonSchemaAttempt) validate(response sdkm.ChatCompletionResponse) error {
+
+	if len(response.Choices) == 0 || response.Choices[0].Message == nil {
+		return errors.New("response has no content")
+	}
+
+	return common.ValidateJSONSchema(gconv.String(response.Choices[0].Message.Content), a.schema)
+}
+
+// 结构化输出校验, 校验失败时追加纠正提示重试, 每次尝试单独记录日志和计费
+func (s *sChat) jsonSchemaCompletions(ctx context.Context, params sdkm.ChatCompletionRequest, config mcommon.JsonSchemaConfig, schema interface{}) (response sdkm.ChatCompletionResponse, err error) {
+
+	maxRetries := config.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = DEFAULT_JSON_SCHEMA_RETRIES
+	}
+
+	var (
+		request       = params
+		fallbackModel *model.Model
+		attempt       *jsonSchemaAttempt
+	)
+
+	for i := 0; i <= maxRetries; i++ {
+
+		attempt = &jsonSchemaAttempt{
+			attempt: i + 1,
+			schema:  schema,
+		}
+
+		if response, err = s.Completions(context.WithValue(ctx, jsonSchemaAttemptKey{}, attempt), request, fallbackModel); err != nil {
+			logger.Error(ctx, err)
+			return response, err
+		}
+
+		if attempt.err == nil {
+			return response, nil
+		}
+
+		logger.Errorf(ctx, "sChat jsonSchemaCompletions model: %s, attempt: %d, error: %v", params.Model, attempt.attempt, attempt.err)
+
+		messages := slices.Clone(request.Messages)
+
+		if len(response.Choices) > 0 && response.Choices[0].Message != nil {
+			messages = append(messages, sdkm.ChatCompletionMessage{
+				Role:    consts.ROLE_ASSISTANT,
+				Content: gconv.String(response.Choices[0].Message.Content),
+			})
+		}
+
+		request.Messages = append(messages, sdkm.ChatCompletionMessage{
+			Role:    consts.ROLE_USER,
+			Content: fmt.Sprintf(JSON_SCHEMA_PROMPT, attempt.err.Error()),
+		})
+
+		// 重试使用后备模型
+		if config.FallbackModel != "" && fallbackModel == nil {
+			if fallbackModel, err = service.Model().GetCacheModel(ctx, config.FallbackModel); err != nil || fallbackModel == nil {
+				if fallbackModel, err = service.Model().GetModelAndSaveCache(ctx, config.FallbackModel); err != nil {
+					logger.Error(ctx, err)
+					fallbackModel = nil
+				}
+			}
+		}
+	}
+
+	return response, errors.NewError(422, "json_schema_validation_failed", fmt.Sprintf("The response does not conform to the JSON schema after %d attempts: %s", attempt.attempt, attempt.err.Error()), "invalid_response_error")
+}
@@ -0,0 +1,460 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/util/gconv"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/config"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/logic/common"
+	"github.com/iimeta/fastapi/internal/model"
+	mcommon "github.com/iimeta/fastapi/internal/model/common"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/cache"
+	"github.com/iimeta/fastapi/utility/crypto"
+	"github.com/iimeta/fastapi/utility/logger"
+	"github.com/iimeta/fastapi/utility/redis"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// 语义缓存默认相似度阈值
+const DEFAULT_SEMANTIC_THRESHOLD = 0.95
+
+// 语义缓存默认时长(秒)
+const DEFAULT_SEMANTIC_TTL = 24 * 60 * 60
+
+// 语义缓存默认最大条数
+const DEFAULT_SEMANTIC_MAX_SIZE = 1000
+
+// 进程内索引清理过期条目的间隔(秒)
+const SEMANTIC_SWEEP_INTERVAL = 60
+
+// Redis索引在进程内缓存已解码向量的最大范围数
+const SEMANTIC_VECTOR_CACHE_SIZE = 1000
+
+type semanticEntry struct {
+	Id        string                       `json:"id"`                 // 条目ID
+	Vector    string                       `json:"vector"`             // 向量(base64)
+	Response  *sdkm.ChatCompletionResponse `json:"response,omitempty"` // 缓存的响应, Redis索引中单独存储
+	ExpiresAt int64                        `json:"expires_at"`         // 过期时间
+	vector    []float32
+}
+
+// 语义缓存查询上下文, 未命中时用于写入缓存
+type semanticQuery struct {
+	scope  string
+	vector []float32
+	config *mcommon.SemanticCacheConfig
+}
+
+type semanticIndex interface {
+	Search(ctx context.Context, scope string, vector []float32, threshold float64) (*semanticEntry, float64)
+	Add(ctx context.Context, scope string, entry *semanticEntry, maxSize int, ttl int64)
+}
+
+var (
+	memoryIndex = &semanticMemoryIndex{entries: make(map[string][]*semanticEntry)}
+	redisIndex  = &semanticRedisIndex{vectors: cache.New(SEMANTIC_VECTOR_CACHE_SIZE)}
+)
+
+func getSemanticIndex() semanticIndex {
+
+	if config.Cfg.SemanticCache.Store == "redis" {
+		return redisIndex
+	}
+
+	return memoryIndex
+}
+
+// 进程内向量索引
+type semanticMemoryIndex struct {
+	sync.RWMutex
+	entries map[string][]*semanticEntry
+	sweptAt int64
+}
+
+func (i *semanticMemoryIndex) Search(ctx context.Context, scope string, vector []float32, threshold float64) (*semanticEntry, float64) {
+
+	i.RLock()
+	defer i.RUnlock()
+
+	return search(i.entries[scope], vector, threshold)
+}
+
+func (i *semanticMemoryIndex) Add(ctx context.Context, scope string, entry *semanticEntry, maxSize int, ttl int64) {
+
+	i.Lock()
+	defer i.Unlock()
+
+	now := gtime.Timestamp()
+
+	// 定期清理所有范围的过期条目, 并删除已无条目的范围
+	if now-i.sweptAt >= SEMANTIC_SWEEP_INTERVAL {
+
+		for s, entries := range i.entries {
+			if entries = getUnexpired(entries, now); len(entries) == 0 {
+				delete(i.entries, s)
+			} else {
+				i.entries[s] = entries
+			}
+		}
+
+		i.sweptAt = now
+	}
+
+	entries := append(getUnexpired(i.entries[scope], now), entry)
+
+	if len(entries) > maxSize {
+		entries = entries[len(entries)-maxSize:]
+	}
+
+	i.entries[scope] = entries
+}
+
+func getUnexpired(entries []*semanticEntry, now int64) []*semanticEntry {
+
+	unexpired := make([]*semanticEntry, 0, len(entries)+1)
+	for _, entry := range entries {
+		if entry.ExpiresAt > now {
+			unexpired = append(unexpired, entry)
+		}
+	}
+
+	return unexpired
+}
+
+// Redis持久化向量索引, 多实例共享, 列表中仅存储向量, 响应按条目单独存储, 命中后再读取
+// 已解码的向量按范围缓存在进程内, 写入时递增版本号, 版本号变化后才重新读取列表
+type semanticRedisIndex struct {
+	vectors *cache.Cache
+}
+
+// 进程内缓存的已解码向量
+type semanticVectors struct {
+	version int
+	entries []*semanticEntry
+}
+
+func (i *semanticRedisIndex) Search(ctx context.Context, scope string, vector []float32, threshold float64) (*semanticEntry, float64) {
+
+	version, err := redis.GetInt(ctx, fmt.Sprintf(consts.SEMANTIC_VERSION_KEY, scope))
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, 0
+	}
+
+	// 版本号不存在说明索引已过期或从未写入
+	if version == 0 {
+		return nil, 0
+	}
+
+	entries := i.getEntries(ctx, scope, version)
+	if len(entries) == 0 {
+		return nil, 0
+	}
+
+	hit, similarity := search(entries, vector, threshold)
+	if hit == nil {
+		return nil, 0
+	}
+
+	// 缓存的条目为多个请求共用, 响应挂在副本上
+	hit = &semanticEntry{
+		Id:        hit.Id,
+		Vector:    hit.Vector,
+		ExpiresAt: hit.ExpiresAt,
+		vector:    hit.vector,
+	}
+
+	reply, err := redis.GetStr(ctx, fmt.Sprintf(consts.SEMANTIC_RESPONSE_KEY, scope, hit.Id))
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, 0
+	}
+
+	// 响应已过期或被淘汰
+	if reply == "" {
+		return nil, 0
+	}
+
+	hit.Response = new(sdkm.ChatCompletionResponse)
+	if err = gjson.Unmarshal([]byte(reply), hit.Response); err != nil {
+		logger.Error(ctx, err)
+		return nil, 0
+	}
+
+	return hit, similarity
+}
+
+// 获取范围内已解码的向量, 进程内缓存的版本与Redis一致时直接使用
+func (i *semanticRedisIndex) getEntries(ctx context.Context, scope string, version int) []*semanticEntry {
+
+	if vectors, ok := i.vectors.GetVal(ctx, scope).(*semanticVectors); ok && vectors.version == version {
+		return vectors.entries
+	}
+
+	values, err := redis.LRange(ctx, fmt.Sprintf(consts.SEMANTIC_CACHE_KEY, scope), 0, -1)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil
+	}
+
+	entries := make([]*semanticEntry, 0, len(values))
+	for _, value := range values {
+
+		entry := new(semanticEntry)
+		if err = gjson.Unmarshal(value.Bytes(), entry); err != nil {
+			logger.Error(ctx, err)
+			continue
+		}
+
+		if entry.vector, err = common.DecodeEmbedding(entry.Vector); err != nil {
+			logger.Error(ctx, err)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err = i.vectors.Set(ctx, scope, &semanticVectors{version: version, entries: entries}, time.Duration(DEFAULT_SEMANTIC_TTL)*time.Second); err != nil {
+		logger.Error(ctx, err)
+	}
+
+	return entries
+}
+
+func (i *semanticRedisIndex) Add(ctx context.Context, scope string, entry *semanticEntry, maxSize int, ttl int64) {
+
+	key := fmt.Sprintf(consts.SEMANTIC_CACHE_KEY, scope)
+
+	if err := redis.SetEX(ctx, fmt.Sprintf(consts.SEMANTIC_RESPONSE_KEY, scope, entry.Id), gjson.MustEncodeString(entry.Response), ttl); err != nil {
+		logger.Error(ctx, err)
+		return
+	}
+
+	if _, err := redis.RPush(ctx, key, gjson.MustEncodeString(&semanticEntry{
+		Id:        entry.Id,
+		Vector:    entry.Vector,
+		ExpiresAt: entry.ExpiresAt,
+	})); err != nil {
+		logger.Error(ctx, err)
+		return
+	}
+
+	if err := redis.LTrim(ctx, key, int64(-maxSize), -1); err != nil {
+		logger.Error(ctx, err)
+	}
+
+	if _, err := redis.Expire(ctx, key, ttl); err != nil {
+		logger.Error(ctx, err)
+	}
+
+	versionKey := fmt.Sprintf(consts.SEMANTIC_VERSION_KEY, scope)
+
+	if _, err := redis.Incr(ctx, versionKey); err != nil {
+		logger.Error(ctx, err)
+	}
+
+	if _, err := redis.Expire(ctx, versionKey, ttl); err != nil {
+		logger.Error(ctx, err)
+	}
+}
+
+// 返回相似度最高且不低于阈值的未过期条目
+func search(entries []*semanticEntry, vector []float32, threshold float64) (*semanticEntry, float64) {
+
+	var (
+		now        = gtime.Timestamp()
+		hit        *semanticEntry
+		similarity float64
+	)
+
+	for _, entry := range entries {
+
+		if entry.ExpiresAt <= now {
+			continue
+		}
+
+		if score := cosineSimilarity(entry.vector, vector); score >= threshold && score > similarity {
+			hit = entry
+			similarity = score
+		}
+	}
+
+	return hit, similarity
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// 查询语义缓存, 仅对开启语义缓存的应用且无工具调用的单轮对话生效
+func (s *sChat) getSemanticCache(ctx context.Context, realModel *model.Model, params sdkm.ChatCompletionRequest) (*semanticQuery, *sdkm.ChatCompletionResponse, float64) {
+
+	if len(params.Tools) > 0 || len(params.Functions) > 0 || params.N > 1 {
+		return nil, nil, 0
+	}
+
+	app, err := service.App().GetCacheApp(ctx, service.Session().GetAppId(ctx))
+	if err != nil || !app.IsEnableSemanticCache || app.SemanticCacheConfig.EmbeddingModel == "" {
+		return nil, nil, 0
+	}
+
+	var prompt string
+	for _, message := range params.Messages {
+		switch message.Role {
+		case consts.ROLE_USER:
+			if prompt != "" {
+				return nil, nil, 0
+			}
+			prompt = gconv.String(message.Content)
+		case consts.ROLE_SYSTEM:
+		default:
+			return nil, nil, 0
+		}
+	}
+
+	if prompt == "" {
+		return nil, nil, 0
+	}
+
+	vector := s.getSemanticVector(ctx, app.SemanticCacheConfig.EmbeddingModel, prompt)
+	if len(vector) == 0 {
+		return nil, nil, 0
+	}
+
+	query := &semanticQuery{
+		scope:  fmt.Sprintf("%d:%s", app.AppId, realModel.Model),
+		vector: vector,
+		config: &app.SemanticCacheConfig,
+	}
+
+	// 系统提示词不同的请求不共用缓存
+	if params.Messages[0].Role == consts.ROLE_SYSTEM {
+		query.scope += ":" + crypto.SM3(gconv.String(params.Messages[0].Content))
+	}
+
+	threshold := query.config.Threshold
+	if threshold <= 0 {
+		threshold = DEFAULT_SEMANTIC_THRESHOLD
+	}
+
+	entry, similarity := getSemanticIndex().Search(ctx, query.scope, query.vector, threshold)
+	if entry == nil || entry.Response == nil || len(entry.Response.Choices) == 0 {
+		return query, nil, 0
+	}
+
+	logger.Debugf(ctx, "sChat getSemanticCache hit scope: %s, similarity: %f", query.scope, similarity)
+
+	setCacheHeader(ctx, "SEMANTIC")
+
+	return query, cloneResponse(entry.Response), similarity
+}
+
+// 写入语义缓存
+func (s *sChat) setSemanticCache(ctx context.Context, query *semanticQuery, response *sdkm.ChatCompletionResponse) {
+
+	if len(response.Choices) == 0 {
+		return
+	}
+
+	ttl := int64(query.config.Ttl)
+	if ttl <= 0 {
+		ttl = DEFAULT_SEMANTIC_TTL
+	}
+
+	maxSize := query.config.MaxSize
+	if maxSize <= 0 {
+		maxSize = config.Cfg.SemanticCache.MaxSize
+	}
+
+	if maxSize <= 0 {
+		maxSize = DEFAULT_SEMANTIC_MAX_SIZE
+	}
+
+	// 调用方后续仍会修改响应, 缓存副本
+	getSemanticIndex().Add(ctx, query.scope, &semanticEntry{
+		Id:        gctx.CtxId(ctx),
+		Vector:    common.EncodeEmbedding(query.vector),
+		Response:  cloneResponse(response),
+		ExpiresAt: gtime.Timestamp() + ttl,
+		vector:    query.vector,
+	}, maxSize, ttl)
+}
+
+// 深拷贝响应, 避免缓存中的响应与请求处理中的响应互相影响
+func cloneResponse(response *sdkm.ChatCompletionResponse) *sdkm.ChatCompletionResponse {
+
+	r := *response
+	r.Choices = slices.Clone(response.Choices)
+
+	for i := range r.Choices {
+		if r.Choices[i].Message != nil {
+			message := *r.Choices[i].Message
+			r.Choices[i].Message = &message
+		}
+	}
+
+	if response.Usage != nil {
+		usage := *response.Usage
+		r.Usage = &usage
+	}
+
+	return &r
+}
+
+// 获取提示的向量, 同一请求使用后备模型时复用首次结果, 避免重复向量化和计费
+func (s *sChat) getSemanticVector(ctx context.Context, embeddingModel, prompt string) []float32 {
+
+	r := g.RequestFromCtx(ctx)
+
+	if r != nil {
+		if vector, ok := r.GetCtxVar(consts.SEMANTIC_VECTOR_KEY).Val().([]float32); ok {
+			return vector
+		}
+	}
+
+	// 向量化计费与日志按向量接口正常记录
+	embeddingRes, err := service.Embedding().Embeddings(ctx, sdkm.EmbeddingRequest{
+		Model: embeddingModel,
+		Input: prompt,
+	}, nil)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil
+	}
+
+	if len(embeddingRes.Data) == 0 {
+		return nil
+	}
+
+	if r != nil {
+		r.SetCtxVar(consts.SEMANTIC_VECTOR_KEY, embeddingRes.Data[0].Embedding)
+	}
+
+	return embeddingRes.Data[0].Embedding
+}
@@ -18,7 +18,6 @@ import (
 	mcommon "github.com/iimeta/fastapi/internal/model/common"
 	"github.com/iimeta/fastapi/internal/service"
 	"github.com/iimeta/fastapi/utility/logger"
-	"github.com/iimeta/tiktoken-go"
 	"math"
 )
 
@@ -45,6 +44,8 @@ func (s *sChat) SmartCompletions(ctx context.Context, params sdkm.ChatCompletion
 		imageTokens int
 		totalTokens int
 		projectId   string
+		request     sdkm.ChatCompletionRequest
+		ruleParams  map[string]interface{}
 	)
 
 	defer func() {
@@ -55,26 +56,18 @@ func (s *sChat) SmartCompletions(ctx context.Context, params sdkm.ChatCompletion
 		if retryInfo == nil && (err == nil || common.IsAborted(err)) {
 
 			model := realModel.Model
-			if !tiktoken.IsEncodingForModel(model) {
-				model = consts.DEFAULT_MODEL
-			}
 
 			if realModel.Type == 100 { // 多模态
 				if response.Usage == nil {
 
 					response.Usage = new(sdkm.Usage)
 
-					if content, ok := params.Messages[len(params.Messages)-1].Content.([]interface{}); ok {
-						textTokens, imageTokens = common.GetMultimodalTokens(ctx, model, content, realModel)
-						response.Usage.PromptTokens = textTokens + imageTokens
-					} else {
-						if response.Usage.PromptTokens == 0 {
-							response.Usage.PromptTokens = common.GetPromptTokens(ctx, model, params.Messages)
-						}
-					}
+					textTokens, imageTokens = common.GetMultimodalTokens(ctx, model, params.Messages, realModel)
+					textTokens += common.GetToolsTokens(ctx, model, params)
+					response.Usage.PromptTokens = textTokens + imageTokens
 
-					if response.Usage.CompletionTokens == 0 && len(response.Choices) > 0 && response.Choices[0].Message != nil {
-						response.Usage.CompletionTokens = common.GetCompletionTokens(ctx, model, gconv.String(response.Choices[0].Message.Content))
+					if len(response.Choices) > 0 && response.Choices[0].Message != nil {
+						response.Usage.CompletionTokens = common.GetCompletionTokens(ctx, model, common.GetMessageCompletion(response.Choices[0].Message))
 					}
 
 					response.Usage.TotalTokens = response.Usage.PromptTokens + response.Usage.CompletionTokens
@@ -88,10 +81,10 @@ func (s *sChat) SmartCompletions(ctx context.Context, params sdkm.ChatCompletion
 
 				response.Usage = new(sdkm.Usage)
 
-				response.Usage.PromptTokens = common.GetPromptTokens(ctx, model, params.Messages)
+				response.Usage.PromptTokens = common.GetPromptTokens(ctx, model, params.Messages) + common.GetToolsTokens(ctx, model, params)
 
 				if len(response.Choices) > 0 && response.Choices[0].Message != nil {
-					response.Usage.CompletionTokens = common.GetCompletionTokens(ctx, model, gconv.String(response.Choices[0].Message.Content))
+					response.Usage.CompletionTokens = common.GetCompletionTokens(ctx, model, common.GetMessageCompletion(response.Choices[0].Message))
 				}
 
 				response.Usage.TotalTokens = response.Usage.PromptTokens + response.Usage.CompletionTokens
@@ -119,6 +112,7 @@ func (s *sChat) SmartCompletions(ctx context.Context, params sdkm.ChatCompletion
 				TotalTime:    response.TotalTime,
 				InternalTime: internalTime,
 				EnterTime:    enterTime,
+				Params:       ruleParams,
 			}
 
 			if retryInfo == nil && response.Usage != nil {
@@ -310,6 +304,18 @@ func (s *sChat) SmartCompletions(ctx context.Context, params sdkm.ChatCompletion
 		}
 	}
 
+	// 参数规则
+	if request, ruleParams, err = common.ApplyParamRules(ctx, realModel, params); err != nil {
+		logger.Error(ctx, err)
+		return response, err
+	}
+
+	// 旧版functions转换为tools
+	request = common.NormalizeFunctions(ctx, realModel, request)
+
+	// 按公司规范消息格式
+	request = common.NormalizeRequest(ctx, realModel, request)
+
 	client, err = common.NewClient(ctx, realModel, key, baseUrl, path)
 	if err != nil {
 		logger.Error(ctx, err)
@@ -328,7 +334,7 @@ func (s *sChat) SmartCompletions(ctx context.Context, params sdkm.ChatCompletion
 		return response, err
 	}
 
-	response, err = client.ChatCompletion(ctx, params)
+	response, err = client.ChatCompletion(ctx, request)
 	if err != nil {
 		logger.Error(ctx, err)
 
@@ -0,0 +1,305 @@
+package common
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/gogf/gf/v2/os/gfile"
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/gogf/gf/v2/util/gconv"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/config"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/utility/logger"
+	tiktokengo "github.com/iimeta/tiktoken-go"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// 预分词正则
+const (
+	// cl100k, 数字最多3位一组
+	CL100K_PATTERN = `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+	// 通义千问, 数字逐位切分
+	QWEN_PATTERN = `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+	// DeepSeek-V3, 数字和中日文已先行切分
+	DEEPSEEK_PATTERN = `[\x21-\x2f\x3a-\x40\x5b-\x60\x7b-\x7e][A-Za-z]+|[^\r\n\p{L}\p{P}\p{S}]?[\p{L}\p{M}]+| ?[\p{P}\p{S}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+)
+
+// 词表加载失败后的重试间隔
+const BPE_RETRY_INTERVAL = time.Minute
+
+// 词表下载超时时间
+const BPE_DOWNLOAD_TIMEOUT = 5 * time.Minute
+
+func init() {
+	// 按注册顺序倒序匹配, DeepSeek最后注册以优先于名称中的qwen
+	RegisterTokenizer(`(?i)glm|cogview`, &BpeTokenizer{
+		Name:     "glm",
+		Vocab:    "https://huggingface.co/THUDM/glm-4-9b-chat/resolve/main/tokenizer.model",
+		Pattern:  CL100K_PATTERN,
+		Fallback: &EstimateTokenizer{CjkRatio: 0.625, TextRatio: 1}, // 约1.6字1令牌
+	})
+	RegisterTokenizer(`(?i)qwen|qwq|qvq`, &BpeTokenizer{
+		Name:     "qwen",
+		Vocab:    "https://huggingface.co/Qwen/Qwen-7B/resolve/main/qwen.tiktoken",
+		Pattern:  QWEN_PATTERN,
+		Fallback: &EstimateTokenizer{CjkRatio: 0.7, TextRatio: 1}, // 词表基于cl100k扩充中文, 约1.4字1令牌
+	})
+	RegisterTokenizer(`(?i)deepseek`, &BpeTokenizer{
+		Name:     "deepseek",
+		Vocab:    "https://huggingface.co/deepseek-ai/DeepSeek-V3/resolve/main/tokenizer.json",
+		Pattern:  DEEPSEEK_PATTERN,
+		Splits:   []string{`\p{N}{1,3}`, `[一-龥぀-ゟ゠-ヿ]+`},
+		Fallback: &EstimateTokenizer{CjkRatio: 0.6, CharRatio: 0.3}, // 官方估算规则: 1个中文字符约0.6令牌, 1个英文字符约0.3令牌
+	})
+}
+
+// 基于公开词表的BPE分词器, 首次使用时加载词表, 加载失败时使用后备分词器估算
+type BpeTokenizer struct {
+	Name     string    // 名称, 用于按名称配置本地词表
+	Vocab    string    // 默认词表地址, tiktoken格式(每行base64编码的字节和序号)或HuggingFace的tokenizer.json
+	Pattern  string    // 预分词正则
+	Splits   []string  // 预分词前依次独立切分的正则, 对应HuggingFace的Split预分词
+	Fallback Tokenizer // 后备分词器
+
+	mu       sync.Mutex
+	codec    *tiktokengo.Tiktoken
+	splits   []*regexp.Regexp
+	failedAt time.Time
+}
+
+func (t *BpeTokenizer) NumTokensFromString(text string) (int, error) {
+
+	if text == "" {
+		return 0, nil
+	}
+
+	codec, splits := t.load()
+	if codec == nil {
+		return t.Fallback.NumTokensFromString(text)
+	}
+
+	pieces := []string{text}
+	for _, split := range splits {
+		pieces = splitIsolated(pieces, split)
+	}
+
+	tokens := 0
+	for _, piece := range pieces {
+		tokens += len(codec.EncodeOrdinary(piece))
+	}
+
+	return tokens, nil
+}
+
+func (t *BpeTokenizer) NumTokensFromMessages(messages []sdkm.ChatCompletionMessage) (int, error) {
+
+	if codec, _ := t.load(); codec == nil {
+		return t.Fallback.NumTokensFromMessages(messages)
+	}
+
+	return numTokensFromMessages(t, messages)
+}
+
+func (t *BpeTokenizer) load() (*tiktokengo.Tiktoken, []*regexp.Regexp) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.codec != nil || time.Since(t.failedAt) < BPE_RETRY_INTERVAL {
+		return t.codec, t.splits
+	}
+
+	ctx := gctx.New()
+	now := time.Now()
+
+	codec, splits, err := t.newCodec(ctx)
+	if err != nil {
+		logger.Errorf(ctx, "BpeTokenizer load name: %s, error: %v", t.Name, err)
+		t.failedAt = now
+		return nil, nil
+	}
+
+	logger.Infof(ctx, "BpeTokenizer load name: %s, time: %d ms", t.Name, time.Since(now).Milliseconds())
+
+	t.codec, t.splits = codec, splits
+
+	return t.codec, t.splits
+}
+
+func (t *BpeTokenizer) newCodec(ctx context.Context) (*tiktokengo.Tiktoken, []*regexp.Regexp, error) {
+
+	vocab := t.Vocab
+	if path := config.Cfg.Tokenizer.Vocabs[t.Name]; path != "" {
+		vocab = path
+	}
+
+	data, err := readVocab(ctx, vocab)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ranks map[string]int
+	if gstr.HasSuffix(gstr.Split(vocab, "?")[0], ".json") {
+		ranks, err = parseHuggingFaceVocab(data)
+	} else {
+		ranks, err = parseTiktokenVocab(data)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bpe, err := tiktokengo.NewCoreBPE(ranks, map[string]int{}, t.Pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	splits := make([]*regexp.Regexp, 0, len(t.Splits))
+	for _, split := range t.Splits {
+		re, err := regexp.Compile(split)
+		if err != nil {
+			return nil, nil, err
+		}
+		splits = append(splits, re)
+	}
+
+	return tiktokengo.NewTiktoken(bpe, &tiktokengo.Encoding{Name: t.Name, PatStr: t.Pattern, MergeableRanks: ranks}, nil), splits, nil
+}
+
+func readVocab(ctx context.Context, vocab string) ([]byte, error) {
+
+	if !gstr.HasPrefix(vocab, "http://") && !gstr.HasPrefix(vocab, "https://") {
+		if !gfile.Exists(vocab) {
+			return nil, errors.Newf("vocab file not found: %s", vocab)
+		}
+		return gfile.GetBytes(vocab), nil
+	}
+
+	client := g.Client().Timeout(BPE_DOWNLOAD_TIMEOUT)
+	if config.Cfg.Http.ProxyUrl != "" {
+		client.SetProxy(config.Cfg.Http.ProxyUrl)
+	}
+
+	response, err := client.Get(ctx, vocab)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := response.Close(); err != nil {
+			logger.Error(ctx, err)
+		}
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Newf("download vocab: %s, statusCode: %d", vocab, response.StatusCode)
+	}
+
+	return response.ReadAll(), nil
+}
+
+// tiktoken格式词表, 每行为base64编码的字节和序号
+func parseTiktokenVocab(data []byte) (map[string]int, error) {
+
+	ranks := make(map[string]int)
+
+	for _, line := range gstr.Split(string(data), "\n") {
+
+		fields := gstr.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, err
+		}
+
+		ranks[string(token)] = gconv.Int(fields[1])
+	}
+
+	if len(ranks) == 0 {
+		return nil, errors.New("empty tiktoken vocab")
+	}
+
+	return ranks, nil
+}
+
+// HuggingFace字节级BPE词表, 词元为字节映射后的字符, 以词元ID作为合并优先级
+func parseHuggingFaceVocab(data []byte) (map[string]int, error) {
+
+	vocab := gjson.New(data).Get("model.vocab").MapStrAny()
+	if len(vocab) == 0 {
+		return nil, errors.New("empty huggingface vocab")
+	}
+
+	decoder := getByteDecoder()
+	ranks := make(map[string]int, len(vocab))
+
+	for token, id := range vocab {
+
+		bytes := make([]byte, 0, len(token))
+		for _, r := range token {
+			b, ok := decoder[r]
+			if !ok {
+				bytes = nil
+				break
+			}
+			bytes = append(bytes, b)
+		}
+
+		if bytes != nil {
+			ranks[string(bytes)] = gconv.Int(id)
+		}
+	}
+
+	return ranks, nil
+}
+
+// GPT-2字节到可见字符的映射的逆映射
+func getByteDecoder() map[rune]byte {
+
+	decoder := make(map[rune]byte, 256)
+
+	n := 0
+	for b := 0; b < 256; b++ {
+		if (b >= '!' && b <= '~') || (b >= 0xA1 && b <= 0xAC) || (b >= 0xAE && b <= 0xFF) {
+			decoder[rune(b)] = byte(b)
+		} else {
+			decoder[rune(256+n)] = byte(b)
+			n++
+		}
+	}
+
+	return decoder
+}
+
+// 按正则切分, 匹配部分和未匹配部分各自独立成段
+func splitIsolated(pieces []string, re *regexp.Regexp) []string {
+
+	result := make([]string, 0, len(pieces))
+
+	for _, piece := range pieces {
+
+		last := 0
+		for _, loc := range re.FindAllStringIndex(piece, -1) {
+			if loc[0] > last {
+				result = append(result, piece[last:loc[0]])
+			}
+			result = append(result, piece[loc[0]:loc[1]])
+			last = loc[1]
+		}
+
+		if last < len(piece) {
+			result = append(result, piece[last:])
+		}
+	}
+
+	return result
+}
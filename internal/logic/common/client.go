@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/iimeta/fastapi-sdk"
 	"github.com/iimeta/fastapi/internal/config"
+	"github.com/iimeta/fastapi/internal/consts"
 	"github.com/iimeta/fastapi/internal/model"
 	"github.com/iimeta/fastapi/internal/service"
 	"github.com/iimeta/fastapi/utility/logger"
@@ -18,7 +19,13 @@ func NewClient(ctx context.Context, model *model.Model, key, baseURL, path strin
 	return sdk.NewClient(ctx, GetCorpCode(ctx, model.Corp), model.Model, key, baseURL, path, nil, config.Cfg.Http.ProxyUrl), nil
 }
 
-func NewEmbeddingClient(ctx context.Context, model *model.Model, key, baseURL, path string) (*sdk.EmbeddingClient, error) {
+func NewEmbeddingClient(ctx context.Context, model *model.Model, key, baseURL, path string) (EmbeddingClient, error) {
+
+	switch corp := GetCorpCode(ctx, model.Corp); corp {
+	case consts.CORP_BAIDU, consts.CORP_ZHIPUAI, consts.CORP_ALIYUN, consts.CORP_GOOGLE:
+		return NewCorpEmbeddingClient(ctx, corp, model, key, baseURL, path), nil
+	}
+
 	return sdk.NewEmbeddingClient(ctx, model.Model, key, baseURL, path, config.Cfg.Http.ProxyUrl), nil
 }
 
@@ -5,19 +5,24 @@ import (
 	"context"
 	"fmt"
 	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
 	"github.com/gogf/gf/v2/os/gctx"
 	"github.com/gogf/gf/v2/os/grpool"
+	"github.com/gogf/gf/v2/os/gtime"
 	"github.com/gogf/gf/v2/text/gregex"
 	"github.com/gogf/gf/v2/text/gstr"
 	"github.com/gogf/gf/v2/util/gconv"
 	sdkm "github.com/iimeta/fastapi-sdk/model"
 	"github.com/iimeta/fastapi-sdk/sdkerr"
 	"github.com/iimeta/fastapi/internal/config"
+	"github.com/iimeta/fastapi/internal/consts"
 	"github.com/iimeta/fastapi/internal/errors"
 	"github.com/iimeta/fastapi/internal/model"
 	"github.com/iimeta/fastapi/internal/service"
 	"github.com/iimeta/fastapi/utility/logger"
+	"io"
 	"net"
+	"net/http"
 	"strings"
 )
 
@@ -255,3 +260,48 @@ func CheckIp(ctx context.Context, ipWhitelist, ipBlacklist []string) error {
 
 	return nil
 }
+
+// 创建独立的内部请求, 用于批处理、任务回调等以其它密钥身份执行的调用, 会话保存在该请求上, 不影响当前请求
+func NewInternalRequest(ctx context.Context, method, url string, body io.Reader) (*ghttp.Request, error) {
+
+	request, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.RemoteAddr = "127.0.0.1:0"
+
+	r := &ghttp.Request{
+		Request:   request,
+		Response:  &ghttp.Response{},
+		EnterTime: gtime.Now(),
+	}
+	r.Response.Request = r
+
+	return r, nil
+}
+
+// 标记请求是否显式传入temperature, 由Gemini、Responses等协议入口在转换请求时设置
+func SetTemperatureFlag(ctx context.Context, isSet bool) {
+	if r := g.RequestFromCtx(ctx); r != nil {
+		r.SetCtxVar(consts.IS_SET_TEMPERATURE_KEY, isSet)
+	}
+}
+
+// 请求是否显式传入temperature, 未传时上游使用默认值, 原生Chat接口和批处理以请求体为准
+func IsSetTemperature(ctx context.Context) bool {
+
+	r := g.RequestFromCtx(ctx)
+	if r == nil {
+		return false
+	}
+
+	if value := r.GetCtxVar(consts.IS_SET_TEMPERATURE_KEY); !value.IsNil() {
+		return value.Bool()
+	}
+
+	_, ok := getRequestBody(ctx)["temperature"]
+
+	return ok
+}
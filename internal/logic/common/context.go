@@ -0,0 +1,123 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/internal/model"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/logger"
+	"slices"
+)
+
+// 每次请求固定的引导令牌数, 与tiktoken.NumTokensFromMessages保持一致
+const PRIMING_TOKENS = 3
+
+// 按应用的上下文管理策略处理超出模型上下文长度的请求, 在请求上游前执行, 避免无效计费
+func FitContext(ctx context.Context, model *model.Model, request sdkm.ChatCompletionRequest) (sdkm.ChatCompletionRequest, error) {
+
+	if model.ContextLength <= 0 || len(request.Messages) == 0 {
+		return request, nil
+	}
+
+	app, err := service.App().GetCacheApp(ctx, service.Session().GetAppId(ctx))
+	if err != nil || !app.IsEnableContext {
+		return request, nil
+	}
+
+	config := app.ContextConfig
+
+	reservedTokens := config.ReservedTokens
+	if request.MaxCompletionTokens > 0 {
+		reservedTokens = request.MaxCompletionTokens
+	} else if request.MaxTokens > 0 {
+		reservedTokens = request.MaxTokens
+	}
+
+	limit := model.ContextLength - reservedTokens
+
+	promptTokens := GetPromptTokens(ctx, model.Model, request.Messages) + GetToolsTokens(ctx, model.Model, request)
+	if promptTokens <= limit {
+		return request, nil
+	}
+
+	exceededErr := errors.NewError(400, "context_length_exceeded", fmt.Sprintf("This model's maximum context length is %d tokens. However, your messages resulted in %d tokens and %d tokens are reserved for the completion. Please reduce the length of the messages or completion.", model.ContextLength, promptTokens, reservedTokens), "invalid_request_error")
+
+	if config.Strategy != 1 && config.Strategy != 2 {
+		logger.Errorf(ctx, "FitContext model: %s, contextLength: %d, promptTokens: %d, reservedTokens: %d, rejected", model.Model, model.ContextLength, promptTokens, reservedTokens)
+		return request, exceededErr
+	}
+
+	turns := SplitTurns(request.Messages)
+
+	// 系统消息和最后一轮对话始终保留
+	var (
+		first = slices.IndexFunc(turns, func(turn []sdkm.ChatCompletionMessage) bool { return turn[0].Role != consts.ROLE_SYSTEM })
+		last  = len(turns) - 1
+	)
+
+	if first == -1 {
+		return request, exceededErr
+	}
+
+	// 裁剪中间的对话时保留第一轮对话
+	if config.Strategy == 2 {
+		first++
+	}
+
+	var (
+		dropped = make(map[int]bool)
+		i       = first
+	)
+
+	for ; i < last && promptTokens > limit; i++ {
+		if turns[i][0].Role != consts.ROLE_SYSTEM {
+			promptTokens -= GetPromptTokens(ctx, model.Model, turns[i]) - PRIMING_TOKENS
+			dropped[i] = true
+		}
+	}
+
+	// 避免裁剪后以assistant消息开头
+	for ; i < last && dropped[i-1] && turns[i][0].Role == consts.ROLE_ASSISTANT; i++ {
+		promptTokens -= GetPromptTokens(ctx, model.Model, turns[i]) - PRIMING_TOKENS
+		dropped[i] = true
+	}
+
+	if promptTokens > limit {
+		logger.Errorf(ctx, "FitContext model: %s, contextLength: %d, promptTokens: %d, reservedTokens: %d, still exceeded after trimming", model.Model, model.ContextLength, promptTokens, reservedTokens)
+		return request, exceededErr
+	}
+
+	messages := make([]sdkm.ChatCompletionMessage, 0, len(request.Messages))
+	for i, turn := range turns {
+		if !dropped[i] {
+			messages = append(messages, turn...)
+		}
+	}
+
+	logger.Infof(ctx, "FitContext model: %s, strategy: %d, contextLength: %d, trimmed messages: %d, promptTokens: %d", model.Model, config.Strategy, model.ContextLength, len(request.Messages)-len(messages), promptTokens)
+
+	request.Messages = messages
+
+	return request, nil
+}
+
+// 按轮拆分消息, 工具调用与其结果作为同一轮, 裁剪时一起移除
+func SplitTurns(messages []sdkm.ChatCompletionMessage) [][]sdkm.ChatCompletionMessage {
+
+	turns := make([][]sdkm.ChatCompletionMessage, 0, len(messages))
+
+	for _, message := range messages {
+
+		if len(turns) > 0 && (message.Role == consts.ROLE_TOOL || message.Role == consts.ROLE_FUNCTION) {
+			turns[len(turns)-1] = append(turns[len(turns)-1], message)
+			continue
+		}
+
+		turns = append(turns, []sdkm.ChatCompletionMessage{message})
+	}
+
+	return turns
+}
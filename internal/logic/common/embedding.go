@@ -0,0 +1,373 @@
+package common
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/util/gconv"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/config"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/internal/model"
+	"github.com/iimeta/fastapi/utility/logger"
+	"math"
+	"net/http"
+	"time"
+)
+
+type EmbeddingClient interface {
+	Embeddings(ctx context.Context, request sdkm.EmbeddingRequest) (sdkm.EmbeddingResponse, error)
+}
+
+// 非OpenAI格式的向量接口
+type CorpEmbeddingClient struct {
+	corp     string
+	model    string
+	key      string
+	baseURL  string
+	path     string
+	proxyURL string
+}
+
+// 各公司向量接口默认地址
+var embeddingEndpoints = map[string][2]string{
+	consts.CORP_BAIDU:   {"https://qianfan.baidubce.com/v2", "/embeddings"},
+	consts.CORP_ZHIPUAI: {"https://open.bigmodel.cn/api/paas/v4", "/embeddings"},
+	consts.CORP_ALIYUN:  {"https://dashscope.aliyuncs.com/api/v1", "/services/embeddings/text-embedding/text-embedding"},
+	consts.CORP_GOOGLE:  {"https://generativelanguage.googleapis.com/v1beta", ""},
+}
+
+func NewCorpEmbeddingClient(ctx context.Context, corp string, model *model.Model, key, baseURL, path string) *CorpEmbeddingClient {
+
+	client := &CorpEmbeddingClient{
+		corp:     corp,
+		model:    model.Model,
+		key:      key,
+		baseURL:  baseURL,
+		path:     path,
+		proxyURL: config.Cfg.Http.ProxyUrl,
+	}
+
+	if endpoint, ok := embeddingEndpoints[corp]; ok {
+
+		if client.baseURL == "" {
+			client.baseURL = endpoint[0]
+		}
+
+		if client.path == "" {
+			client.path = endpoint[1]
+		}
+	}
+
+	return client
+}
+
+func (c *CorpEmbeddingClient) Embeddings(ctx context.Context, request sdkm.EmbeddingRequest) (response sdkm.EmbeddingResponse, err error) {
+
+	logger.Infof(ctx, "Embeddings corp: %s, model: %s, start", c.corp, c.model)
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		response.TotalTime = gtime.TimestampMilli() - now
+		logger.Infof(ctx, "Embeddings corp: %s, model: %s, totalTime: %d ms", c.corp, c.model, response.TotalTime)
+	}()
+
+	inputs := GetEmbeddingInputs(request.Input)
+	if len(inputs) == 0 {
+		return response, errors.ERR_INVALID_PARAMETER
+	}
+
+	var (
+		url     = c.baseURL + c.path
+		data    interface{}
+		headers = map[string]string{
+			"Content-Type":  "application/json",
+			"Authorization": "Bearer " + c.key,
+		}
+	)
+
+	switch c.corp {
+	case consts.CORP_ALIYUN:
+
+		parameters := g.Map{}
+		if request.Dimensions > 0 {
+			parameters["dimension"] = request.Dimensions
+		}
+
+		data = g.Map{
+			"model":      c.model,
+			"input":      g.Map{"texts": inputs},
+			"parameters": parameters,
+		}
+
+	case consts.CORP_GOOGLE:
+
+		headers = map[string]string{
+			"Content-Type":   "application/json",
+			"x-goog-api-key": c.key,
+		}
+
+		requests := make([]g.Map, 0, len(inputs))
+		for _, input := range inputs {
+
+			content := g.Map{
+				"model":   "models/" + c.model,
+				"content": g.Map{"parts": []g.Map{{"text": input}}},
+			}
+
+			if request.Dimensions > 0 {
+				content["outputDimensionality"] = request.Dimensions
+			}
+
+			requests = append(requests, content)
+		}
+
+		// 单条使用embedContent, 多条使用batchEmbedContents
+		if len(requests) == 1 {
+			url = fmt.Sprintf("%s/models/%s:embedContent", c.baseURL, c.model)
+			data = requests[0]
+		} else {
+			url = fmt.Sprintf("%s/models/%s:batchEmbedContents", c.baseURL, c.model)
+			data = g.Map{"requests": requests}
+		}
+
+	default:
+
+		body := g.Map{
+			"model": c.model,
+			"input": inputs,
+		}
+
+		if request.Dimensions > 0 {
+			body["dimensions"] = request.Dimensions
+		}
+
+		data = body
+	}
+
+	client := g.Client().Timeout(config.Cfg.Http.Timeout * time.Second).SetHeaderMap(headers)
+
+	if c.proxyURL != "" {
+		client.SetProxy(c.proxyURL)
+	}
+
+	res, err := client.Post(ctx, url, data)
+	if err != nil {
+		logger.Errorf(ctx, "Embeddings corp: %s, model: %s, error: %v", c.corp, c.model, err)
+		return response, err
+	}
+	defer func() {
+		if err := res.Close(); err != nil {
+			logger.Error(ctx, err)
+		}
+	}()
+
+	bytes := res.ReadAll()
+
+	if res.StatusCode != http.StatusOK {
+		logger.Errorf(ctx, "Embeddings corp: %s, model: %s, statusCode: %d, response: %s", c.corp, c.model, res.StatusCode, string(bytes))
+		return response, errors.NewError(res.StatusCode, "embedding_error", string(bytes), "api_error")
+	}
+
+	result := gjson.New(bytes)
+
+	response = sdkm.EmbeddingResponse{
+		Object: "list",
+		Model:  c.model,
+		Data:   make([]sdkm.Embedding, 0, len(inputs)),
+		Usage:  new(sdkm.Usage),
+	}
+
+	switch c.corp {
+	case consts.CORP_ALIYUN:
+
+		for _, embedding := range result.Get("output.embeddings").Maps() {
+			response.Data = append(response.Data, sdkm.Embedding{
+				Object:    "embedding",
+				Embedding: gconv.Float32s(embedding["embedding"]),
+				Index:     gconv.Int(embedding["text_index"]),
+			})
+		}
+
+		response.Usage.PromptTokens = result.Get("usage.total_tokens").Int()
+
+	case consts.CORP_GOOGLE:
+
+		values := make([]interface{}, 0)
+		if len(inputs) == 1 {
+			values = append(values, result.Get("embedding.values").Interfaces())
+		} else {
+			for _, embedding := range result.Get("embeddings").Maps() {
+				values = append(values, embedding["values"])
+			}
+		}
+
+		for i, value := range values {
+			response.Data = append(response.Data, sdkm.Embedding{
+				Object:    "embedding",
+				Embedding: gconv.Float32s(value),
+				Index:     i,
+			})
+		}
+
+		// Gemini不返回用量, 按输入估算
+		for _, input := range inputs {
+			response.Usage.PromptTokens += GetCompletionTokens(ctx, c.model, input)
+		}
+
+	default:
+
+		for _, embedding := range result.Get("data").Maps() {
+			response.Data = append(response.Data, sdkm.Embedding{
+				Object:    "embedding",
+				Embedding: gconv.Float32s(embedding["embedding"]),
+				Index:     gconv.Int(embedding["index"]),
+			})
+		}
+
+		response.Usage.PromptTokens = result.Get("usage.prompt_tokens").Int()
+	}
+
+	response.Usage.TotalTokens = response.Usage.PromptTokens
+
+	return response, nil
+}
+
+// 各公司单次向量请求的输入条数上限
+var embeddingBatchSizes = map[string]int{
+	consts.CORP_OPENAI:  2048,
+	consts.CORP_AZURE:   2048,
+	consts.CORP_BAIDU:   16,
+	consts.CORP_ALIYUN:  25,
+	consts.CORP_ZHIPUAI: 64,
+	consts.CORP_GOOGLE:  100,
+}
+
+// 按模型配置的条数和令牌数上限拆分输入, 未超出上限时返回nil
+func SplitEmbeddingInputs(ctx context.Context, model *model.Model, input interface{}) [][]string {
+
+	inputs := GetEmbeddingInputs(input)
+	if len(inputs) <= 1 {
+		return nil
+	}
+
+	maxBatchSize := model.EmbeddingConfig.MaxBatchSize
+	if maxBatchSize <= 0 {
+		maxBatchSize = embeddingBatchSizes[GetCorpCode(ctx, model.Corp)]
+	}
+
+	maxBatchTokens := model.EmbeddingConfig.MaxBatchTokens
+
+	if (maxBatchSize <= 0 || len(inputs) <= maxBatchSize) && maxBatchTokens <= 0 {
+		return nil
+	}
+
+	var (
+		batches = make([][]string, 0)
+		batch   = make([]string, 0)
+		tokens  = 0
+	)
+
+	for _, input := range inputs {
+
+		inputTokens := 0
+		if maxBatchTokens > 0 {
+			inputTokens = GetCompletionTokens(ctx, model.Model, input)
+		}
+
+		if len(batch) > 0 && ((maxBatchSize > 0 && len(batch) >= maxBatchSize) || (maxBatchTokens > 0 && tokens+inputTokens > maxBatchTokens)) {
+			batches = append(batches, batch)
+			batch = make([]string, 0)
+			tokens = 0
+		}
+
+		batch = append(batch, input)
+		tokens += inputTokens
+	}
+
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+
+	if len(batches) <= 1 {
+		return nil
+	}
+
+	return batches
+}
+
+// 统一输入格式, 兼容字符串和字符串数组, 令牌数组等非字符串输入返回nil, 由调用方原样透传
+func GetEmbeddingInputs(input interface{}) []string {
+
+	switch value := input.(type) {
+	case string:
+		return []string{value}
+	case []string:
+		return value
+	case []interface{}:
+		inputs := make([]string, 0, len(value))
+		for _, v := range value {
+			text, ok := v.(string)
+			if !ok {
+				return nil
+			}
+			inputs = append(inputs, text)
+		}
+		return inputs
+	}
+
+	return nil
+}
+
+// 向量转换为base64编码, 与OpenAI一致采用小端序float32
+func ConvEmbeddingBase64(response sdkm.EmbeddingResponse) model.EmbeddingBase64Res {
+
+	res := model.EmbeddingBase64Res{
+		Object: response.Object,
+		Data:   make([]model.EmbeddingBase64, 0, len(response.Data)),
+		Model:  response.Model,
+		Usage:  response.Usage,
+	}
+
+	for _, embedding := range response.Data {
+		res.Data = append(res.Data, model.EmbeddingBase64{
+			Object:    embedding.Object,
+			Embedding: EncodeEmbedding(embedding.Embedding),
+			Index:     embedding.Index,
+		})
+	}
+
+	return res
+}
+
+// 向量编码为base64字符串
+func EncodeEmbedding(embedding []float32) string {
+
+	bytes := make([]byte, 4*len(embedding))
+	for i, value := range embedding {
+		binary.LittleEndian.PutUint32(bytes[i*4:], math.Float32bits(value))
+	}
+
+	return base64.StdEncoding.EncodeToString(bytes)
+}
+
+// base64字符串解码为向量
+func DecodeEmbedding(data string) ([]float32, error) {
+
+	bytes, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+
+	embedding := make([]float32, len(bytes)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(bytes[i*4:]))
+	}
+
+	return embedding, nil
+}
@@ -0,0 +1,374 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/gogf/gf/v2/util/gconv"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/model"
+	"github.com/iimeta/fastapi/utility/logger"
+	"github.com/iimeta/fastapi/utility/util"
+	"slices"
+)
+
+// 模拟工具调用的提示词
+const EMULATE_TOOLS_PROMPT = `You have access to the following tools:
+%s
+
+To call one or more tools, reply with ONLY a JSON object in exactly this format and nothing else:
+{"tool_calls": [{"name": "<tool name>", "arguments": {<arguments matching the tool parameters>}}]}
+%s`
+
+// 模拟JSON模式的提示词
+const EMULATE_JSON_PROMPT = "Reply with ONLY a valid JSON object, without markdown code fences or any other text."
+
+// 模拟JSON Schema的提示词
+const EMULATE_JSON_SCHEMA_PROMPT = "The JSON object must conform to this JSON schema:\n%s"
+
+// 是否模拟工具调用
+func IsEmulateTools(model *model.Model, request sdkm.ChatCompletionRequest) bool {
+	return model.IsEnableEmulate && model.EmulateConfig.IsEmulateTools && len(request.Tools) > 0 && gconv.String(request.ToolChoice) != "none"
+}
+
+// 是否模拟JSON模式
+func IsEmulateJson(model *model.Model, request sdkm.ChatCompletionRequest) bool {
+	return model.IsEnableEmulate && model.EmulateConfig.IsEmulateJson && request.ResponseFormat != nil && (request.ResponseFormat.Type == "json_object" || request.ResponseFormat.Type == "json_schema")
+}
+
+// 将工具定义和JSON格式要求注入系统提示词, 并转换历史工具调用消息
+func EmulateRequest(ctx context.Context, model *model.Model, request sdkm.ChatCompletionRequest) sdkm.ChatCompletionRequest {
+
+	var (
+		isTools = IsEmulateTools(model, request)
+		isJson  = IsEmulateJson(model, request)
+		prompts = make([]string, 0)
+	)
+
+	if !isTools && !isJson {
+		return request
+	}
+
+	if isTools {
+
+		tools := make([]interface{}, 0, len(request.Tools))
+		for _, tool := range request.Tools {
+			if tool.Function != nil {
+				tools = append(tools, tool.Function)
+			}
+		}
+
+		prompts = append(prompts, fmt.Sprintf(EMULATE_TOOLS_PROMPT, gjson.MustEncodeString(tools), getToolChoicePrompt(request.ToolChoice)))
+
+		request.Messages = emulateToolMessages(request.Messages)
+		request.Tools = nil
+		request.ToolChoice = nil
+		request.ParallelToolCalls = nil
+	}
+
+	if isJson {
+
+		prompt := EMULATE_JSON_PROMPT
+		if request.ResponseFormat.JSONSchema != nil && request.ResponseFormat.JSONSchema.Schema != nil {
+			prompt += "\n" + fmt.Sprintf(EMULATE_JSON_SCHEMA_PROMPT, gjson.MustEncodeString(request.ResponseFormat.JSONSchema.Schema))
+		}
+
+		// 工具调用时仍需按工具调用格式回复
+		if isTools {
+			prompt = "If you do not call a tool, " + gstr.LcFirst(prompt)
+		}
+
+		prompts = append(prompts, prompt)
+
+		request.ResponseFormat = nil
+	}
+
+	prompt := gstr.Join(prompts, "\n\n")
+
+	logger.Debugf(ctx, "EmulateRequest model: %s, tools: %t, json: %t", model.Model, isTools, isJson)
+
+	messages := slices.Clone(request.Messages)
+	if len(messages) > 0 && messages[0].Role == consts.ROLE_SYSTEM {
+		messages[0].Content = gconv.String(messages[0].Content) + "\n\n" + prompt
+	} else {
+		messages = append([]sdkm.ChatCompletionMessage{{
+			Role:    consts.ROLE_SYSTEM,
+			Content: prompt,
+		}}, messages...)
+	}
+
+	request.Messages = messages
+
+	return request
+}
+
+func getToolChoicePrompt(toolChoice interface{}) string {
+
+	if name := gjson.New(toolChoice).Get("function.name").String(); name != "" {
+		return fmt.Sprintf("You must call the tool \"%s\".", name)
+	}
+
+	if gconv.String(toolChoice) == "required" {
+		return "You must call at least one tool."
+	}
+
+	return "If no tool is needed, reply to the user normally."
+}
+
+// 上游不支持工具角色, 历史工具调用和结果转换为普通文本消息
+func emulateToolMessages(messages []sdkm.ChatCompletionMessage) []sdkm.ChatCompletionMessage {
+
+	result := make([]sdkm.ChatCompletionMessage, 0, len(messages))
+
+	for _, message := range messages {
+
+		if message.Role == consts.ROLE_ASSISTANT && len(message.ToolCalls) > 0 {
+
+			toolCalls := make([]map[string]interface{}, 0, len(message.ToolCalls))
+			for _, toolCall := range message.ToolCalls {
+				toolCalls = append(toolCalls, map[string]interface{}{
+					"name":      toolCall.Function.Name,
+					"arguments": gjson.New(toolCall.Function.Arguments).Map(),
+				})
+			}
+
+			result = append(result, sdkm.ChatCompletionMessage{
+				Role:    consts.ROLE_ASSISTANT,
+				Content: gjson.MustEncodeString(map[string]interface{}{"tool_calls": toolCalls}),
+			})
+
+			continue
+		}
+
+		if message.Role == consts.ROLE_TOOL || message.Role == consts.ROLE_FUNCTION {
+			result = append(result, sdkm.ChatCompletionMessage{
+				Role:    consts.ROLE_USER,
+				Content: fmt.Sprintf("Tool result (id: %s, name: %s):\n%s", message.ToolCallID, message.Name, gconv.String(message.Content)),
+			})
+			continue
+		}
+
+		result = append(result, message)
+	}
+
+	return result
+}
+
+// 将模型的结构化回复还原为工具调用或纯JSON内容
+func EmulateResponse(isTools, isJson bool, response sdkm.ChatCompletionResponse) sdkm.ChatCompletionResponse {
+
+	if !isTools && !isJson {
+		return response
+	}
+
+	// 内容已修改, 不能再使用上游原始响应
+	response.ResponseBytes = nil
+
+	for i, choice := range response.Choices {
+
+		if choice.Message == nil {
+			continue
+		}
+
+		content := gconv.String(choice.Message.Content)
+
+		if isTools {
+			if toolCalls := ParseEmulateToolCalls(content); len(toolCalls) > 0 {
+				message := *choice.Message
+				message.Content = nil
+				message.ToolCalls = toolCalls
+				response.Choices[i].Message = &message
+				response.Choices[i].FinishReason = "tool_calls"
+				continue
+			}
+		}
+
+		if isJson {
+			message := *choice.Message
+			message.Content = ExtractJSON(content)
+			response.Choices[i].Message = &message
+		}
+	}
+
+	return response
+}
+
+// 解析模拟工具调用的回复, 非工具调用时返回空
+func ParseEmulateToolCalls(content string) []sdkm.ToolCall {
+
+	content = ExtractJSON(content)
+	if !gjson.Valid(content) {
+		return nil
+	}
+
+	calls := gjson.New(content).Get("tool_calls").Maps()
+	if len(calls) == 0 {
+		return nil
+	}
+
+	toolCalls := make([]sdkm.ToolCall, 0, len(calls))
+	for _, call := range calls {
+
+		name := gconv.String(call["name"])
+		if name == "" {
+			continue
+		}
+
+		arguments := call["arguments"]
+		if arguments == nil {
+			arguments = map[string]interface{}{}
+		}
+
+		index := len(toolCalls)
+
+		toolCalls = append(toolCalls, sdkm.ToolCall{
+			Index: &index,
+			ID:    "call_" + util.GenerateId(),
+			Type:  "function",
+			Function: sdkm.FunctionCall{
+				Name:      name,
+				Arguments: gjson.MustEncodeString(arguments),
+			},
+		})
+	}
+
+	return toolCalls
+}
+
+// 去除Markdown代码块等多余内容, 提取JSON对象
+func ExtractJSON(content string) string {
+
+	trimmed := gstr.Trim(content)
+
+	if gstr.HasPrefix(trimmed, "```") {
+		if index := gstr.Pos(trimmed, "\n"); index != -1 {
+			trimmed = trimmed[index+1:]
+		}
+		trimmed = gstr.Trim(gstr.TrimRightStr(gstr.Trim(trimmed), "```"))
+	}
+
+	if gjson.Valid(trimmed) {
+		return trimmed
+	}
+
+	start := gstr.Pos(trimmed, "{")
+	end := gstr.PosR(trimmed, "}")
+	if start != -1 && end > start && gjson.Valid(trimmed[start:end+1]) {
+		return trimmed[start : end+1]
+	}
+
+	return content
+}
+
+// 流式模拟, 缓冲可能为结构化回复的分片, 结束时统一转换输出
+type EmulateStream struct {
+	isTools   bool
+	isJson    bool
+	content   string
+	last      *sdkm.ChatCompletionResponse
+	usage     *sdkm.Usage
+	isPass    bool
+	isFlushed bool
+}
+
+func NewEmulateStream(isTools, isJson bool) *EmulateStream {
+	return &EmulateStream{
+		isTools: isTools,
+		isJson:  isJson,
+	}
+}
+
+// 返回需要输出的分片, 缓冲中时返回空
+func (s *EmulateStream) Conv(chunk *sdkm.ChatCompletionResponse) *sdkm.ChatCompletionResponse {
+
+	if s.isPass || s.isFlushed {
+		return chunk
+	}
+
+	if len(chunk.Choices) == 0 || chunk.Choices[0].Delta == nil {
+
+		if s.last == nil {
+			return chunk
+		}
+
+		if chunk.Usage != nil {
+			s.usage = chunk.Usage
+		}
+
+		return nil
+	}
+
+	s.content += chunk.Choices[0].Delta.Content
+	s.last = chunk
+
+	if chunk.Usage != nil {
+		s.usage = chunk.Usage
+	}
+
+	if chunk.Choices[0].FinishReason != "" {
+		return s.flush()
+	}
+
+	// 仅模拟工具调用时, 首个字符不是JSON或代码块即可直接输出
+	if !s.isJson {
+		if trimmed := gstr.TrimLeft(s.content); trimmed != "" && !gstr.HasPrefix(trimmed, "{") && !gstr.HasPrefix(trimmed, "`") {
+
+			s.isPass = true
+
+			res := *chunk
+			res.ResponseBytes = nil
+			res.Choices = slices.Clone(chunk.Choices)
+			delta := *chunk.Choices[0].Delta
+			delta.Content = s.content
+			res.Choices[0].Delta = &delta
+
+			return &res
+		}
+	}
+
+	return nil
+}
+
+// 上游结束时输出剩余的缓冲内容
+func (s *EmulateStream) Flush() *sdkm.ChatCompletionResponse {
+
+	if s.isPass || s.isFlushed || s.last == nil {
+		return nil
+	}
+
+	return s.flush()
+}
+
+func (s *EmulateStream) flush() *sdkm.ChatCompletionResponse {
+
+	s.isFlushed = true
+
+	res := *s.last
+	res.ResponseBytes = nil
+	res.Usage = s.usage
+
+	choice := sdkm.ChatCompletionChoice{
+		Index:        s.last.Choices[0].Index,
+		Delta:        &sdkm.ChatCompletionStreamChoiceDelta{Role: consts.ROLE_ASSISTANT},
+		FinishReason: s.last.Choices[0].FinishReason,
+	}
+
+	if choice.FinishReason == "" {
+		choice.FinishReason = "stop"
+	}
+
+	if toolCalls := ParseEmulateToolCalls(s.content); s.isTools && len(toolCalls) > 0 {
+		choice.Delta.ToolCalls = toolCalls
+		choice.FinishReason = "tool_calls"
+	} else if s.isJson {
+		choice.Delta.Content = ExtractJSON(s.content)
+	} else {
+		choice.Delta.Content = s.content
+	}
+
+	res.Choices = []sdkm.ChatCompletionChoice{choice}
+
+	return &res
+}
@@ -0,0 +1,346 @@
+package common
+
+import (
+	"fmt"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/util/gconv"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/model"
+	"strings"
+)
+
+// Gemini请求转换为Chat请求
+func ConvGeminiToChatCompletionRequest(reqModel string, params model.GeminiGenerateContentReq) sdkm.ChatCompletionRequest {
+
+	request := sdkm.ChatCompletionRequest{
+		Model: reqModel,
+	}
+
+	if params.SystemInstruction != nil {
+
+		var texts []string
+		for _, part := range params.SystemInstruction.Parts {
+			if part.Text != "" {
+				texts = append(texts, part.Text)
+			}
+		}
+
+		if len(texts) > 0 {
+			request.Messages = append(request.Messages, sdkm.ChatCompletionMessage{
+				Role:    consts.ROLE_SYSTEM,
+				Content: strings.Join(texts, "\n"),
+			})
+		}
+	}
+
+	for _, content := range params.Contents {
+		request.Messages = append(request.Messages, convGeminiContent(content)...)
+	}
+
+	for _, tool := range params.Tools {
+		for _, declaration := range tool.FunctionDeclarations {
+			request.Tools = append(request.Tools, sdkm.Tool{
+				Type: "function",
+				Function: &sdkm.FunctionDefinition{
+					Name:        declaration.Name,
+					Description: declaration.Description,
+					Parameters:  declaration.Parameters,
+				},
+			})
+		}
+	}
+
+	if params.ToolConfig != nil && params.ToolConfig.FunctionCallingConfig != nil {
+		switch params.ToolConfig.FunctionCallingConfig.Mode {
+		case "AUTO":
+			request.ToolChoice = "auto"
+		case "ANY":
+			if len(params.ToolConfig.FunctionCallingConfig.AllowedFunctionNames) == 1 {
+				request.ToolChoice = map[string]interface{}{
+					"type": "function",
+					"function": map[string]interface{}{
+						"name": params.ToolConfig.FunctionCallingConfig.AllowedFunctionNames[0],
+					},
+				}
+			} else {
+				request.ToolChoice = "required"
+			}
+		case "NONE":
+			request.ToolChoice = "none"
+		}
+	}
+
+	if config := params.GenerationConfig; config != nil {
+
+		request.Stop = config.StopSequences
+		request.N = config.CandidateCount
+		request.MaxTokens = config.MaxOutputTokens
+		request.Seed = config.Seed
+
+		if config.Temperature != nil {
+			request.Temperature = *config.Temperature
+		}
+
+		if config.TopP != nil {
+			request.TopP = *config.TopP
+		}
+
+		if config.PresencePenalty != nil {
+			request.PresencePenalty = *config.PresencePenalty
+		}
+
+		if config.FrequencyPenalty != nil {
+			request.FrequencyPenalty = *config.FrequencyPenalty
+		}
+
+		if config.ResponseMimeType == "application/json" {
+			request.ResponseFormat = &sdkm.ChatCompletionResponseFormat{
+				Type: "json_object",
+			}
+		}
+	}
+
+	return request
+}
+
+// Chat响应转换为Gemini响应
+func ConvChatCompletionResponseToGemini(response *sdkm.ChatCompletionResponse) model.GeminiGenerateContentRes {
+
+	res := model.GeminiGenerateContentRes{
+		Candidates:   make([]model.GeminiCandidate, 0),
+		ModelVersion: response.Model,
+	}
+
+	for _, choice := range response.Choices {
+
+		candidate := model.GeminiCandidate{
+			Content: model.GeminiContent{
+				Role:  "model",
+				Parts: make([]model.GeminiPart, 0),
+			},
+			FinishReason: convFinishReasonToGemini(string(choice.FinishReason)),
+			Index:        choice.Index,
+		}
+
+		var (
+			text      string
+			toolCalls []sdkm.ToolCall
+		)
+
+		if choice.Message != nil {
+			text = gconv.String(choice.Message.Content)
+			toolCalls = choice.Message.ToolCalls
+		} else if choice.Delta != nil {
+			text = choice.Delta.Content
+			toolCalls = choice.Delta.ToolCalls
+		}
+
+		if text != "" {
+			candidate.Content.Parts = append(candidate.Content.Parts, model.GeminiPart{Text: text})
+		}
+
+		for _, toolCall := range toolCalls {
+
+			args := make(map[string]interface{})
+			if toolCall.Function.Arguments != "" {
+				_ = gjson.Unmarshal([]byte(toolCall.Function.Arguments), &args)
+			}
+
+			candidate.Content.Parts = append(candidate.Content.Parts, model.GeminiPart{
+				FunctionCall: &model.GeminiFunctionCall{
+					Name: toolCall.Function.Name,
+					Args: args,
+				},
+			})
+		}
+
+		if len(candidate.Content.Parts) == 0 && candidate.FinishReason == "" {
+			continue
+		}
+
+		res.Candidates = append(res.Candidates, candidate)
+	}
+
+	if response.Usage != nil {
+		res.UsageMetadata = &model.GeminiUsageMetadata{
+			PromptTokenCount:     response.Usage.PromptTokens,
+			CandidatesTokenCount: response.Usage.CompletionTokens,
+			TotalTokenCount:      response.Usage.PromptTokens + response.Usage.CompletionTokens,
+		}
+	}
+
+	return res
+}
+
+// Gemini流式响应转换, 工具调用参数为分片返回, 需合并后再输出
+type GeminiStream struct {
+	toolCalls []sdkm.ToolCall
+}
+
+func NewGeminiStream() *GeminiStream {
+	return &GeminiStream{}
+}
+
+// 返回nil表示当前分片无需输出
+func (s *GeminiStream) Conv(response *sdkm.ChatCompletionResponse) *model.GeminiGenerateContentRes {
+
+	chunk := *response
+	chunk.Choices = make([]sdkm.ChatCompletionChoice, 0, len(response.Choices))
+
+	for _, choice := range response.Choices {
+
+		if choice.Delta != nil && len(choice.Delta.ToolCalls) > 0 {
+
+			for _, toolCall := range choice.Delta.ToolCalls {
+
+				index := len(s.toolCalls) - 1
+				if toolCall.Index != nil {
+					index = *toolCall.Index
+				} else if toolCall.ID != "" || index < 0 {
+					index = len(s.toolCalls)
+				}
+
+				for len(s.toolCalls) <= index {
+					s.toolCalls = append(s.toolCalls, sdkm.ToolCall{})
+				}
+
+				if toolCall.ID != "" {
+					s.toolCalls[index].ID = toolCall.ID
+				}
+
+				if toolCall.Function.Name != "" {
+					s.toolCalls[index].Function.Name = toolCall.Function.Name
+				}
+
+				s.toolCalls[index].Function.Arguments += toolCall.Function.Arguments
+			}
+
+			delta := *choice.Delta
+			delta.ToolCalls = nil
+			choice.Delta = &delta
+		}
+
+		if choice.FinishReason != "" && len(s.toolCalls) > 0 && choice.Delta != nil {
+			delta := *choice.Delta
+			delta.ToolCalls = s.toolCalls
+			choice.Delta = &delta
+			s.toolCalls = nil
+		}
+
+		chunk.Choices = append(chunk.Choices, choice)
+	}
+
+	res := ConvChatCompletionResponseToGemini(&chunk)
+	if len(res.Candidates) == 0 && res.UsageMetadata == nil {
+		return nil
+	}
+
+	return &res
+}
+
+func convGeminiContent(content model.GeminiContent) []sdkm.ChatCompletionMessage {
+
+	var (
+		messages     []sdkm.ChatCompletionMessage
+		texts        []string
+		multiContent []interface{}
+		toolCalls    []sdkm.ToolCall
+		isMultimodal bool
+	)
+
+	role := consts.ROLE_USER
+	if content.Role == "model" {
+		role = consts.ROLE_ASSISTANT
+	}
+
+	for _, part := range content.Parts {
+
+		if part.Text != "" {
+			texts = append(texts, part.Text)
+			multiContent = append(multiContent, map[string]interface{}{
+				"type": "text",
+				"text": part.Text,
+			})
+		}
+
+		if part.InlineData != nil {
+			isMultimodal = true
+			multiContent = append(multiContent, map[string]interface{}{
+				"type": "image_url",
+				"image_url": map[string]interface{}{
+					"url": fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data),
+				},
+			})
+		}
+
+		if part.FileData != nil {
+			isMultimodal = true
+			multiContent = append(multiContent, map[string]interface{}{
+				"type": "image_url",
+				"image_url": map[string]interface{}{
+					"url": part.FileData.FileUri,
+				},
+			})
+		}
+
+		// Gemini无调用ID, 使用函数名关联调用与结果
+		if part.FunctionCall != nil {
+			toolCalls = append(toolCalls, sdkm.ToolCall{
+				ID:   part.FunctionCall.Name,
+				Type: "function",
+				Function: sdkm.FunctionCall{
+					Name:      part.FunctionCall.Name,
+					Arguments: gjson.MustEncodeString(part.FunctionCall.Args),
+				},
+			})
+		}
+
+		if part.FunctionResponse != nil {
+			messages = append(messages, sdkm.ChatCompletionMessage{
+				Role:       consts.ROLE_TOOL,
+				Content:    gjson.MustEncodeString(part.FunctionResponse.Response),
+				Name:       part.FunctionResponse.Name,
+				ToolCallID: part.FunctionResponse.Name,
+			})
+		}
+	}
+
+	if len(toolCalls) > 0 {
+		message := sdkm.ChatCompletionMessage{
+			Role:      consts.ROLE_ASSISTANT,
+			ToolCalls: toolCalls,
+		}
+		if len(texts) > 0 {
+			message.Content = strings.Join(texts, "\n")
+		}
+		return append([]sdkm.ChatCompletionMessage{message}, messages...)
+	}
+
+	if isMultimodal {
+		messages = append(messages, sdkm.ChatCompletionMessage{
+			Role:    role,
+			Content: multiContent,
+		})
+	} else if len(texts) > 0 {
+		messages = append(messages, sdkm.ChatCompletionMessage{
+			Role:    role,
+			Content: strings.Join(texts, "\n"),
+		})
+	}
+
+	return messages
+}
+
+func convFinishReasonToGemini(finishReason string) string {
+	switch finishReason {
+	case "":
+		return ""
+	case "length":
+		return "MAX_TOKENS"
+	case "content_filter":
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
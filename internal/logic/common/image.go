@@ -0,0 +1,229 @@
+package common
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/iimeta/fastapi/internal/config"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/internal/model"
+	mcommon "github.com/iimeta/fastapi/internal/model/common"
+	"github.com/iimeta/fastapi/utility/cache"
+	"github.com/iimeta/fastapi/utility/img"
+	"github.com/iimeta/fastapi/utility/logger"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Gemini每张图片固定的令牌数
+const GEMINI_IMAGE_TOKENS = 258
+
+// 计算图片令牌数, 按尺寸计算失败时使用固定额度
+func getImageTokens(ctx context.Context, content map[string]interface{}, reqModel *model.Model) (tokens int, isFixed bool) {
+
+	imageContent, _ := content["image_url"].(map[string]interface{})
+	detail, _ := imageContent["detail"].(string)
+	imageUrl, _ := imageContent["url"].(string)
+
+	multimodalQuota := reqModel.MultimodalQuota
+
+	switch multimodalQuota.ImageBillingMethod {
+	case 4:
+		return GEMINI_IMAGE_TOKENS, false
+	case 2, 3:
+
+		if multimodalQuota.ImageBillingMethod == 2 && detail == "low" {
+			return img.GetOpenAITokens(0, 0, detail), false
+		}
+
+		width, height, err := GetImageSize(ctx, imageUrl, multimodalQuota.ImageFetchSize)
+		if err != nil {
+			logger.Errorf(ctx, "getImageTokens model: %s, url: %s, error: %v", reqModel.Model, gstr.SubStrRune(imageUrl, 0, 64), err)
+			break
+		}
+
+		if multimodalQuota.ImageBillingMethod == 2 {
+			return img.GetOpenAITokens(width, height, detail), false
+		}
+
+		return img.GetClaudeTokens(width, height), false
+	}
+
+	var imageQuota mcommon.ImageQuota
+	for _, quota := range multimodalQuota.ImageQuotas {
+
+		if quota.Mode == detail {
+			imageQuota = quota
+			break
+		}
+
+		if quota.IsDefault {
+			imageQuota = quota
+		}
+	}
+
+	return imageQuota.FixedQuota, true
+}
+
+// 远程图片获取超时时间
+const IMAGE_FETCH_TIMEOUT = 5 * time.Second
+
+// 图片尺寸缓存时长
+const IMAGE_SIZE_CACHE_TTL = time.Hour
+
+// 远程图片尺寸缓存, 按URL缓存, 避免多轮对话中重复获取
+var imageSizeCache = cache.New(10000)
+
+// 运营商级NAT地址段, 不属于公网地址
+var cgnatNet = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// 获取图片尺寸, 仅解析图片头部, base64图片直接解码, 远程图片需配置获取大小上限(KB), 且仅允许访问公网地址
+func GetImageSize(ctx context.Context, imageUrl string, fetchSize int) (width, height int, err error) {
+
+	if gstr.HasPrefix(imageUrl, "data:") {
+
+		index := gstr.Pos(imageUrl, ",")
+		if index == -1 {
+			return 0, 0, errors.New("invalid image data url")
+		}
+
+		return img.DecodeSize(base64.NewDecoder(base64.StdEncoding, strings.NewReader(imageUrl[index+1:])))
+	}
+
+	if fetchSize <= 0 || (!gstr.HasPrefix(imageUrl, "http://") && !gstr.HasPrefix(imageUrl, "https://")) {
+		return 0, 0, errors.New("image fetch disabled")
+	}
+
+	if reply, err := imageSizeCache.Get(ctx, imageUrl); err == nil && reply != nil {
+		if size := reply.Ints(); len(size) == 2 {
+			return size[0], size[1], nil
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, imageUrl, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = checkImageHost(ctx, request.URL.Hostname()); err != nil {
+		return 0, 0, err
+	}
+
+	transport := &http.Transport{
+		TLSHandshakeTimeout: IMAGE_FETCH_TIMEOUT,
+		DisableKeepAlives:   true,
+	}
+
+	if config.Cfg.Http.ProxyUrl != "" {
+
+		proxyUrl, err := url.Parse(config.Cfg.Http.ProxyUrl)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		// 经代理访问时由代理解析域名, 每次请求(含重定向)发出前重新解析校验, 缩小DNS重绑定的时间窗口
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if err := checkImageHost(req.Context(), req.URL.Hostname()); err != nil {
+				return nil, err
+			}
+			return proxyUrl, nil
+		}
+
+	} else {
+		// 直连时校验实际连接的地址, 防止解析后DNS重绑定到内网地址
+		transport.DialContext = (&net.Dialer{
+			Timeout: IMAGE_FETCH_TIMEOUT,
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+					return errors.Newf("image host %s is not allowed", host)
+				}
+				return nil
+			},
+		}).DialContext
+	}
+
+	client := &http.Client{
+		Timeout:   IMAGE_FETCH_TIMEOUT,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return errors.New("image fetch stopped after 3 redirects")
+			}
+			return checkImageHost(req.Context(), req.URL.Hostname())
+		},
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	defer func() {
+		if err := response.Body.Close(); err != nil {
+			logger.Error(ctx, err)
+		}
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return 0, 0, errors.Newf("image fetch status code %d", response.StatusCode)
+	}
+
+	limit := int64(fetchSize) * 1024
+
+	if response.ContentLength > limit {
+		return 0, 0, errors.Newf("image size %d exceeds fetch limit %d", response.ContentLength, limit)
+	}
+
+	if width, height, err = img.DecodeSize(io.LimitReader(response.Body, limit)); err != nil {
+		return 0, 0, err
+	}
+
+	if err := imageSizeCache.Set(ctx, imageUrl, []int{width, height}, IMAGE_SIZE_CACHE_TTL); err != nil {
+		logger.Error(ctx, err)
+	}
+
+	return width, height, nil
+}
+
+// 校验图片地址的主机, 域名解析出的地址须全部为公网地址
+func checkImageHost(ctx context.Context, host string) error {
+
+	if host == "" {
+		return errors.New("invalid image url")
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublicIP(ip) {
+			return errors.Newf("image host %s is not allowed", host)
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return errors.Newf("image host %s resolves to non-public address %s", host, addr.IP)
+		}
+	}
+
+	return nil
+}
+
+// 是否公网地址, 排除私有、回环、链路本地(含169.254.169.254元数据地址)、组播等地址
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || cgnatNet.Contains(ip))
+}
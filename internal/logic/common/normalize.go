@@ -0,0 +1,247 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/gogf/gf/v2/util/gconv"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/model"
+	"github.com/iimeta/fastapi/utility/logger"
+	"slices"
+)
+
+// 消息规范规则
+type normalizeRule struct {
+	isAlternate bool // 要求user/assistant严格交替, 合并连续相同角色的消息
+	isStripName bool // 不支持name字段
+	isStripTool bool // 不支持tool角色, 工具调用和结果转为普通文本
+}
+
+// 各公司消息规范规则
+var normalizeRules = map[string]normalizeRule{
+	consts.CORP_ANTHROPIC:  {isAlternate: true, isStripName: true},
+	consts.CORP_GCP_CLAUDE: {isAlternate: true, isStripName: true},
+	consts.CORP_AWS_CLAUDE: {isAlternate: true, isStripName: true},
+	consts.CORP_BAIDU:      {isAlternate: true, isStripName: true, isStripTool: true},
+	consts.CORP_XFYUN:      {isStripName: true, isStripTool: true},
+	consts.CORP_360AI:      {isStripName: true, isStripTool: true},
+}
+
+// 旧版functions转换为tools, 需在判断是否模拟工具调用前执行
+func NormalizeFunctions(ctx context.Context, model *model.Model, request sdkm.ChatCompletionRequest) sdkm.ChatCompletionRequest {
+
+	if len(request.Functions) == 0 {
+		return request
+	}
+
+	corp := GetCorpCode(ctx, model.Corp)
+
+	// 模拟工具调用仅支持tools
+	if (corp != consts.CORP_OPENAI && corp != consts.CORP_AZURE) || (model.IsEnableEmulate && model.EmulateConfig.IsEmulateTools) {
+		request = convFunctionsToTools(request)
+		logger.Debugf(ctx, "NormalizeFunctions corp: %s, model: %s, converted %d functions to tools", corp, model.Model, len(request.Tools))
+	}
+
+	return request
+}
+
+// 按公司规范请求消息, 避免上游因消息格式拒绝请求
+func NormalizeRequest(ctx context.Context, model *model.Model, request sdkm.ChatCompletionRequest) sdkm.ChatCompletionRequest {
+
+	corp := GetCorpCode(ctx, model.Corp)
+
+	messages := make([]sdkm.ChatCompletionMessage, len(request.Messages))
+	copy(messages, request.Messages)
+
+	// 不支持system角色时, 系统提示词并入首条用户消息
+	if model.IsEnablePresetConfig && !model.PresetConfig.IsSupportSystemRole {
+		if folded := foldSystemMessages(messages); len(folded) != len(messages) {
+			logger.Debugf(ctx, "NormalizeRequest corp: %s, model: %s, folded %d system messages into first user message", corp, model.Model, len(messages)-len(folded))
+			messages = folded
+		}
+	}
+
+	rule, ok := normalizeRules[corp]
+	if !ok {
+		request.Messages = messages
+		return request
+	}
+
+	if rule.isStripTool && slices.ContainsFunc(messages, func(message sdkm.ChatCompletionMessage) bool {
+		return message.Role == consts.ROLE_TOOL || message.Role == consts.ROLE_FUNCTION || len(message.ToolCalls) > 0
+	}) {
+		messages = emulateToolMessages(messages)
+		// 工具调用已转为文本, 不再传递工具定义
+		request.Tools = nil
+		request.ToolChoice = nil
+		request.ParallelToolCalls = nil
+		logger.Debugf(ctx, "NormalizeRequest corp: %s, model: %s, converted tool messages to text", corp, model.Model)
+	}
+
+	if rule.isStripName {
+		for i := range messages {
+			if messages[i].Name != "" && messages[i].Role != consts.ROLE_FUNCTION {
+				logger.Debugf(ctx, "NormalizeRequest corp: %s, model: %s, stripped name of message %d", corp, model.Model, i)
+				messages[i].Name = ""
+			}
+		}
+	}
+
+	if rule.isAlternate {
+		if merged := mergeMessages(messages); len(merged) != len(messages) {
+			logger.Debugf(ctx, "NormalizeRequest corp: %s, model: %s, merged %d consecutive same role messages", corp, model.Model, len(messages)-len(merged))
+			messages = merged
+		}
+	}
+
+	request.Messages = messages
+
+	return request
+}
+
+// functions/function_call转换为tools/tool_choice, 历史消息同步转换
+func convFunctionsToTools(request sdkm.ChatCompletionRequest) sdkm.ChatCompletionRequest {
+
+	for i := range request.Functions {
+		request.Tools = append(request.Tools, sdkm.Tool{
+			Type:     "function",
+			Function: &request.Functions[i],
+		})
+	}
+
+	if name := gjson.New(request.FunctionCall).Get("name").String(); name != "" {
+		request.ToolChoice = map[string]interface{}{
+			"type":     "function",
+			"function": map[string]interface{}{"name": name},
+		}
+	} else if request.FunctionCall != nil {
+		request.ToolChoice = request.FunctionCall
+	}
+
+	request.Functions = nil
+	request.FunctionCall = nil
+
+	messages := make([]sdkm.ChatCompletionMessage, 0, len(request.Messages))
+
+	var callId string
+	for i, message := range request.Messages {
+
+		if message.Role == consts.ROLE_ASSISTANT && message.FunctionCall != nil {
+			callId = fmt.Sprintf("call_%s_%d", message.FunctionCall.Name, i)
+			message.ToolCalls = []sdkm.ToolCall{{
+				ID:       callId,
+				Type:     "function",
+				Function: *message.FunctionCall,
+			}}
+			message.FunctionCall = nil
+		} else if message.Role == consts.ROLE_FUNCTION {
+			message.Role = consts.ROLE_TOOL
+			message.ToolCallID = callId
+			message.Name = ""
+		}
+
+		messages = append(messages, message)
+	}
+
+	request.Messages = messages
+
+	return request
+}
+
+// 系统消息并入首条用户消息
+func foldSystemMessages(messages []sdkm.ChatCompletionMessage) []sdkm.ChatCompletionMessage {
+
+	var (
+		systems  = make([]string, 0)
+		result   = make([]sdkm.ChatCompletionMessage, 0, len(messages))
+		isFolded bool
+	)
+
+	for _, message := range messages {
+		if message.Role == consts.ROLE_SYSTEM {
+			systems = append(systems, gconv.String(message.Content))
+		} else {
+			result = append(result, message)
+		}
+	}
+
+	if len(systems) == 0 {
+		return messages
+	}
+
+	system := gstr.Join(systems, "\n\n") + "\n\n"
+
+	for i := range result {
+		if result[i].Role == consts.ROLE_USER {
+			result[i].Content = prependContent(system, result[i].Content)
+			isFolded = true
+			break
+		}
+	}
+
+	if !isFolded {
+		result = append([]sdkm.ChatCompletionMessage{{
+			Role:    consts.ROLE_USER,
+			Content: system,
+		}}, result...)
+	}
+
+	return result
+}
+
+// 合并连续相同角色的消息, 工具调用相关消息保持不变
+func mergeMessages(messages []sdkm.ChatCompletionMessage) []sdkm.ChatCompletionMessage {
+
+	result := make([]sdkm.ChatCompletionMessage, 0, len(messages))
+
+	for _, message := range messages {
+
+		if len(result) > 0 {
+
+			last := &result[len(result)-1]
+
+			if last.Role == message.Role && (message.Role == consts.ROLE_USER || message.Role == consts.ROLE_ASSISTANT) &&
+				len(last.ToolCalls) == 0 && len(message.ToolCalls) == 0 && last.FunctionCall == nil && message.FunctionCall == nil {
+				last.Content = appendContent(last.Content, message.Content)
+				continue
+			}
+		}
+
+		result = append(result, message)
+	}
+
+	return result
+}
+
+func prependContent(text string, content interface{}) interface{} {
+
+	if parts, ok := content.([]interface{}); ok {
+		return append([]interface{}{map[string]interface{}{"type": "text", "text": text}}, parts...)
+	}
+
+	return text + gconv.String(content)
+}
+
+// 多模态内容按分片追加, 文本内容以空行分隔
+func appendContent(content, next interface{}) interface{} {
+
+	parts, isParts := content.([]interface{})
+	nextParts, isNextParts := next.([]interface{})
+
+	if !isParts && !isNextParts {
+		return gconv.String(content) + "\n\n" + gconv.String(next)
+	}
+
+	if !isParts {
+		parts = []interface{}{map[string]interface{}{"type": "text", "text": gconv.String(content)}}
+	}
+
+	if !isNextParts {
+		nextParts = []interface{}{map[string]interface{}{"type": "text", "text": gconv.String(next)}}
+	}
+
+	return append(append(make([]interface{}, 0, len(parts)+len(nextParts)), parts...), nextParts...)
+}
@@ -0,0 +1,130 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/text/gstr"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/internal/model"
+	"github.com/iimeta/fastapi/utility/logger"
+	"reflect"
+)
+
+// 参数规则动作
+const (
+	PARAM_RULE_DROP    = 1 // 删除
+	PARAM_RULE_RENAME  = 2 // 重命名
+	PARAM_RULE_DEFAULT = 3 // 默认值
+	PARAM_RULE_FORCE   = 4 // 强制值
+	PARAM_RULE_REJECT  = 5 // 拒绝
+)
+
+// 请求结构支持的参数, 规则仅能作用于这些参数, 其它参数无法透传上游
+var chatRequestParams = getJsonFields(reflect.TypeOf(sdkm.ChatCompletionRequest{}))
+
+// 按模型参数规则转换请求, 返回转换后的请求和实际生效的参数(不含消息), 未配置规则时原样返回
+func ApplyParamRules(ctx context.Context, model *model.Model, request sdkm.ChatCompletionRequest) (sdkm.ChatCompletionRequest, map[string]interface{}, error) {
+
+	if len(model.ParamRules) == 0 {
+		return request, nil, nil
+	}
+
+	data := make(map[string]interface{})
+	if err := gjson.Unmarshal(gjson.MustEncode(request), &data); err != nil {
+		logger.Error(ctx, err)
+		return request, nil, err
+	}
+
+	// 结构体序列化会省略零值, Chat接口以请求体判断参数是否显式传入
+	body := getRequestBody(ctx)
+
+	for _, rule := range model.ParamRules {
+
+		// 模型和消息不允许通过规则修改
+		if rule.Param == "" || rule.Param == "model" || rule.Param == "messages" {
+			continue
+		}
+
+		if !chatRequestParams[rule.Param] || (rule.Action == PARAM_RULE_RENAME && !chatRequestParams[rule.Target]) {
+			logger.Errorf(ctx, "ApplyParamRules model: %s, unsupported param rule: %+v", model.Model, rule)
+			continue
+		}
+
+		value, exists := data[rule.Param]
+		if !exists {
+			_, exists = body[rule.Param]
+		}
+
+		switch rule.Action {
+		case PARAM_RULE_DROP:
+			delete(data, rule.Param)
+		case PARAM_RULE_RENAME:
+			if exists && rule.Target != "" {
+				delete(data, rule.Param)
+				if _, ok := data[rule.Target]; !ok && value != nil {
+					data[rule.Target] = value
+				}
+			}
+		case PARAM_RULE_DEFAULT:
+			if !exists {
+				data[rule.Param] = rule.Value
+			}
+		case PARAM_RULE_FORCE:
+			data[rule.Param] = rule.Value
+		case PARAM_RULE_REJECT:
+			if exists {
+				return request, nil, errors.NewError(400, "unsupported_parameter", fmt.Sprintf("Unsupported parameter: '%s' is not supported with this model.", rule.Param), "invalid_request_error")
+			}
+		}
+	}
+
+	result := sdkm.ChatCompletionRequest{}
+	if err := gjson.Unmarshal(gjson.MustEncode(data), &result); err != nil {
+		logger.Error(ctx, err)
+		return request, nil, err
+	}
+
+	// 按实际发送的请求记录生效参数, 零值会被序列化省略
+	params := make(map[string]interface{})
+	if err := gjson.Unmarshal(gjson.MustEncode(result), &params); err != nil {
+		logger.Error(ctx, err)
+		return request, nil, err
+	}
+
+	for _, rule := range model.ParamRules {
+		if rule.Action == PARAM_RULE_FORCE && chatRequestParams[rule.Param] && params[rule.Param] == nil && rule.Value != nil {
+			logger.Errorf(ctx, "ApplyParamRules model: %s, force value of param %s is omitted as zero value: %v", model.Model, rule.Param, rule.Value)
+		}
+	}
+
+	delete(params, "messages")
+
+	return result, params, nil
+}
+
+// 获取原生Chat接口的请求体参数, 其它协议转换的请求返回nil
+func getRequestBody(ctx context.Context) map[string]interface{} {
+
+	if r := g.RequestFromCtx(ctx); r != nil && gstr.HasSuffix(r.URL.Path, "/chat/completions") {
+		return gjson.New(r.GetBody()).Map()
+	}
+
+	return nil
+}
+
+// 获取结构体的json字段名
+func getJsonFields(t reflect.Type) map[string]bool {
+
+	fields := make(map[string]bool)
+
+	for i := 0; i < t.NumField(); i++ {
+		if name := gstr.Split(t.Field(i).Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+
+	return fields
+}
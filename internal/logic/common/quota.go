@@ -1,12 +1,16 @@
 package common
 
 import (
+	"context"
 	"github.com/gogf/gf/v2/net/ghttp"
 	"github.com/gogf/gf/v2/text/gstr"
 	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/iimeta/fastapi/internal/config"
+	"github.com/iimeta/fastapi/internal/consts"
 	"github.com/iimeta/fastapi/internal/errors"
 	"github.com/iimeta/fastapi/internal/model"
 	mcommon "github.com/iimeta/fastapi/internal/model/common"
+	"math"
 )
 
 func GetImageQuota(model *model.Model, size string) (imageQuota mcommon.ImageQuota) {
@@ -66,3 +70,42 @@ func GetMidjourneyQuota(model *model.Model, request *ghttp.Request, path string)
 
 	return mcommon.MidjourneyQuota{}, errors.ERR_PATH_NOT_FOUND
 }
+
+// 批处理请求按折扣倍率计算额度
+func GetBatchQuota(ctx context.Context, model *model.Model, totalTokens int) int {
+
+	if model == nil || ctx.Value(consts.BATCH_ID_KEY) == nil {
+		return totalTokens
+	}
+
+	ratio := model.BatchRatio
+	if ratio == 0 {
+		ratio = config.Cfg.Batch.Ratio
+	}
+
+	if ratio <= 0 {
+		return totalTokens
+	}
+
+	return int(math.Ceil(float64(totalTokens) * ratio))
+}
+
+// 实时会话每轮额度 = 文本额度 + 音频额度 + 固定额度
+func GetRealtimeQuota(realtimeQuota mcommon.RealtimeQuota, usage mcommon.RealtimeUsage) int {
+
+	var totalTokens int
+
+	if realtimeQuota.TextQuota.BillingMethod == 1 {
+		totalTokens += int(math.Ceil(float64(usage.InputTextTokens)*realtimeQuota.TextQuota.PromptRatio + float64(usage.OutputTextTokens)*realtimeQuota.TextQuota.CompletionRatio))
+	} else if usage.InputTextTokens > 0 || usage.OutputTextTokens > 0 {
+		totalTokens += realtimeQuota.TextQuota.FixedQuota
+	}
+
+	if realtimeQuota.AudioQuota.BillingMethod == 1 {
+		totalTokens += int(math.Ceil(float64(usage.InputAudioTokens)*realtimeQuota.AudioQuota.PromptRatio + float64(usage.OutputAudioTokens)*realtimeQuota.AudioQuota.CompletionRatio))
+	} else if usage.InputAudioTokens > 0 || usage.OutputAudioTokens > 0 {
+		totalTokens += realtimeQuota.AudioQuota.FixedQuota
+	}
+
+	return totalTokens + realtimeQuota.FixedQuota
+}
@@ -0,0 +1,217 @@
+package common
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/iimeta/fastapi/internal/config"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/internal/model"
+	"github.com/iimeta/fastapi/utility/logger"
+	"net/http"
+	"time"
+)
+
+type RerankClient struct {
+	corp     string
+	model    string
+	key      string
+	baseURL  string
+	path     string
+	proxyURL string
+}
+
+// 各公司重排序接口默认地址
+var rerankEndpoints = map[string][2]string{
+	consts.CORP_JINA:    {"https://api.jina.ai/v1", "/rerank"},
+	consts.CORP_COHERE:  {"https://api.cohere.com/v1", "/rerank"},
+	consts.CORP_ALIYUN:  {"https://dashscope.aliyuncs.com/api/v1", "/services/rerank/text-rerank/text-rerank"},
+	consts.CORP_ZHIPUAI: {"https://open.bigmodel.cn/api/paas/v4", "/rerank"},
+}
+
+func NewRerankClient(ctx context.Context, model *model.Model, key, baseURL, path string) (*RerankClient, error) {
+
+	client := &RerankClient{
+		corp:     GetCorpCode(ctx, model.Corp),
+		model:    model.Model,
+		key:      key,
+		baseURL:  baseURL,
+		path:     path,
+		proxyURL: config.Cfg.Http.ProxyUrl,
+	}
+
+	if endpoint, ok := rerankEndpoints[client.corp]; ok {
+
+		if client.baseURL == "" {
+			client.baseURL = endpoint[0]
+		}
+
+		if client.path == "" {
+			client.path = endpoint[1]
+		}
+	}
+
+	if client.baseURL == "" {
+		return nil, errors.Newf("rerank baseURL undefined, corp: %s", client.corp)
+	}
+
+	if client.path == "" {
+		client.path = "/rerank"
+	}
+
+	return client, nil
+}
+
+func (c *RerankClient) Rerank(ctx context.Context, request model.RerankReq) (response model.RerankRes, err error) {
+
+	logger.Infof(ctx, "Rerank corp: %s, model: %s, start", c.corp, c.model)
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		response.TotalTime = gtime.TimestampMilli() - now
+		logger.Infof(ctx, "Rerank corp: %s, model: %s, totalTime: %d ms", c.corp, c.model, response.TotalTime)
+	}()
+
+	documents := GetRerankDocuments(request.Documents)
+
+	returnDocuments := request.ReturnDocuments != nil && *request.ReturnDocuments
+
+	var data g.Map
+	if c.corp == consts.CORP_ALIYUN {
+		data = g.Map{
+			"model": c.model,
+			"input": g.Map{
+				"query":     request.Query,
+				"documents": documents,
+			},
+			"parameters": g.Map{
+				"return_documents": returnDocuments,
+			},
+		}
+		if request.TopN > 0 {
+			data["parameters"].(g.Map)["top_n"] = request.TopN
+		}
+	} else {
+		data = g.Map{
+			"model":            c.model,
+			"query":            request.Query,
+			"documents":        documents,
+			"return_documents": returnDocuments,
+		}
+		if request.TopN > 0 {
+			data["top_n"] = request.TopN
+		}
+	}
+
+	client := g.Client().Timeout(config.Cfg.Http.Timeout * time.Second).SetHeaderMap(map[string]string{
+		"Content-Type":  "application/json",
+		"Authorization": "Bearer " + c.key,
+	})
+
+	if c.proxyURL != "" {
+		client.SetProxy(c.proxyURL)
+	}
+
+	res, err := client.Post(ctx, c.baseURL+c.path, data)
+	if err != nil {
+		logger.Errorf(ctx, "Rerank corp: %s, model: %s, error: %v", c.corp, c.model, err)
+		return response, err
+	}
+	defer func() {
+		if err := res.Close(); err != nil {
+			logger.Error(ctx, err)
+		}
+	}()
+
+	bytes := res.ReadAll()
+
+	if res.StatusCode != http.StatusOK {
+		logger.Errorf(ctx, "Rerank corp: %s, model: %s, statusCode: %d, response: %s", c.corp, c.model, res.StatusCode, string(bytes))
+		return response, errors.NewError(res.StatusCode, "rerank_error", string(bytes), "api_error")
+	}
+
+	result := gjson.New(bytes)
+
+	// 阿里云结果在output下
+	results := result.Get("results").Maps()
+	if c.corp == consts.CORP_ALIYUN {
+		results = result.Get("output.results").Maps()
+	}
+
+	response = model.RerankRes{
+		Id:      result.Get("id").String(),
+		Model:   c.model,
+		Results: make([]model.RerankResult, 0, len(results)),
+	}
+
+	if response.Id == "" {
+		response.Id = result.Get("request_id").String()
+	}
+
+	for _, item := range results {
+
+		rerankResult := model.RerankResult{
+			Index:          gconv.Int(item["index"]),
+			RelevanceScore: gconv.Float64(item["relevance_score"]),
+		}
+
+		if returnDocuments {
+			rerankResult.Document = &model.RerankDocument{}
+			if document, ok := item["document"].(map[string]interface{}); ok {
+				rerankResult.Document.Text = gconv.String(document["text"])
+			} else if item["document"] != nil {
+				rerankResult.Document.Text = gconv.String(item["document"])
+			} else if index := rerankResult.Index; index >= 0 && index < len(documents) {
+				rerankResult.Document.Text = documents[index]
+			}
+		}
+
+		response.Results = append(response.Results, rerankResult)
+	}
+
+	totalTokens := result.Get("usage.total_tokens").Int()
+	if totalTokens == 0 {
+		totalTokens = result.Get("meta.tokens.input_tokens").Int()
+	}
+
+	if totalTokens > 0 {
+		response.Usage = &model.RerankUsage{
+			TotalTokens: totalTokens,
+		}
+	}
+
+	return response, nil
+}
+
+// 统一文档格式, 兼容字符串和{"text": ""}
+func GetRerankDocuments(documents []interface{}) []string {
+
+	texts := make([]string, 0, len(documents))
+
+	for _, document := range documents {
+		if value, ok := document.(map[string]interface{}); ok {
+			texts = append(texts, gconv.String(value["text"]))
+		} else {
+			texts = append(texts, gconv.String(document))
+		}
+	}
+
+	return texts
+}
+
+// 上游未返回用量时, 按查询与每个文档组合估算令牌数
+func GetRerankTokens(ctx context.Context, model, query string, documents []string) int {
+
+	queryTokens := GetCompletionTokens(ctx, model, query)
+
+	totalTokens := 0
+	for _, document := range documents {
+		totalTokens += queryTokens + GetCompletionTokens(ctx, model, gstr.Trim(document))
+	}
+
+	return totalTokens
+}
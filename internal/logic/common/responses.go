@@ -0,0 +1,501 @@
+package common
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/util/gconv"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/model"
+	mcommon "github.com/iimeta/fastapi/internal/model/common"
+	"github.com/iimeta/fastapi/utility/util"
+	"strings"
+)
+
+// Responses输入转换为统一的输入项
+func ConvResponsesInput(input interface{}) []mcommon.ResponseItem {
+
+	items := make([]mcommon.ResponseItem, 0)
+
+	if text, ok := input.(string); ok {
+		return append(items, mcommon.ResponseItem{
+			Type:    "message",
+			Role:    consts.ROLE_USER,
+			Content: []mcommon.ResponseContent{{Type: "input_text", Text: text}},
+		})
+	}
+
+	for _, value := range gconv.Interfaces(input) {
+
+		data := gconv.Map(value)
+
+		item := mcommon.ResponseItem{
+			Type:      gconv.String(data["type"]),
+			Id:        gconv.String(data["id"]),
+			Role:      gconv.String(data["role"]),
+			Status:    gconv.String(data["status"]),
+			CallId:    gconv.String(data["call_id"]),
+			Name:      gconv.String(data["name"]),
+			Arguments: gconv.String(data["arguments"]),
+			Output:    gconv.String(data["output"]),
+		}
+
+		if item.Type == "" {
+			item.Type = "message"
+		}
+
+		if item.Type == "message" {
+
+			textType := "input_text"
+			if item.Role == consts.ROLE_ASSISTANT {
+				textType = "output_text"
+			}
+
+			if text, ok := data["content"].(string); ok {
+				item.Content = []mcommon.ResponseContent{{Type: textType, Text: text}}
+			} else {
+				for _, part := range gconv.Maps(data["content"]) {
+
+					content := mcommon.ResponseContent{
+						Type:    gconv.String(part["type"]),
+						Text:    gconv.String(part["text"]),
+						Detail:  gconv.String(part["detail"]),
+						Refusal: gconv.String(part["refusal"]),
+					}
+
+					// 兼容image_url为对象的写法
+					if imageUrl, ok := part["image_url"].(map[string]interface{}); ok {
+						content.ImageUrl = gconv.String(imageUrl["url"])
+					} else {
+						content.ImageUrl = gconv.String(part["image_url"])
+					}
+
+					item.Content = append(item.Content, content)
+				}
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// Responses输入项转换为Chat消息
+func ConvResponseItemsToMessages(items []mcommon.ResponseItem) []sdkm.ChatCompletionMessage {
+
+	messages := make([]sdkm.ChatCompletionMessage, 0)
+
+	for _, item := range items {
+		switch item.Type {
+		case "message":
+
+			var (
+				texts        []string
+				multiContent []interface{}
+				isMultimodal bool
+			)
+
+			for _, content := range item.Content {
+				switch content.Type {
+				case "input_text", "output_text":
+					texts = append(texts, content.Text)
+					multiContent = append(multiContent, map[string]interface{}{
+						"type": "text",
+						"text": content.Text,
+					})
+				case "refusal":
+					texts = append(texts, content.Refusal)
+				case "input_image":
+					isMultimodal = true
+					imageUrl := map[string]interface{}{
+						"url": content.ImageUrl,
+					}
+					if content.Detail != "" {
+						imageUrl["detail"] = content.Detail
+					}
+					multiContent = append(multiContent, map[string]interface{}{
+						"type":      "image_url",
+						"image_url": imageUrl,
+					})
+				}
+			}
+
+			message := sdkm.ChatCompletionMessage{
+				Role: item.Role,
+			}
+
+			if message.Role == "developer" {
+				message.Role = consts.ROLE_SYSTEM
+			}
+
+			if isMultimodal {
+				message.Content = multiContent
+			} else {
+				message.Content = strings.Join(texts, "\n")
+			}
+
+			messages = append(messages, message)
+
+		case "function_call":
+
+			toolCall := sdkm.ToolCall{
+				ID:   item.CallId,
+				Type: "function",
+				Function: sdkm.FunctionCall{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			}
+
+			// 同一轮的多个函数调用合并到一条助手消息
+			if last := len(messages) - 1; last >= 0 && messages[last].Role == consts.ROLE_ASSISTANT {
+				messages[last].ToolCalls = append(messages[last].ToolCalls, toolCall)
+			} else {
+				messages = append(messages, sdkm.ChatCompletionMessage{
+					Role:      consts.ROLE_ASSISTANT,
+					ToolCalls: []sdkm.ToolCall{toolCall},
+				})
+			}
+
+		case "function_call_output":
+			messages = append(messages, sdkm.ChatCompletionMessage{
+				Role:       consts.ROLE_TOOL,
+				Content:    item.Output,
+				ToolCallID: item.CallId,
+			})
+		}
+	}
+
+	return messages
+}
+
+// Responses请求转换为Chat请求
+func ConvResponsesToChatCompletionRequest(params model.ResponsesReq, messages []sdkm.ChatCompletionMessage) sdkm.ChatCompletionRequest {
+
+	request := sdkm.ChatCompletionRequest{
+		Model:     params.Model,
+		Messages:  messages,
+		MaxTokens: params.MaxOutputTokens,
+		Stream:    params.Stream,
+		User:      params.User,
+	}
+
+	if params.Instructions != "" {
+		request.Messages = append([]sdkm.ChatCompletionMessage{{
+			Role:    consts.ROLE_SYSTEM,
+			Content: params.Instructions,
+		}}, request.Messages...)
+	}
+
+	if params.Temperature != nil {
+		request.Temperature = *params.Temperature
+	}
+
+	if params.TopP != nil {
+		request.TopP = *params.TopP
+	}
+
+	for _, tool := range params.Tools {
+		// 仅支持函数调用, 内置工具无法在其它模型上实现
+		if tool.Type == "function" {
+			request.Tools = append(request.Tools, sdkm.Tool{
+				Type: "function",
+				Function: &sdkm.FunctionDefinition{
+					Name:        tool.Name,
+					Description: tool.Description,
+					Parameters:  tool.Parameters,
+				},
+			})
+		}
+	}
+
+	if len(request.Tools) > 0 {
+
+		if params.ParallelToolCalls != nil {
+			request.ParallelToolCalls = *params.ParallelToolCalls
+		}
+
+		if toolChoice, ok := params.ToolChoice.(map[string]interface{}); ok {
+			request.ToolChoice = map[string]interface{}{
+				"type": "function",
+				"function": map[string]interface{}{
+					"name": toolChoice["name"],
+				},
+			}
+		} else if params.ToolChoice != nil {
+			request.ToolChoice = params.ToolChoice
+		}
+	}
+
+	if params.Text != nil && params.Text.Format != nil {
+		switch params.Text.Format.Type {
+		case "json_object":
+			request.ResponseFormat = &sdkm.ChatCompletionResponseFormat{
+				Type: "json_object",
+			}
+		case "json_schema":
+			request.ResponseFormat = &sdkm.ChatCompletionResponseFormat{
+				Type: "json_schema",
+				JSONSchema: &sdkm.ChatCompletionResponseFormatJSONSchema{
+					Name:        params.Text.Format.Name,
+					Description: params.Text.Format.Description,
+					Schema:      params.Text.Format.Schema,
+					Strict:      params.Text.Format.Strict,
+				},
+			}
+		}
+	}
+
+	return request
+}
+
+// Chat响应转换为Responses输出项
+func ConvChatCompletionResponseToResponseItems(response *sdkm.ChatCompletionResponse) []mcommon.ResponseItem {
+
+	items := make([]mcommon.ResponseItem, 0)
+
+	if len(response.Choices) == 0 || response.Choices[0].Message == nil {
+		return items
+	}
+
+	message := response.Choices[0].Message
+
+	if content := gconv.String(message.Content); content != "" {
+		items = append(items, mcommon.ResponseItem{
+			Type:    "message",
+			Id:      "msg_" + util.GenerateId(),
+			Role:    consts.ROLE_ASSISTANT,
+			Status:  "completed",
+			Content: []mcommon.ResponseContent{{Type: "output_text", Text: content}},
+		})
+	}
+
+	for _, toolCall := range message.ToolCalls {
+		items = append(items, mcommon.ResponseItem{
+			Type:      "function_call",
+			Id:        "fc_" + util.GenerateId(),
+			Status:    "completed",
+			CallId:    toolCall.ID,
+			Name:      toolCall.Function.Name,
+			Arguments: toolCall.Function.Arguments,
+		})
+	}
+
+	return items
+}
+
+// Responses流式响应转换, 将Chat分片转换为语义事件
+type ResponsesStream struct {
+	response     *model.Response
+	PromptTokens int
+	sequence     int
+	started      bool
+	messageIndex int
+	calls        map[int]int
+	finishReason string
+}
+
+func NewResponsesStream(response *model.Response) *ResponsesStream {
+	return &ResponsesStream{
+		response:     response,
+		messageIndex: -1,
+		calls:        make(map[int]int),
+	}
+}
+
+// 流式响应结束后的完整响应
+func (s *ResponsesStream) Response() *model.Response {
+	return s.response
+}
+
+func (s *ResponsesStream) Conv(response *sdkm.ChatCompletionResponse) []*model.ResponseStreamEvent {
+
+	events := s.start()
+
+	if len(response.Choices) == 0 {
+		return events
+	}
+
+	choice := response.Choices[0]
+
+	if choice.FinishReason != "" {
+		s.finishReason = string(choice.FinishReason)
+	}
+
+	if choice.Delta == nil {
+		return events
+	}
+
+	if choice.Delta.Content != "" {
+
+		if s.messageIndex < 0 {
+
+			s.response.Output = append(s.response.Output, mcommon.ResponseItem{
+				Type:    "message",
+				Id:      "msg_" + util.GenerateId(),
+				Role:    consts.ROLE_ASSISTANT,
+				Status:  "in_progress",
+				Content: []mcommon.ResponseContent{{Type: "output_text"}},
+			})
+			s.messageIndex = len(s.response.Output) - 1
+
+			item := s.response.Output[s.messageIndex]
+			item.Content = nil
+			events = append(events, s.event(&model.ResponseStreamEvent{Type: "response.output_item.added", OutputIndex: intPtr(s.messageIndex), Item: &item}))
+			events = append(events, s.event(&model.ResponseStreamEvent{Type: "response.content_part.added", OutputIndex: intPtr(s.messageIndex), ItemId: item.Id, ContentIndex: intPtr(0), Part: &mcommon.ResponseContent{Type: "output_text"}}))
+		}
+
+		s.response.Output[s.messageIndex].Content[0].Text += choice.Delta.Content
+
+		delta := choice.Delta.Content
+		events = append(events, s.event(&model.ResponseStreamEvent{Type: "response.output_text.delta", OutputIndex: intPtr(s.messageIndex), ItemId: s.response.Output[s.messageIndex].Id, ContentIndex: intPtr(0), Delta: &delta}))
+	}
+
+	for _, toolCall := range choice.Delta.ToolCalls {
+
+		index := len(s.calls) - 1
+		if toolCall.Index != nil {
+			index = *toolCall.Index
+		} else if toolCall.ID != "" || index < 0 {
+			index = len(s.calls)
+		}
+
+		outputIndex, ok := s.calls[index]
+		if !ok {
+
+			// 函数调用开始前先结束文本消息
+			events = append(events, s.closeMessage()...)
+
+			s.response.Output = append(s.response.Output, mcommon.ResponseItem{
+				Type:   "function_call",
+				Id:     "fc_" + util.GenerateId(),
+				Status: "in_progress",
+				CallId: toolCall.ID,
+				Name:   toolCall.Function.Name,
+			})
+			outputIndex = len(s.response.Output) - 1
+			s.calls[index] = outputIndex
+
+			item := s.response.Output[outputIndex]
+			events = append(events, s.event(&model.ResponseStreamEvent{Type: "response.output_item.added", OutputIndex: intPtr(outputIndex), Item: &item}))
+		}
+
+		if toolCall.Function.Arguments != "" {
+
+			s.response.Output[outputIndex].Arguments += toolCall.Function.Arguments
+
+			delta := toolCall.Function.Arguments
+			events = append(events, s.event(&model.ResponseStreamEvent{Type: "response.function_call_arguments.delta", OutputIndex: intPtr(outputIndex), ItemId: s.response.Output[outputIndex].Id, Delta: &delta}))
+		}
+	}
+
+	return events
+}
+
+// 结束所有输出项并返回完成事件
+func (s *ResponsesStream) Done(ctx context.Context, usage *sdkm.Usage) []*model.ResponseStreamEvent {
+
+	events := s.start()
+	events = append(events, s.closeMessage()...)
+
+	for outputIndex := range s.response.Output {
+
+		item := &s.response.Output[outputIndex]
+		if item.Type != "function_call" || item.Status == "completed" {
+			continue
+		}
+
+		item.Status = "completed"
+
+		arguments := item.Arguments
+		events = append(events, s.event(&model.ResponseStreamEvent{Type: "response.function_call_arguments.done", OutputIndex: intPtr(outputIndex), ItemId: item.Id, Arguments: &arguments}))
+
+		done := *item
+		events = append(events, s.event(&model.ResponseStreamEvent{Type: "response.output_item.done", OutputIndex: intPtr(outputIndex), Item: &done}))
+	}
+
+	s.response.Usage = &mcommon.ResponseUsage{InputTokens: s.PromptTokens}
+
+	if usage != nil && usage.PromptTokens != 0 {
+		s.response.Usage.InputTokens = usage.PromptTokens
+	}
+
+	if usage != nil && usage.CompletionTokens != 0 {
+		s.response.Usage.OutputTokens = usage.CompletionTokens
+	} else {
+		var completion string
+		for _, item := range s.response.Output {
+			completion += item.Arguments
+			for _, content := range item.Content {
+				completion += content.Text
+			}
+		}
+		s.response.Usage.OutputTokens = GetCompletionTokens(ctx, s.response.Model, completion)
+	}
+
+	s.response.Usage.TotalTokens = s.response.Usage.InputTokens + s.response.Usage.OutputTokens
+
+	eventType := "response.completed"
+	s.response.Status = "completed"
+
+	if s.finishReason == "length" {
+		eventType = "response.incomplete"
+		s.response.Status = "incomplete"
+		s.response.IncompleteDetails = map[string]interface{}{"reason": "max_output_tokens"}
+	}
+
+	response := *s.response
+	events = append(events, s.event(&model.ResponseStreamEvent{Type: eventType, Response: &response}))
+
+	return events
+}
+
+func (s *ResponsesStream) start() []*model.ResponseStreamEvent {
+
+	if s.started {
+		return nil
+	}
+
+	s.started = true
+
+	created := *s.response
+	inProgress := *s.response
+
+	return []*model.ResponseStreamEvent{
+		s.event(&model.ResponseStreamEvent{Type: "response.created", Response: &created}),
+		s.event(&model.ResponseStreamEvent{Type: "response.in_progress", Response: &inProgress}),
+	}
+}
+
+func (s *ResponsesStream) closeMessage() []*model.ResponseStreamEvent {
+
+	if s.messageIndex < 0 {
+		return nil
+	}
+
+	outputIndex := s.messageIndex
+	s.messageIndex = -1
+
+	item := &s.response.Output[outputIndex]
+	item.Status = "completed"
+
+	text := item.Content[0].Text
+	part := item.Content[0]
+	done := *item
+
+	return []*model.ResponseStreamEvent{
+		s.event(&model.ResponseStreamEvent{Type: "response.output_text.done", OutputIndex: intPtr(outputIndex), ItemId: item.Id, ContentIndex: intPtr(0), Text: &text}),
+		s.event(&model.ResponseStreamEvent{Type: "response.content_part.done", OutputIndex: intPtr(outputIndex), ItemId: item.Id, ContentIndex: intPtr(0), Part: &part}),
+		s.event(&model.ResponseStreamEvent{Type: "response.output_item.done", OutputIndex: intPtr(outputIndex), Item: &done}),
+	}
+}
+
+func (s *ResponsesStream) event(event *model.ResponseStreamEvent) *model.ResponseStreamEvent {
+	event.SequenceNumber = s.sequence
+	s.sequence++
+	return event
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,192 @@
+package common
+
+import (
+	"fmt"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/gogf/gf/v2/util/gconv"
+	"math"
+	"reflect"
+	"slices"
+)
+
+// 按JSON Schema校验内容, 支持结构化输出常用的关键字
+func ValidateJSONSchema(content string, schema interface{}) error {
+
+	if !gjson.Valid(content) {
+		return fmt.Errorf("response is not valid JSON")
+	}
+
+	value, err := gjson.Decode(content)
+	if err != nil {
+		return err
+	}
+
+	root := gconv.Map(schema)
+
+	return (&schemaValidator{root: root}).validate("$", value, root)
+}
+
+type schemaValidator struct {
+	root map[string]interface{}
+}
+
+func (v *schemaValidator) validate(path string, value interface{}, schema map[string]interface{}) error {
+
+	if len(schema) == 0 {
+		return nil
+	}
+
+	// 仅支持文档内引用, 如#/$defs/xxx
+	if ref := gconv.String(schema["$ref"]); ref != "" {
+
+		if !gstr.HasPrefix(ref, "#/") {
+			return fmt.Errorf("%s: unsupported $ref %s", path, ref)
+		}
+
+		target := v.root
+		for _, name := range gstr.Split(ref[2:], "/") {
+			if target = gconv.Map(target[name]); target == nil {
+				return fmt.Errorf("%s: unresolved $ref %s", path, ref)
+			}
+		}
+
+		return v.validate(path, value, target)
+	}
+
+	if anyOf := gconv.Maps(schema["anyOf"]); len(anyOf) > 0 {
+
+		matched := false
+		for _, sub := range anyOf {
+			if v.validate(path, value, sub) == nil {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return fmt.Errorf("%s: does not match any schema in anyOf", path)
+		}
+	}
+
+	if types := gconv.Strings(schema["type"]); len(types) > 0 {
+		if actual := jsonType(value); !slices.Contains(types, actual) && !(actual == "integer" && slices.Contains(types, "number")) {
+			return fmt.Errorf("%s: expected %s, got %s", path, gstr.Join(types, " or "), actual)
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		if !slices.ContainsFunc(enum, func(item interface{}) bool { return jsonEqual(item, value) }) {
+			return fmt.Errorf("%s: value is not one of the enum values", path)
+		}
+	}
+
+	if constant, ok := schema["const"]; ok && !jsonEqual(constant, value) {
+		return fmt.Errorf("%s: value does not equal const", path)
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+
+		properties := gconv.Map(schema["properties"])
+
+		for _, name := range gconv.Strings(schema["required"]) {
+			if _, ok := value[name]; !ok {
+				return fmt.Errorf("%s: missing required property %s", path, name)
+			}
+		}
+
+		for name, item := range value {
+			if property, ok := properties[name]; ok {
+				if err := v.validate(path+"."+name, item, gconv.Map(property)); err != nil {
+					return err
+				}
+			} else if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				return fmt.Errorf("%s: additional property %s is not allowed", path, name)
+			} else if additional := gconv.Map(schema["additionalProperties"]); additional != nil {
+				if err := v.validate(path+"."+name, item, additional); err != nil {
+					return err
+				}
+			}
+		}
+
+	case []interface{}:
+
+		if min, ok := schema["minItems"]; ok && len(value) < gconv.Int(min) {
+			return fmt.Errorf("%s: expected at least %d items", path, gconv.Int(min))
+		}
+
+		if max, ok := schema["maxItems"]; ok && len(value) > gconv.Int(max) {
+			return fmt.Errorf("%s: expected at most %d items", path, gconv.Int(max))
+		}
+
+		if items := gconv.Map(schema["items"]); items != nil {
+			for i, item := range value {
+				if err := v.validate(fmt.Sprintf("%s[%d]", path, i), item, items); err != nil {
+					return err
+				}
+			}
+		}
+
+	case string:
+
+		if min, ok := schema["minLength"]; ok && len([]rune(value)) < gconv.Int(min) {
+			return fmt.Errorf("%s: expected at least %d characters", path, gconv.Int(min))
+		}
+
+		if max, ok := schema["maxLength"]; ok && len([]rune(value)) > gconv.Int(max) {
+			return fmt.Errorf("%s: expected at most %d characters", path, gconv.Int(max))
+		}
+
+	case bool, nil:
+	default:
+
+		number := gconv.Float64(value)
+
+		if min, ok := schema["minimum"]; ok && number < gconv.Float64(min) {
+			return fmt.Errorf("%s: expected >= %v", path, min)
+		}
+
+		if max, ok := schema["maximum"]; ok && number > gconv.Float64(max) {
+			return fmt.Errorf("%s: expected <= %v", path, max)
+		}
+	}
+
+	return nil
+}
+
+func jsonType(value interface{}) string {
+
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+
+	if number := gconv.Float64(value); number == math.Trunc(number) {
+		return "integer"
+	}
+
+	return "number"
+}
+
+func jsonEqual(a, b interface{}) bool {
+
+	if isNumber(a) || isNumber(b) {
+		return isNumber(a) && isNumber(b) && gconv.Float64(a) == gconv.Float64(b)
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func isNumber(value interface{}) bool {
+	typ := jsonType(value)
+	return typ == "integer" || typ == "number"
+}
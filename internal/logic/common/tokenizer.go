@@ -0,0 +1,180 @@
+package common
+
+import (
+	"github.com/gogf/gf/v2/text/gregex"
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/gogf/gf/v2/util/gconv"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi-sdk/tiktoken"
+	"github.com/iimeta/fastapi/internal/consts"
+	tiktokengo "github.com/iimeta/tiktoken-go"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// 分词器
+type Tokenizer interface {
+	NumTokensFromString(text string) (int, error)
+	NumTokensFromMessages(messages []sdkm.ChatCompletionMessage) (int, error)
+}
+
+type tokenizerRule struct {
+	pattern   string
+	tokenizer Tokenizer
+}
+
+var (
+	tokenizerMu sync.RWMutex
+	// 按模型名称匹配的分词器, 未匹配的模型使用tiktoken
+	// 按从具体到宽泛排列, 首个匹配的生效, 名称中包含多个系列时以发布方为准, 如deepseek-r1-distill-qwen按DeepSeek计算
+	// 公开词表的模型由BPE分词器注册, 此处仅为未公开词表的模型按校准倍率估算
+	tokenizerRules = []tokenizerRule{
+		// 文心一言官方估算规则: 汉字数 + 单词数 * 1.3
+		{`(?i)ernie`, &EstimateTokenizer{CjkRatio: 1, WordRatio: 1.3}},
+		// Anthropic未公开词表, 按cl100k校准, 中文约1字1令牌
+		{`(?i)claude`, &EstimateTokenizer{CjkRatio: 1, TextRatio: 1.15}},
+		// Gemini约4个字符1令牌, 中文约1字1令牌
+		{`(?i)gemini|gemma`, &EstimateTokenizer{CjkRatio: 1, CharRatio: 0.25}},
+	}
+)
+
+// 注册分词器, 后注册的优先匹配
+func RegisterTokenizer(pattern string, tokenizer Tokenizer) {
+
+	tokenizerMu.Lock()
+	defer tokenizerMu.Unlock()
+
+	tokenizerRules = append([]tokenizerRule{{pattern, tokenizer}}, tokenizerRules...)
+}
+
+// 获取模型对应的分词器, 未注册且tiktoken不支持的模型使用默认模型的编码
+func GetTokenizer(model string) Tokenizer {
+
+	tokenizerMu.RLock()
+	defer tokenizerMu.RUnlock()
+
+	for _, rule := range tokenizerRules {
+		if gregex.IsMatchString(rule.pattern, model) {
+			return rule.tokenizer
+		}
+	}
+
+	if tiktokengo.IsEncodingForModel(model) {
+		return &TiktokenTokenizer{Model: model}
+	}
+
+	return &TiktokenTokenizer{Model: consts.DEFAULT_MODEL}
+}
+
+// tiktoken分词器, 用于OpenAI模型
+type TiktokenTokenizer struct {
+	Model string
+}
+
+func (t *TiktokenTokenizer) NumTokensFromString(text string) (int, error) {
+	return tiktoken.NumTokensFromString(t.Model, text)
+}
+
+func (t *TiktokenTokenizer) NumTokensFromMessages(messages []sdkm.ChatCompletionMessage) (int, error) {
+	return tiktoken.NumTokensFromMessages(t.Model, messages)
+}
+
+// 校准估算分词器, 用于未公开词表的模型, 以及公开词表加载失败时的后备
+// 中文按字符计数, 其余文本依次按单词数、字符数或cl100k令牌数乘以倍率估算
+type EstimateTokenizer struct {
+	CjkRatio  float64 // 每个中日韩字符的令牌数
+	WordRatio float64 // 每个单词的令牌数
+	CharRatio float64 // 每个非空白字符的令牌数
+	TextRatio float64 // 相对cl100k令牌数的倍率
+}
+
+func (t *EstimateTokenizer) NumTokensFromString(text string) (int, error) {
+
+	if text == "" {
+		return 0, nil
+	}
+
+	var (
+		cjk   int
+		chars int
+		other strings.Builder
+	)
+
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+			other.WriteRune(' ')
+		} else {
+			if !unicode.IsSpace(r) {
+				chars++
+			}
+			other.WriteRune(r)
+		}
+	}
+
+	tokens := float64(cjk) * t.CjkRatio
+
+	switch {
+	case t.WordRatio > 0:
+		tokens += float64(len(gstr.SplitAndTrim(gstr.Replace(other.String(), "\n", " "), " "))) * t.WordRatio
+	case t.CharRatio > 0:
+		tokens += float64(chars) * t.CharRatio
+	case chars > 0:
+		textTokens, err := tiktoken.NumTokensFromString(consts.DEFAULT_MODEL, other.String())
+		if err != nil {
+			return 0, err
+		}
+		tokens += float64(textTokens) * t.TextRatio
+	}
+
+	return int(math.Ceil(tokens)), nil
+}
+
+func (t *EstimateTokenizer) NumTokensFromMessages(messages []sdkm.ChatCompletionMessage) (int, error) {
+	return numTokensFromMessages(t, messages)
+}
+
+// 按OpenAI的消息计数规则: 每条消息3个令牌, 有name时加1, 回复引导3个令牌
+func numTokensFromMessages(tokenizer Tokenizer, messages []sdkm.ChatCompletionMessage) (int, error) {
+
+	numTokens := 3
+
+	for _, message := range messages {
+
+		numTokens += 3
+
+		for _, text := range []string{message.Role, message.Name, getMessageText(message.Content)} {
+			tokens, err := tokenizer.NumTokensFromString(text)
+			if err != nil {
+				return 0, err
+			}
+			numTokens += tokens
+		}
+
+		if message.Name != "" {
+			numTokens++
+		}
+	}
+
+	return numTokens, nil
+}
+
+// 获取消息中的文本内容, 多模态消息仅计算文本部分
+func getMessageText(content interface{}) string {
+
+	parts, ok := content.([]interface{})
+	if !ok {
+		return gconv.String(content)
+	}
+
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if value, ok := part.(map[string]interface{}); ok && value["type"] == "text" {
+			texts = append(texts, gconv.String(value["text"]))
+		}
+	}
+
+	return gstr.Join(texts, "\n")
+}
@@ -2,22 +2,38 @@ package common
 
 import (
 	"context"
+	"fmt"
 	"github.com/gogf/gf/v2/encoding/gjson"
 	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/text/gstr"
 	"github.com/gogf/gf/v2/util/gconv"
 	sdkm "github.com/iimeta/fastapi-sdk/model"
 	"github.com/iimeta/fastapi-sdk/tiktoken"
 	"github.com/iimeta/fastapi/internal/consts"
 	"github.com/iimeta/fastapi/internal/model"
-	mcommon "github.com/iimeta/fastapi/internal/model/common"
 	"github.com/iimeta/fastapi/utility/logger"
+	"maps"
+	"slices"
 )
 
 func GetPromptTokens(ctx context.Context, model string, messages []sdkm.ChatCompletionMessage) int {
 
 	promptTime := gtime.TimestampMilli()
 
-	promptTokens, err := tiktoken.NumTokensFromMessages(model, messages)
+	// 多模态消息仅计算文本部分, 图片单独计算
+	if slices.ContainsFunc(messages, func(message sdkm.ChatCompletionMessage) bool {
+		_, ok := message.Content.([]interface{})
+		return ok
+	}) {
+		messages = slices.Clone(messages)
+		for i := range messages {
+			if _, ok := messages[i].Content.([]interface{}); ok {
+				messages[i].Content = getMessageText(messages[i].Content)
+			}
+		}
+	}
+
+	promptTokens, err := GetTokenizer(model).NumTokensFromMessages(messages)
 	if err != nil {
 		logger.Errorf(ctx, "GetPromptTokens NumTokensFromMessages model: %s, messages: %s, error: %v", model, gjson.MustEncodeString(messages), err)
 		if promptTokens, err = tiktoken.NumTokensFromMessages(consts.DEFAULT_MODEL, messages); err != nil {
@@ -32,7 +48,7 @@ func GetPromptTokens(ctx context.Context, model string, messages []sdkm.ChatComp
 func GetCompletionTokens(ctx context.Context, model, completion string) int {
 
 	completionTime := gtime.TimestampMilli()
-	completionTokens, err := tiktoken.NumTokensFromString(model, completion)
+	completionTokens, err := GetTokenizer(model).NumTokensFromString(completion)
 	if err != nil {
 		logger.Errorf(ctx, "GetCompletionTokens NumTokensFromString model: %s, completion: %s, error: %v", model, completion, err)
 		if completionTokens, err = tiktoken.NumTokensFromString(consts.DEFAULT_MODEL, completion); err != nil {
@@ -44,45 +60,158 @@ func GetCompletionTokens(ctx context.Context, model, completion string) int {
 	return completionTokens
 }
 
-func GetMultimodalTokens(ctx context.Context, model string, multiContent []interface{}, reqModel *model.Model) (textTokens, imageTokens int) {
+func GetMultimodalTokens(ctx context.Context, model string, messages []sdkm.ChatCompletionMessage, reqModel *model.Model) (textTokens, imageTokens int) {
 
-	for _, value := range multiContent {
+	textTokens = GetPromptTokens(ctx, model, messages)
 
-		content := value.(map[string]interface{})
+	for _, message := range messages {
 
-		if content["type"] == "image_url" {
+		multiContent, ok := message.Content.([]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, value := range multiContent {
+			if content, ok := value.(map[string]interface{}); ok && content["type"] == "image_url" {
+				// 按尺寸计算的图片令牌与文本令牌同样按提示倍率计费
+				if tokens, isFixed := getImageTokens(ctx, content, reqModel); isFixed {
+					imageTokens += tokens
+				} else {
+					textTokens += tokens
+				}
+			}
+		}
+	}
 
-			imageUrl := content["image_url"].(map[string]interface{})
-			detail := imageUrl["detail"]
+	logger.Debugf(ctx, "GetMultimodalTokens model: %s, textTokens: %d, imageTokens: %d", model, textTokens, imageTokens)
 
-			var imageQuota mcommon.ImageQuota
-			for _, quota := range reqModel.MultimodalQuota.ImageQuotas {
+	return textTokens, imageTokens
+}
 
-				if quota.Mode == detail {
-					imageQuota = quota
-					break
-				}
+// 按OpenAI的规则计算工具定义的令牌数: 工具定义转换为TypeScript命名空间计数, 固定加9个令牌, 有系统消息时减4个令牌
+func GetToolsTokens(ctx context.Context, model string, request sdkm.ChatCompletionRequest) int {
 
-				if quota.IsDefault {
-					imageQuota = quota
-				}
-			}
+	functions := slices.Clone(request.Functions)
+	for _, tool := range request.Tools {
+		if tool.Function != nil {
+			functions = append(functions, *tool.Function)
+		}
+	}
+
+	if len(functions) == 0 {
+		return 0
+	}
+
+	toolsTokens := GetCompletionTokens(ctx, model, formatFunctionDefinitions(functions)) + 9
+
+	if slices.ContainsFunc(request.Messages, func(message sdkm.ChatCompletionMessage) bool {
+		return message.Role == consts.ROLE_SYSTEM
+	}) {
+		toolsTokens -= 4
+	}
+
+	return toolsTokens
+}
+
+// 获取回复中需要计算令牌的内容, 包括文本和工具调用
+func GetMessageCompletion(message *sdkm.ChatCompletionMessage) string {
+
+	completion := gconv.String(message.Content)
+
+	if message.FunctionCall != nil {
+		completion += message.FunctionCall.Name + message.FunctionCall.Arguments
+	}
+
+	for _, toolCall := range message.ToolCalls {
+		completion += toolCall.Function.Name + toolCall.Function.Arguments
+	}
+
+	return completion
+}
 
-			imageTokens += imageQuota.FixedQuota
+func formatFunctionDefinitions(functions []sdkm.FunctionDefinition) string {
 
+	lines := []string{"namespace functions {", ""}
+
+	for _, function := range functions {
+
+		if function.Description != "" {
+			lines = append(lines, "// "+function.Description)
+		}
+
+		if parameters := gjson.New(function.Parameters); len(parameters.Get("properties").Map()) > 0 {
+			lines = append(lines, fmt.Sprintf("type %s = (_: {", function.Name), formatObjectProperties(parameters, 0), "}) => any;")
 		} else {
-			contentTime := gtime.TimestampMilli()
-			tokens, err := tiktoken.NumTokensFromString(model, gconv.String(content))
-			if err != nil {
-				logger.Errorf(ctx, "GetMultimodalQuota NumTokensFromString model: %s, content: %s, error: %v", model, gconv.String(content), err)
-				if tokens, err = tiktoken.NumTokensFromString(consts.DEFAULT_MODEL, gconv.String(content)); err != nil {
-					logger.Errorf(ctx, "GetMultimodalQuota NumTokensFromString model: %s, content: %s, error: %v", consts.DEFAULT_MODEL, gconv.String(content), err)
-				}
-			}
-			textTokens += tokens
-			logger.Debugf(ctx, "GetMultimodalQuota NumTokensFromString model: %s, len(content): %d, tokens: %d, time: %d", model, len(gconv.String(content)), tokens, gtime.TimestampMilli()-contentTime)
+			lines = append(lines, fmt.Sprintf("type %s = () => any;", function.Name))
 		}
+
+		lines = append(lines, "")
 	}
 
-	return textTokens, imageTokens
+	lines = append(lines, "} // namespace functions")
+
+	return gstr.Join(lines, "\n")
+}
+
+func formatObjectProperties(object *gjson.Json, indent int) string {
+
+	var (
+		required   = object.Get("required").Strings()
+		properties = object.Get("properties").Map()
+		lines      = make([]string, 0)
+	)
+
+	// 参数已解析为map, 原始顺序不可得, 按名称排序保证同一请求计数稳定
+	for _, name := range slices.Sorted(maps.Keys(properties)) {
+
+		property := gjson.New(properties[name])
+
+		if description := property.Get("description").String(); description != "" && indent < 2 {
+			lines = append(lines, "// "+description)
+		}
+
+		if slices.Contains(required, name) {
+			lines = append(lines, fmt.Sprintf("%s: %s,", name, formatType(property, indent)))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s?: %s,", name, formatType(property, indent)))
+		}
+	}
+
+	for i := range lines {
+		lines[i] = gstr.Repeat(" ", indent) + lines[i]
+	}
+
+	return gstr.Join(lines, "\n")
+}
+
+func formatType(property *gjson.Json, indent int) string {
+
+	switch property.Get("type").String() {
+	case "string":
+		if enum := property.Get("enum").Strings(); len(enum) > 0 {
+			for i := range enum {
+				enum[i] = `"` + enum[i] + `"`
+			}
+			return gstr.Join(enum, " | ")
+		}
+		return "string"
+	case "number", "integer":
+		if enum := property.Get("enum").Strings(); len(enum) > 0 {
+			return gstr.Join(enum, " | ")
+		}
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "null":
+		return "null"
+	case "object":
+		return gstr.Join([]string{"{", formatObjectProperties(property, indent+2), "}"}, "\n")
+	case "array":
+		if items := property.Get("items"); !items.IsNil() {
+			return formatType(gjson.New(items.Val()), indent) + "[]"
+		}
+		return "any[]"
+	default:
+		return "any"
+	}
 }
@@ -0,0 +1,147 @@
+package embedding
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/util/gconv"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/logic/common"
+	"github.com/iimeta/fastapi/internal/model"
+	"github.com/iimeta/fastapi/utility/logger"
+	"github.com/iimeta/fastapi/utility/redis"
+	goredis "github.com/redis/go-redis/v9"
+	"time"
+)
+
+// 缓存默认时长(秒)
+const DEFAULT_CACHE_TTL = 24 * 60 * 60
+
+func getCacheKey(model string, dimensions int, input string) string {
+	hash := sha256.Sum256([]byte(input))
+	return fmt.Sprintf(consts.EMBEDDING_CACHE_KEY, model, dimensions, hex.EncodeToString(hash[:]))
+}
+
+// 查询缓存, 返回命中的向量(按输入位置)
+func (s *sEmbedding) getCache(ctx context.Context, realModel *model.Model, dimensions int, inputs []string) map[int][]float32 {
+
+	hits := make(map[int][]float32)
+
+	if len(inputs) == 0 {
+		return hits
+	}
+
+	keys := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		keys = append(keys, getCacheKey(realModel.Model, dimensions, input))
+	}
+
+	values, err := redis.MGet(ctx, keys...)
+	if err != nil {
+		logger.Error(ctx, err)
+		return hits
+	}
+
+	for i, key := range keys {
+
+		value := values[key]
+		if value == nil || value.IsNil() || value.IsEmpty() {
+			continue
+		}
+
+		embedding, err := common.DecodeEmbedding(value.String())
+		if err != nil {
+			logger.Error(ctx, err)
+			continue
+		}
+
+		hits[i] = embedding
+	}
+
+	return hits
+}
+
+// 写入缓存, 超出最大条数时淘汰最早写入的
+// 索引为按写入时间排序的有序集合, 写入与索引更新批量提交
+func (s *sEmbedding) setCache(ctx context.Context, realModel *model.Model, dimensions int, inputs []string, data []sdkm.Embedding) {
+
+	ttl := int64(realModel.EmbeddingConfig.CacheTtl)
+	if ttl <= 0 {
+		ttl = DEFAULT_CACHE_TTL
+	}
+
+	var (
+		indexKey = fmt.Sprintf(consts.EMBEDDING_CACHE_INDEX_KEY, realModel.Model)
+		now      = float64(gtime.TimestampMilli())
+		members  = make([]goredis.Z, 0, len(data))
+		seen     = make(map[string]bool, len(data))
+		pipe     = redis.Pipeline(ctx)
+	)
+
+	for _, embedding := range data {
+
+		if embedding.Index < 0 || embedding.Index >= len(inputs) || len(embedding.Embedding) == 0 {
+			continue
+		}
+
+		key := getCacheKey(realModel.Model, dimensions, inputs[embedding.Index])
+
+		// 相同输入只写入一次
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+
+		pipe.SetEx(ctx, key, common.EncodeEmbedding(embedding.Embedding), time.Duration(ttl)*time.Second)
+		members = append(members, goredis.Z{Score: now, Member: key})
+	}
+
+	if len(members) == 0 {
+		return
+	}
+
+	maxSize := int64(realModel.EmbeddingConfig.CacheMaxSize)
+
+	var size *goredis.IntCmd
+	if maxSize > 0 {
+		// 已存在的键更新写入时间, 避免淘汰时删除刚刷新的缓存
+		pipe.ZAdd(ctx, indexKey, members...)
+		pipe.Expire(ctx, indexKey, time.Duration(ttl)*time.Second)
+		size = pipe.ZCard(ctx, indexKey)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Error(ctx, err)
+		return
+	}
+
+	if size == nil {
+		return
+	}
+
+	overflow := size.Val() - maxSize
+	if overflow <= 0 {
+		return
+	}
+
+	evicts, err := redis.ZPopMin(ctx, indexKey, overflow)
+	if err != nil {
+		logger.Error(ctx, err)
+		return
+	}
+
+	keys := make([]string, 0, len(evicts))
+	for _, evict := range evicts {
+		keys = append(keys, gconv.String(evict.Member))
+	}
+
+	if len(keys) > 0 {
+		if _, err = redis.Del(ctx, keys...); err != nil {
+			logger.Error(ctx, err)
+		}
+	}
+}
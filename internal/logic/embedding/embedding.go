@@ -7,7 +7,6 @@ import (
 	"github.com/gogf/gf/v2/os/grpool"
 	"github.com/gogf/gf/v2/os/gtime"
 	"github.com/gogf/gf/v2/util/gconv"
-	"github.com/iimeta/fastapi-sdk"
 	sdkm "github.com/iimeta/fastapi-sdk/model"
 	"github.com/iimeta/fastapi/internal/config"
 	"github.com/iimeta/fastapi/internal/dao"
@@ -21,11 +20,15 @@ import (
 	"github.com/iimeta/fastapi/utility/util"
 	"math"
 	"slices"
+	"sync"
 	"time"
 )
 
 type sEmbedding struct{}
 
+// 拆分批次的默认并发请求数
+const DEFAULT_CONCURRENCY = 4
+
 func init() {
 	service.RegisterEmbedding(New())
 }
@@ -43,7 +46,7 @@ func (s *sEmbedding) Embeddings(ctx context.Context, params sdkm.EmbeddingReques
 	}()
 
 	var (
-		client      *sdk.EmbeddingClient
+		client      common.EmbeddingClient
 		reqModel    *model.Model
 		realModel   = new(model.Model)
 		k           *model.Key
@@ -55,6 +58,10 @@ func (s *sEmbedding) Embeddings(ctx context.Context, params sdkm.EmbeddingReques
 		keyTotal    int
 		retryInfo   *mcommon.Retry
 		totalTokens int
+		inputs      []string
+		hits        map[int][]float32
+		misses      []int
+		cacheTokens int
 	)
 
 	defer func() {
@@ -65,11 +72,20 @@ func (s *sEmbedding) Embeddings(ctx context.Context, params sdkm.EmbeddingReques
 		if reqModel != nil && response.Usage != nil {
 			if reqModel.TextQuota.BillingMethod == 1 {
 				totalTokens = int(math.Ceil(float64(response.Usage.PromptTokens)*reqModel.TextQuota.PromptRatio + float64(response.Usage.CompletionTokens)*reqModel.TextQuota.CompletionRatio))
+				// 缓存命中按倍率计费
+				if cacheTokens > 0 {
+					totalTokens += int(math.Ceil(float64(cacheTokens) * reqModel.TextQuota.PromptRatio * realModel.EmbeddingConfig.CacheRatio))
+				}
+			} else if len(hits) > 0 && len(misses) == 0 {
+				totalTokens = int(math.Ceil(float64(reqModel.TextQuota.FixedQuota) * realModel.EmbeddingConfig.CacheRatio))
 			} else {
 				totalTokens = reqModel.TextQuota.FixedQuota
 			}
 		}
 
+		// 批处理折扣
+		totalTokens = common.GetBatchQuota(ctx, reqModel, totalTokens)
+
 		if retryInfo == nil && (err == nil || common.IsAborted(err)) {
 			if err := grpool.Add(gctx.NeverDone(ctx), func(ctx context.Context) {
 				if err := service.Common().RecordUsage(ctx, totalTokens, k.Key); err != nil {
@@ -90,6 +106,8 @@ func (s *sEmbedding) Embeddings(ctx context.Context, params sdkm.EmbeddingReques
 				TotalTime:    response.TotalTime,
 				InternalTime: internalTime,
 				EnterTime:    enterTime,
+				CacheHits:    len(hits),
+				CacheMisses:  len(misses),
 			}
 
 			if retryInfo == nil && response.Usage != nil {
@@ -192,6 +210,9 @@ func (s *sEmbedding) Embeddings(ctx context.Context, params sdkm.EmbeddingReques
 	request := params
 	key = k.Key
 
+	// 统一以浮点数请求上游, 需要base64时由网关编码
+	request.EncodingFormat = ""
+
 	client, err = common.NewEmbeddingClient(ctx, realModel, key, baseUrl, path)
 	if err != nil {
 		logger.Error(ctx, err)
@@ -210,7 +231,46 @@ func (s *sEmbedding) Embeddings(ctx context.Context, params sdkm.EmbeddingReques
 		return response, err
 	}
 
-	response, err = client.Embeddings(ctx, request)
+	if realModel.EmbeddingConfig.IsEnableCache {
+		inputs = common.GetEmbeddingInputs(request.Input)
+	}
+
+	// 命中缓存的输入不再请求上游, 仅转发未命中部分, 令牌数组等非字符串输入不使用缓存
+	if len(inputs) > 0 {
+
+		hits = s.getCache(ctx, realModel, request.Dimensions, inputs)
+
+		missInputs := make([]string, 0, len(inputs))
+		for i, input := range inputs {
+			if _, ok := hits[i]; !ok {
+				misses = append(misses, i)
+				missInputs = append(missInputs, input)
+			} else if realModel.EmbeddingConfig.CacheRatio > 0 {
+				cacheTokens += common.GetCompletionTokens(ctx, realModel.Model, input)
+			}
+		}
+
+		if len(hits) > 0 {
+			request.Input = missInputs
+		}
+	}
+
+	if len(hits) > 0 && len(misses) == 0 {
+		response = sdkm.EmbeddingResponse{
+			Object: "list",
+			Model:  realModel.Model,
+			Data:   make([]sdkm.Embedding, 0),
+			Usage:  new(sdkm.Usage),
+		}
+	} else if batches := common.SplitEmbeddingInputs(ctx, realModel, request.Input); len(batches) > 1 {
+		var errKey *model.Key
+		if response, errKey, err = s.batchEmbeddings(ctx, realModel, modelAgent, client, k, baseUrl, path, request, batches); err != nil && errKey != nil {
+			k = errKey
+		}
+	} else {
+		response, err = client.Embeddings(ctx, request)
+	}
+
 	if err != nil {
 		logger.Error(ctx, err)
 
@@ -259,9 +319,179 @@ func (s *sEmbedding) Embeddings(ctx context.Context, params sdkm.EmbeddingReques
 		return response, err
 	}
 
+	if len(inputs) > 0 {
+
+		if len(misses) > 0 {
+
+			missInputs := common.GetEmbeddingInputs(request.Input)
+			data := response.Data
+
+			if err := grpool.AddWithRecover(gctx.NeverDone(ctx), func(ctx context.Context) {
+				s.setCache(ctx, realModel, request.Dimensions, missInputs, data)
+			}, nil); err != nil {
+				logger.Error(ctx, err)
+			}
+		}
+
+		if len(hits) > 0 {
+			response.Data = mergeCache(response.Data, hits, misses)
+		}
+	}
+
 	return response, nil
 }
 
+// 合并缓存命中的向量, 上游结果的索引换算回原请求中的位置
+func mergeCache(data []sdkm.Embedding, hits map[int][]float32, misses []int) []sdkm.Embedding {
+
+	embeddings := make([]sdkm.Embedding, 0, len(hits)+len(data))
+
+	for _, embedding := range data {
+		if embedding.Index >= 0 && embedding.Index < len(misses) {
+			embedding.Index = misses[embedding.Index]
+			embeddings = append(embeddings, embedding)
+		}
+	}
+
+	for index, embedding := range hits {
+		embeddings = append(embeddings, sdkm.Embedding{
+			Object:    "embedding",
+			Embedding: embedding,
+			Index:     index,
+		})
+	}
+
+	slices.SortFunc(embeddings, func(a, b sdkm.Embedding) int {
+		return a.Index - b.Index
+	})
+
+	return embeddings
+}
+
+// 拆分后的批次并发请求, 首批使用已选中的密钥, 其余批次从密钥池中另选, 按原顺序合并结果和用量
+func (s *sEmbedding) batchEmbeddings(ctx context.Context, realModel *model.Model, modelAgent *model.ModelAgent, client common.EmbeddingClient, k *model.Key, baseUrl, path string, request sdkm.EmbeddingRequest, batches [][]string) (response sdkm.EmbeddingResponse, errKey *model.Key, err error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		response.TotalTime = gtime.TimestampMilli() - now
+		logger.Debugf(ctx, "sEmbedding batchEmbeddings batches: %d, time: %d", len(batches), response.TotalTime)
+	}()
+
+	concurrency := realModel.EmbeddingConfig.Concurrency
+	if concurrency <= 0 {
+		concurrency = DEFAULT_CONCURRENCY
+	}
+
+	var (
+		responses = make([]sdkm.EmbeddingResponse, len(batches))
+		keys      = make([]*model.Key, len(batches))
+		errs      = make([]error, len(batches))
+		offsets   = make([]int, len(batches))
+		wg        sync.WaitGroup
+		limiter   = make(chan struct{}, concurrency)
+	)
+
+	for i := 1; i < len(batches); i++ {
+		offsets[i] = offsets[i-1] + len(batches[i-1])
+	}
+
+	for i, batch := range batches {
+
+		keys[i] = k
+
+		if i > 0 {
+
+			var (
+				key *model.Key
+				err error
+			)
+
+			if realModel.IsEnableModelAgent && modelAgent != nil {
+				_, key, err = service.ModelAgent().PickModelAgentKey(ctx, modelAgent)
+			} else {
+				_, key, err = service.Key().PickModelKey(ctx, realModel)
+			}
+
+			if err != nil {
+				logger.Error(ctx, err)
+			} else {
+				keys[i] = key
+			}
+		}
+
+		wg.Add(1)
+		limiter <- struct{}{}
+
+		go func(i int, batch []string) {
+			defer func() {
+				// 单个分批异常不影响进程, 按该分批失败处理
+				if r := recover(); r != nil {
+					logger.Errorf(ctx, "sEmbedding batchEmbeddings batch: %d, panic: %v", i, r)
+					errs[i] = errors.Newf("batch %d panic: %v", i, r)
+				}
+				<-limiter
+				wg.Done()
+			}()
+
+			batchClient := client
+			if keys[i] != k {
+				if batchClient, errs[i] = common.NewEmbeddingClient(ctx, realModel, keys[i].Key, baseUrl, path); errs[i] != nil {
+					return
+				}
+			}
+
+			batchRequest := request
+			batchRequest.Input = batch
+
+			responses[i], errs[i] = batchClient.Embeddings(ctx, batchRequest)
+
+		}(i, batch)
+	}
+
+	wg.Wait()
+
+	response = sdkm.EmbeddingResponse{
+		Object: "list",
+		Data:   make([]sdkm.Embedding, 0),
+		Usage:  new(sdkm.Usage),
+	}
+
+	for i := range batches {
+
+		if errs[i] != nil {
+			logger.Errorf(ctx, "sEmbedding batchEmbeddings batch: %d, error: %v", i, errs[i])
+			return response, keys[i], errs[i]
+		}
+
+		if response.Model == "" {
+			response.Model = responses[i].Model
+		}
+
+		// 批次内索引换算为原请求中的位置
+		for _, embedding := range responses[i].Data {
+			embedding.Index += offsets[i]
+			response.Data = append(response.Data, embedding)
+		}
+
+		if responses[i].Usage != nil {
+			response.Usage.PromptTokens += responses[i].Usage.PromptTokens
+			response.Usage.CompletionTokens += responses[i].Usage.CompletionTokens
+			response.Usage.TotalTokens += responses[i].Usage.TotalTokens
+		}
+	}
+
+	slices.SortFunc(response.Data, func(a, b sdkm.Embedding) int {
+		return a.Index - b.Index
+	})
+
+	return response, nil, nil
+}
+
+// 向量转换为base64编码输出
+func (s *sEmbedding) ConvBase64(ctx context.Context, response sdkm.EmbeddingResponse) model.EmbeddingBase64Res {
+	return common.ConvEmbeddingBase64(response)
+}
+
 // 保存日志
 func (s *sEmbedding) SaveLog(ctx context.Context, reqModel, realModel, fallbackModel *model.Model, key *model.Key, completionsReq *sdkm.EmbeddingRequest, completionsRes *model.CompletionsRes, retryInfo *mcommon.Retry, retry ...int) {
 
@@ -338,6 +568,8 @@ func (s *sEmbedding) SaveLog(ctx context.Context, reqModel, realModel, fallbackM
 	chat.PromptTokens = completionsRes.Usage.PromptTokens
 	chat.CompletionTokens = completionsRes.Usage.CompletionTokens
 	chat.TotalTokens = completionsRes.Usage.TotalTokens
+	chat.CacheHits = completionsRes.CacheHits
+	chat.CacheMisses = completionsRes.CacheMisses
 
 	if fallbackModel != nil {
 		chat.IsEnableFallback = true
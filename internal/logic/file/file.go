@@ -0,0 +1,303 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/os/gfile"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/iimeta/fastapi/api/file/v1"
+	"github.com/iimeta/fastapi/internal/config"
+	"github.com/iimeta/fastapi/internal/dao"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/internal/model"
+	"github.com/iimeta/fastapi/internal/model/do"
+	"github.com/iimeta/fastapi/internal/model/entity"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/db"
+	"github.com/iimeta/fastapi/utility/logger"
+	"github.com/iimeta/fastapi/utility/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+)
+
+type sFile struct{}
+
+// 默认上传文件大小上限, 单位MB
+const DEFAULT_MAX_FILE_SIZE = 20
+
+func init() {
+	service.RegisterFile(New())
+}
+
+func New() service.IFile {
+	return &sFile{}
+}
+
+// 上传文件
+func (s *sFile) Upload(ctx context.Context, params *v1.UploadReq) (*model.File, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sFile Upload time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	if params.Purpose != "batch" {
+		return nil, errors.ERR_INVALID_PARAMETER
+	}
+
+	maxFileSize := config.Cfg.Batch.MaxFileSize
+	if maxFileSize <= 0 {
+		maxFileSize = DEFAULT_MAX_FILE_SIZE
+	}
+	maxFileSize *= 1024 * 1024
+
+	if params.File.Size > maxFileSize {
+		return nil, errors.NewError(413, "file_too_large", fmt.Sprintf("File size exceeds the maximum of %d bytes.", maxFileSize), "invalid_request_error")
+	}
+
+	reader, err := params.File.Open()
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxFileSize+1))
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	if int64(len(data)) > maxFileSize {
+		return nil, errors.NewError(413, "file_too_large", fmt.Sprintf("File size exceeds the maximum of %d bytes.", maxFileSize), "invalid_request_error")
+	}
+
+	return s.Create(ctx, &model.File{
+		Filename: params.File.Filename,
+		Purpose:  params.Purpose,
+		UserId:   service.Session().GetUserId(ctx),
+		AppId:    service.Session().GetAppId(ctx),
+		Creator:  service.Session().GetSecretKey(ctx),
+	}, data)
+}
+
+// 创建文件
+func (s *sFile) Create(ctx context.Context, file *model.File, data []byte) (*model.File, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sFile Create time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	storage := config.Cfg.Batch.Storage
+	if storage == "" {
+		storage = "local"
+	}
+
+	path := util.GenerateId()
+
+	switch storage {
+	case "gridfs":
+		if err := (&db.GridFS{Database: db.DefaultDatabase}).Upload(ctx, path, file.Filename, data); err != nil {
+			logger.Error(ctx, err)
+			return nil, err
+		}
+	default:
+		path = gfile.Join(getLocalPath(), path)
+		if err := gfile.PutBytes(path, data); err != nil {
+			logger.Error(ctx, err)
+			return nil, err
+		}
+	}
+
+	id, err := dao.File.Insert(ctx, &do.File{
+		UserId:   file.UserId,
+		AppId:    file.AppId,
+		Filename: file.Filename,
+		Purpose:  file.Purpose,
+		Bytes:    int64(len(data)),
+		Storage:  storage,
+		Path:     path,
+		Status:   1,
+		Creator:  file.Creator,
+	})
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	return s.GetFile(ctx, id)
+}
+
+// 文件详情
+func (s *sFile) Retrieve(ctx context.Context, fileId string) (*model.File, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sFile Retrieve time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	file, err := s.GetFile(ctx, fileId)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	if file.UserId != service.Session().GetUserId(ctx) || file.AppId != service.Session().GetAppId(ctx) {
+		return nil, errors.ERR_FILE_NOT_FOUND
+	}
+
+	return file, nil
+}
+
+// 文件列表
+func (s *sFile) List(ctx context.Context, purpose string) (*model.FileListRes, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sFile List time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	filter := bson.M{
+		"user_id": service.Session().GetUserId(ctx),
+		"app_id":  service.Session().GetAppId(ctx),
+		"status":  1,
+	}
+
+	if purpose != "" {
+		filter["purpose"] = purpose
+	}
+
+	results, err := dao.File.Find(ctx, filter, "-created_at")
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	items := make([]*model.File, 0)
+	for _, result := range results {
+		items = append(items, toModel(result))
+	}
+
+	return &model.FileListRes{
+		Object: "list",
+		Data:   items,
+	}, nil
+}
+
+// 删除文件
+func (s *sFile) Delete(ctx context.Context, fileId string) (*model.FileDeleteRes, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sFile Delete time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	file, err := s.Retrieve(ctx, fileId)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	if err = dao.File.UpdateById(ctx, file.Id, bson.M{
+		"status": -1,
+	}); err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	switch file.Storage {
+	case "gridfs":
+		err = (&db.GridFS{Database: db.DefaultDatabase}).Delete(ctx, file.Path)
+	default:
+		err = gfile.Remove(file.Path)
+	}
+
+	if err != nil {
+		logger.Error(ctx, err)
+	}
+
+	return &model.FileDeleteRes{
+		Id:      file.Id,
+		Object:  "file",
+		Deleted: true,
+	}, nil
+}
+
+// 文件内容
+func (s *sFile) Content(ctx context.Context, fileId string) ([]byte, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sFile Content time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	file, err := s.Retrieve(ctx, fileId)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	return s.ReadContent(ctx, file)
+}
+
+// 根据文件ID获取文件信息
+func (s *sFile) GetFile(ctx context.Context, fileId string) (*model.File, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sFile GetFile time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	result, err := dao.File.FindOne(ctx, bson.M{"_id": fileId, "status": 1})
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, errors.ERR_FILE_NOT_FOUND
+	}
+
+	return toModel(result), nil
+}
+
+// 读取文件内容
+func (s *sFile) ReadContent(ctx context.Context, file *model.File) ([]byte, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sFile ReadContent time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	switch file.Storage {
+	case "gridfs":
+		return (&db.GridFS{Database: db.DefaultDatabase}).Download(ctx, file.Path)
+	default:
+		if !gfile.Exists(file.Path) {
+			return nil, errors.ERR_FILE_NOT_FOUND
+		}
+		return gfile.GetBytes(file.Path), nil
+	}
+}
+
+func getLocalPath() string {
+
+	if config.Cfg.Batch.LocalPath != "" {
+		return config.Cfg.Batch.LocalPath
+	}
+
+	return "./resource/files"
+}
+
+func toModel(result *entity.File) *model.File {
+	return &model.File{
+		Id:        result.Id,
+		Object:    "file",
+		Bytes:     result.Bytes,
+		CreatedAt: result.CreatedAt / 1000,
+		Filename:  result.Filename,
+		Purpose:   result.Purpose,
+		UserId:    result.UserId,
+		AppId:     result.AppId,
+		Storage:   result.Storage,
+		Path:      result.Path,
+		Creator:   result.Creator,
+	}
+}
@@ -0,0 +1,75 @@
+package gemini
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/internal/logic/common"
+	"github.com/iimeta/fastapi/internal/model"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/logger"
+)
+
+type sGemini struct{}
+
+func init() {
+	service.RegisterGemini(New())
+}
+
+func New() service.IGemini {
+	return &sGemini{}
+}
+
+// GenerateContent
+func (s *sGemini) GenerateContent(ctx context.Context, reqModel string, params model.GeminiGenerateContentReq) (response model.GeminiGenerateContentRes, err error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sGemini GenerateContent time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	if len(params.Contents) == 0 {
+		return response, errors.ERR_INVALID_PARAMETER
+	}
+
+	common.SetTemperatureFlag(ctx, params.GenerationConfig != nil && params.GenerationConfig.Temperature != nil)
+
+	// 计费和日志与Chat保持一致
+	res, err := service.Chat().Completions(ctx, common.ConvGeminiToChatCompletionRequest(reqModel, params), nil)
+	if err != nil {
+		logger.Error(ctx, err)
+		return response, err
+	}
+
+	return common.ConvChatCompletionResponseToGemini(&res), nil
+}
+
+// StreamGenerateContent
+func (s *sGemini) StreamGenerateContent(ctx context.Context, reqModel string, params model.GeminiGenerateContentReq) (err error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sGemini StreamGenerateContent time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	if len(params.Contents) == 0 {
+		return errors.ERR_INVALID_PARAMETER
+	}
+
+	request := common.ConvGeminiToChatCompletionRequest(reqModel, params)
+	request.Stream = true
+
+	common.SetTemperatureFlag(ctx, params.GenerationConfig != nil && params.GenerationConfig.Temperature != nil)
+
+	// 以Gemini原生格式输出流式响应
+	g.RequestFromCtx(ctx).SetCtxVar(consts.PROTOCOL_KEY, consts.PROTOCOL_GEMINI)
+
+	if err = service.Chat().CompletionsStream(ctx, request, nil); err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/gogf/gf/v2/text/gstr"
 	"github.com/iimeta/fastapi-sdk"
 	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/config"
 	"github.com/iimeta/fastapi/internal/dao"
 	"github.com/iimeta/fastapi/internal/errors"
 	"github.com/iimeta/fastapi/internal/logic/common"
@@ -256,6 +257,19 @@ func (s *sImage) Generations(ctx context.Context, params sdkm.ImageRequest, fall
 		return response, err
 	}
 
+	// 转存图像, 返回签名地址
+	if config.Cfg.Storage.Open {
+		for i, data := range response.Data {
+			if data.URL != "" {
+				if objectId, err := service.Storage().Rehost(ctx, data.URL); err != nil {
+					logger.Error(ctx, err)
+				} else {
+					response.Data[i].URL = service.Storage().SignUrl(ctx, objectId)
+				}
+			}
+		}
+	}
+
 	return response, nil
 }
 
@@ -8,17 +8,24 @@ import (
 	_ "github.com/iimeta/fastapi/internal/logic/app"
 	_ "github.com/iimeta/fastapi/internal/logic/audio"
 	_ "github.com/iimeta/fastapi/internal/logic/auth"
+	_ "github.com/iimeta/fastapi/internal/logic/batch"
 	_ "github.com/iimeta/fastapi/internal/logic/chat"
 	_ "github.com/iimeta/fastapi/internal/logic/common"
 	_ "github.com/iimeta/fastapi/internal/logic/corp"
 	_ "github.com/iimeta/fastapi/internal/logic/dashboard"
 	_ "github.com/iimeta/fastapi/internal/logic/embedding"
+	_ "github.com/iimeta/fastapi/internal/logic/file"
+	_ "github.com/iimeta/fastapi/internal/logic/gemini"
 	_ "github.com/iimeta/fastapi/internal/logic/image"
 	_ "github.com/iimeta/fastapi/internal/logic/key"
 	_ "github.com/iimeta/fastapi/internal/logic/midjourney"
 	_ "github.com/iimeta/fastapi/internal/logic/model"
 	_ "github.com/iimeta/fastapi/internal/logic/model_agent"
 	_ "github.com/iimeta/fastapi/internal/logic/realtime"
+	_ "github.com/iimeta/fastapi/internal/logic/rerank"
+	_ "github.com/iimeta/fastapi/internal/logic/response"
 	_ "github.com/iimeta/fastapi/internal/logic/session"
+	_ "github.com/iimeta/fastapi/internal/logic/storage"
+	_ "github.com/iimeta/fastapi/internal/logic/token"
 	_ "github.com/iimeta/fastapi/internal/logic/user"
 )
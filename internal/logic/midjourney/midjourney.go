@@ -10,6 +10,7 @@ import (
 	"github.com/gogf/gf/v2/os/grpool"
 	"github.com/gogf/gf/v2/os/gtime"
 	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/gogf/gf/v2/util/gconv"
 	"github.com/iimeta/fastapi-sdk"
 	sdkm "github.com/iimeta/fastapi-sdk/model"
 	"github.com/iimeta/fastapi/internal/config"
@@ -22,6 +23,7 @@ import (
 	"github.com/iimeta/fastapi/internal/service"
 	"github.com/iimeta/fastapi/utility/logger"
 	"github.com/iimeta/fastapi/utility/util"
+	"go.mongodb.org/mongo-driver/bson"
 	"net/http"
 	"time"
 )
@@ -60,6 +62,9 @@ func (s *sMidjourney) Submit(ctx context.Context, request *ghttp.Request, fallba
 		reqUrl          = request.RequestURI
 		taskId          string
 		prompt          = request.GetMapStrStr()["prompt"]
+		body            []byte
+		notifyHook      string
+		state           string
 	)
 
 	if model := request.GetRouterMap()["model"]; model != "" {
@@ -197,8 +202,11 @@ func (s *sMidjourney) Submit(ctx context.Context, request *ghttp.Request, fallba
 
 	key = k.Key
 
+	// 任务状态由网关接收后再推送给客户端
+	body, notifyHook, state = s.injectNotifyHook(ctx, request.GetBody())
+
 	client := sdk.NewMidjourneyClient(ctx, baseUrl, midjourneyQuota.Path, key, config.Cfg.Midjourney.MidjourneyProxy.ApiSecretHeader, request.Method, config.Cfg.Http.ProxyUrl)
-	response, err = client.Request(ctx, request.GetBody())
+	response, err = client.Request(ctx, body)
 	if err != nil {
 		logger.Error(ctx, err)
 
@@ -253,7 +261,22 @@ func (s *sMidjourney) Submit(ctx context.Context, request *ghttp.Request, fallba
 		return response, err
 	}
 
-	taskId = data["result"].(string)
+	taskId = gconv.String(data["result"])
+
+	// 提交成功或排队中
+	if code := gconv.Int(data["code"]); taskId != "" && (code == 1 || code == 22) {
+		s.saveTask(ctx, &do.MidjourneyTask{
+			TaskId:      taskId,
+			Model:       reqModel.Model,
+			Key:         key,
+			BaseUrl:     baseUrl,
+			Action:      midjourneyQuota.Action,
+			Prompt:      prompt,
+			State:       state,
+			NotifyHook:  notifyHook,
+			TotalTokens: midjourneyQuota.FixedQuota,
+		})
+	}
 
 	return response, nil
 }
@@ -474,7 +497,7 @@ func (s *sMidjourney) Task(ctx context.Context, request *ghttp.Request, fallback
 		return response, err
 	}
 
-	imageUrl = data["imageUrl"].(string)
+	imageUrl = gconv.String(data["imageUrl"])
 
 	// 替换图片CDN地址
 	if config.Cfg.Midjourney.CdnUrl != "" && config.Cfg.Midjourney.MidjourneyProxy.CdnOriginalUrl != "" && imageUrl != "" {
@@ -488,6 +511,27 @@ func (s *sMidjourney) Task(ctx context.Context, request *ghttp.Request, fallback
 		}
 	}
 
+	// 未配置回调时, 通过查询结果同步任务状态
+	task := new(model.MidjourneyTask)
+	if err := gjson.Unmarshal(response.Response, task); err == nil && task.Id != "" {
+
+		if err := s.updateTask(ctx, task); err != nil {
+			logger.Error(ctx, err)
+		}
+
+		// 已转存的图像返回签名地址
+		if result, err := dao.MidjourneyTask.FindOne(ctx, bson.M{"task_id": task.Id}); err == nil && result.ObjectId != "" {
+
+			imageUrl = service.Storage().SignUrl(ctx, result.ObjectId)
+			data["imageUrl"] = imageUrl
+
+			if response.Response, err = gjson.Marshal(data); err != nil {
+				logger.Error(ctx, err)
+				return response, err
+			}
+		}
+	}
+
 	return response, nil
 }
 
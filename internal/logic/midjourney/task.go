@@ -0,0 +1,462 @@
+package midjourney
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/gogf/gf/v2/os/grpool"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/iimeta/fastapi-sdk"
+	"github.com/iimeta/fastapi/internal/config"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/dao"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/internal/logic/common"
+	"github.com/iimeta/fastapi/internal/model"
+	"github.com/iimeta/fastapi/internal/model/do"
+	"github.com/iimeta/fastapi/internal/model/entity"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/crypto"
+	"github.com/iimeta/fastapi/utility/logger"
+	"github.com/iimeta/fastapi/utility/redis"
+	"go.mongodb.org/mongo-driver/bson"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// 退还记录过期时间, 单位秒
+const REFUND_EXPIRE = 7 * 24 * 60 * 60
+
+// 任务最终状态
+var finalStatus = []string{"SUCCESS", "FAILURE", "CANCEL"}
+
+// 启动未结束任务同步, 回调丢失或客户端不再查询时, 主动查询任务状态, 失败的任务退还额度
+func (s *sMidjourney) Start(ctx context.Context) {
+
+	interval := config.Cfg.Midjourney.SyncInterval * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	after := config.Cfg.Midjourney.SyncAfter * time.Minute
+	if after <= 0 {
+		after = 10 * time.Minute
+	}
+
+	if err := grpool.AddWithRecover(ctx, func(ctx context.Context) {
+		for {
+
+			time.Sleep(interval)
+
+			now := gtime.TimestampMilli()
+
+			// 超过退还记录有效期的任务不再同步
+			results, err := dao.MidjourneyTask.Find(ctx, bson.M{
+				"status":      bson.M{"$nin": finalStatus},
+				"submit_time": bson.M{"$gt": now - REFUND_EXPIRE*1000, "$lt": now - after.Milliseconds()},
+			})
+			if err != nil {
+				logger.Error(ctx, err)
+				continue
+			}
+
+			for _, result := range results {
+
+				task, err := s.fetchTask(ctx, result)
+				if err != nil {
+					logger.Error(ctx, err)
+					continue
+				}
+
+				if err = s.updateTask(ctx, task); err != nil {
+					logger.Error(ctx, err)
+				}
+			}
+		}
+	}, nil); err != nil {
+		panic(err)
+	}
+}
+
+// 任务回调
+func (s *sMidjourney) Notify(ctx context.Context, request *ghttp.Request) error {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sMidjourney Notify time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	// 未配置回调校验密钥时不接受回调, 通过查询结果同步任务状态
+	if config.Cfg.Midjourney.NotifySecret == "" || request.GetQuery("secret").String() != config.Cfg.Midjourney.NotifySecret {
+		return errors.ERR_NOT_AUTHORIZED
+	}
+
+	taskId := gjson.New(request.GetBody()).Get("id").String()
+	if taskId == "" {
+		return errors.ERR_INVALID_PARAMETER
+	}
+
+	result, err := dao.MidjourneyTask.FindOne(ctx, bson.M{"task_id": taskId})
+	if err != nil {
+		// 非本网关提交的任务
+		logger.Infof(ctx, "sMidjourney Notify taskId: %s, not found", taskId)
+		return nil
+	}
+
+	// 回调内容不可信, 以Midjourney-Proxy查询的任务状态为准
+	task, err := s.fetchTask(ctx, result)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return s.updateTask(ctx, task)
+}
+
+// 从提交任务的Midjourney-Proxy查询任务
+func (s *sMidjourney) fetchTask(ctx context.Context, result *entity.MidjourneyTask) (*model.MidjourneyTask, error) {
+
+	baseUrl := result.BaseUrl
+	if baseUrl == "" {
+		baseUrl = config.Cfg.Midjourney.MidjourneyProxy.ApiBaseUrl
+	}
+
+	client := sdk.NewMidjourneyClient(ctx, baseUrl, fmt.Sprintf("/task/%s/fetch", result.TaskId), result.Key, config.Cfg.Midjourney.MidjourneyProxy.ApiSecretHeader, http.MethodGet, config.Cfg.Http.ProxyUrl)
+	response, err := client.Request(ctx, nil)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	task := new(model.MidjourneyTask)
+	if err = gjson.Unmarshal(response.Response, task); err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	if task.Id != result.TaskId {
+		return nil, errors.Newf("fetch task %s returned task %s", result.TaskId, task.Id)
+	}
+
+	return task, nil
+}
+
+// 任务详情
+func (s *sMidjourney) Fetch(ctx context.Context, taskId string) (*model.MidjourneyTask, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sMidjourney Fetch time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	result, err := dao.MidjourneyTask.FindOne(ctx, bson.M{
+		"task_id": taskId,
+		"user_id": service.Session().GetUserId(ctx),
+		"app_id":  service.Session().GetAppId(ctx),
+	})
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, errors.ERR_TASK_NOT_FOUND
+	}
+
+	imageUrl := result.ImageUrl
+	if result.ObjectId != "" {
+		imageUrl = service.Storage().SignUrl(ctx, result.ObjectId)
+	}
+
+	return &model.MidjourneyTask{
+		Id:          result.TaskId,
+		Action:      result.Action,
+		Prompt:      result.Prompt,
+		PromptEn:    result.PromptEn,
+		Description: result.Description,
+		State:       result.State,
+		Status:      result.Status,
+		Progress:    result.Progress,
+		ImageUrl:    imageUrl,
+		FailReason:  result.FailReason,
+		SubmitTime:  result.SubmitTime,
+		StartTime:   result.StartTime,
+		FinishTime:  result.FinishTime,
+		Properties:  result.Properties,
+		Buttons:     result.Buttons,
+	}, nil
+}
+
+// 注入网关回调地址, 返回客户端原始回调地址
+func (s *sMidjourney) injectNotifyHook(ctx context.Context, body []byte) ([]byte, string, string) {
+
+	if len(body) == 0 {
+		return body, "", ""
+	}
+
+	data := make(map[string]interface{})
+	if err := gjson.Unmarshal(body, &data); err != nil {
+		return body, "", ""
+	}
+
+	notifyHook := gconv.String(data["notifyHook"])
+	state := gconv.String(data["state"])
+
+	if config.Cfg.Midjourney.NotifyUrl == "" {
+		return body, notifyHook, state
+	}
+
+	data["notifyHook"] = config.Cfg.Midjourney.NotifyUrl
+	if config.Cfg.Midjourney.NotifySecret != "" {
+		data["notifyHook"] = fmt.Sprintf("%s?secret=%s", config.Cfg.Midjourney.NotifyUrl, config.Cfg.Midjourney.NotifySecret)
+	}
+
+	bytes, err := gjson.Marshal(data)
+	if err != nil {
+		logger.Error(ctx, err)
+		return body, notifyHook, state
+	}
+
+	return bytes, notifyHook, state
+}
+
+// 更新任务状态, 状态变化时推送给客户端, 失败或取消时退还额度
+func (s *sMidjourney) updateTask(ctx context.Context, task *model.MidjourneyTask) error {
+
+	result, err := dao.MidjourneyTask.FindOne(ctx, bson.M{"task_id": task.Id})
+	if err != nil {
+		// 非本网关提交的任务
+		logger.Infof(ctx, "sMidjourney updateTask taskId: %s, not found", task.Id)
+		return nil
+	}
+
+	// 已结束或未变化的任务不再更新, 避免乱序回调覆盖最终状态
+	if slices.Contains(finalStatus, result.Status) || (task.Status == result.Status && task.Progress == result.Progress) {
+		return nil
+	}
+
+	// 替换图片CDN地址
+	if config.Cfg.Midjourney.CdnUrl != "" && config.Cfg.Midjourney.MidjourneyProxy.CdnOriginalUrl != "" && task.ImageUrl != "" {
+		task.ImageUrl = gstr.Replace(task.ImageUrl, config.Cfg.Midjourney.MidjourneyProxy.CdnOriginalUrl, config.Cfg.Midjourney.CdnUrl)
+	}
+
+	if err = dao.MidjourneyTask.UpdateOne(ctx, bson.M{"_id": result.Id, "status": bson.M{"$nin": finalStatus}}, bson.M{
+		"prompt_en":   task.PromptEn,
+		"description": task.Description,
+		"status":      task.Status,
+		"progress":    task.Progress,
+		"image_url":   task.ImageUrl,
+		"fail_reason": task.FailReason,
+		"start_time":  task.StartTime,
+		"finish_time": task.FinishTime,
+		"properties":  task.Properties,
+		"buttons":     task.Buttons,
+	}); err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	// 转存图像, 对外返回签名地址
+	if config.Cfg.Storage.Open && task.Status == "SUCCESS" && task.ImageUrl != "" {
+		if result.ObjectId, err = s.rehost(ctx, result, task.ImageUrl); err != nil {
+			logger.Error(ctx, err)
+		} else {
+			task.ImageUrl = service.Storage().SignUrl(ctx, result.ObjectId)
+		}
+	}
+
+	if (task.Status == "FAILURE" || task.Status == "CANCEL") && result.TotalTokens > 0 {
+		if err = s.refund(ctx, result, task); err != nil {
+			logger.Error(ctx, err)
+		}
+	}
+
+	// 回调地址优先使用提交时指定的, 其次使用应用配置的
+	notifyHook := result.NotifyHook
+	if notifyHook == "" {
+		if app, err := service.App().GetCacheApp(ctx, result.AppId); err == nil && app != nil {
+			notifyHook = app.NotifyHook
+		}
+	}
+
+	if notifyHook != "" {
+
+		// 对外保持提交时的动作和提示
+		task.Action = result.Action
+		task.Prompt = result.Prompt
+		task.State = result.State
+
+		if err = grpool.AddWithRecover(gctx.NeverDone(ctx), func(ctx context.Context) {
+			s.notify(ctx, notifyHook, result.Creator, task)
+		}, nil); err != nil {
+			logger.Error(ctx, err)
+		}
+	}
+
+	return nil
+}
+
+// 退还额度, 按提交时的扣费路径反向记录用户、应用和密钥额度
+func (s *sMidjourney) refund(ctx context.Context, result *entity.MidjourneyTask, task *model.MidjourneyTask) error {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sMidjourney refund time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	if result.RefundQuota > 0 {
+		return nil
+	}
+
+	// 防止并发回调重复退还
+	refundKey := fmt.Sprintf(consts.MIDJOURNEY_REFUND_KEY, result.TaskId)
+	if ok, err := redis.SetNX(ctx, refundKey, result.TotalTokens); err != nil || !ok {
+		return err
+	}
+
+	if _, err := redis.Expire(ctx, refundKey, REFUND_EXPIRE); err != nil {
+		logger.Error(ctx, err)
+	}
+
+	ctx, err := restoreSession(ctx, result.Creator)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	app, err := service.App().GetCacheApp(ctx, result.AppId)
+	if err != nil || app == nil {
+		if app, err = service.App().GetApp(ctx, result.AppId); err != nil {
+			logger.Error(ctx, err)
+			return err
+		}
+	}
+
+	key, err := service.App().GetCacheAppKey(ctx, result.Creator)
+	if err != nil || key == nil {
+		if key, err = service.Key().GetKey(ctx, result.Creator); err != nil {
+			logger.Error(ctx, err)
+			return err
+		}
+	}
+
+	service.Session().SaveIsLimitQuota(ctx, app.IsLimitQuota, key.IsLimitQuota)
+
+	if err = service.Common().RecordUsage(ctx, -result.TotalTokens, result.Key); err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	reason := task.FailReason
+	if reason == "" {
+		reason = task.Status
+	}
+
+	logger.Infof(ctx, "sMidjourney refund taskId: %s, status: %s, refundQuota: %d, reason: %s", result.TaskId, task.Status, result.TotalTokens, reason)
+
+	if err = dao.MidjourneyTask.UpdateById(ctx, result.Id, bson.M{
+		"refund_quota": result.TotalTokens,
+	}); err != nil {
+		logger.Error(ctx, err)
+	}
+
+	// 记录到提交日志
+	if err = dao.Midjourney.UpdateOne(ctx, bson.M{"task_id": result.TaskId}, bson.M{
+		"refund_quota":  result.TotalTokens,
+		"refund_reason": reason,
+		"refund_at":     gtime.TimestampMilli(),
+	}); err != nil {
+		logger.Error(ctx, err)
+	}
+
+	return nil
+}
+
+// 转存任务图像
+func (s *sMidjourney) rehost(ctx context.Context, result *entity.MidjourneyTask, imageUrl string) (string, error) {
+
+	ctx, err := restoreSession(ctx, result.Creator)
+	if err != nil {
+		logger.Error(ctx, err)
+		return "", err
+	}
+
+	objectId, err := service.Storage().Rehost(ctx, imageUrl)
+	if err != nil {
+		logger.Error(ctx, err)
+		return "", err
+	}
+
+	if err = dao.MidjourneyTask.UpdateById(ctx, result.Id, bson.M{
+		"object_id": objectId,
+	}); err != nil {
+		logger.Error(ctx, err)
+	}
+
+	return objectId, nil
+}
+
+// 保存任务
+func (s *sMidjourney) saveTask(ctx context.Context, task *do.MidjourneyTask) {
+
+	task.UserId = service.Session().GetUserId(ctx)
+	task.AppId = service.Session().GetAppId(ctx)
+	task.Status = "SUBMITTED"
+	task.SubmitTime = gtime.TimestampMilli()
+
+	if _, err := dao.MidjourneyTask.Insert(ctx, task); err != nil {
+		logger.Error(ctx, err)
+	}
+}
+
+// 推送任务状态, 签名为HmacSHA256(密钥, 时间戳.请求体)
+func (s *sMidjourney) notify(ctx context.Context, notifyHook, secretKey string, task *model.MidjourneyTask) {
+
+	body, err := gjson.Marshal(task)
+	if err != nil {
+		logger.Error(ctx, err)
+		return
+	}
+
+	timestamp := gconv.String(gtime.Timestamp())
+
+	client := g.Client().Timeout(config.Cfg.Http.Timeout * time.Second).SetHeaderMap(map[string]string{
+		"Content-Type":        "application/json",
+		"X-Fastapi-Timestamp": timestamp,
+		"X-Fastapi-Signature": crypto.HmacSHA256(secretKey, timestamp+"."+string(body)),
+	})
+
+	res, err := client.Post(ctx, notifyHook, body)
+	if err != nil {
+		logger.Errorf(ctx, "sMidjourney notify taskId: %s, notifyHook: %s, error: %v", task.Id, notifyHook, err)
+		return
+	}
+	defer func() {
+		if err := res.Close(); err != nil {
+			logger.Error(ctx, err)
+		}
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		logger.Errorf(ctx, "sMidjourney notify taskId: %s, notifyHook: %s, statusCode: %d", task.Id, notifyHook, res.StatusCode)
+	}
+}
+
+// 按任务提交人创建独立的会话上下文, 回调或轮询请求中执行, 不能覆盖当前请求的会话
+func restoreSession(ctx context.Context, secretKey string) (context.Context, error) {
+
+	r, err := common.NewInternalRequest(gctx.New(), http.MethodPost, "/mj/notify", nil)
+	if err != nil {
+		return ctx, err
+	}
+
+	if err = service.Session().Save(r.GetCtx(), secretKey); err != nil {
+		return ctx, err
+	}
+
+	logger.Infof(ctx, "sMidjourney restoreSession traceId: %s", gctx.CtxId(r.GetCtx()))
+
+	return r.GetCtx(), nil
+}
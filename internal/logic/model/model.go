@@ -62,6 +62,8 @@ func (s *sModel) GetModel(ctx context.Context, m string) (*model.Model, error) {
 		Path:                 result.Path,
 		IsEnablePresetConfig: result.IsEnablePresetConfig,
 		PresetConfig:         result.PresetConfig,
+		ParamRules:           result.ParamRules,
+		ContextLength:        result.ContextLength,
 		TextQuota:            result.TextQuota,
 		ImageQuotas:          result.ImageQuotas,
 		AudioQuota:           result.AudioQuota,
@@ -76,6 +78,14 @@ func (s *sModel) GetModel(ctx context.Context, m string) (*model.Model, error) {
 		ForwardConfig:        result.ForwardConfig,
 		IsEnableFallback:     result.IsEnableFallback,
 		FallbackConfig:       result.FallbackConfig,
+		BatchRatio:           result.BatchRatio,
+		EmbeddingConfig:      result.EmbeddingConfig,
+		IsEnableCache:        result.IsEnableCache,
+		CacheConfig:          result.CacheConfig,
+		IsEnableJsonSchema:   result.IsEnableJsonSchema,
+		JsonSchemaConfig:     result.JsonSchemaConfig,
+		IsEnableEmulate:      result.IsEnableEmulate,
+		EmulateConfig:        result.EmulateConfig,
 		Remark:               result.Remark,
 		Status:               result.Status,
 	}, nil
@@ -105,6 +115,8 @@ func (s *sModel) GetModelById(ctx context.Context, id string) (*model.Model, err
 		Path:                 result.Path,
 		IsEnablePresetConfig: result.IsEnablePresetConfig,
 		PresetConfig:         result.PresetConfig,
+		ParamRules:           result.ParamRules,
+		ContextLength:        result.ContextLength,
 		TextQuota:            result.TextQuota,
 		ImageQuotas:          result.ImageQuotas,
 		AudioQuota:           result.AudioQuota,
@@ -119,6 +131,14 @@ func (s *sModel) GetModelById(ctx context.Context, id string) (*model.Model, err
 		ForwardConfig:        result.ForwardConfig,
 		IsEnableFallback:     result.IsEnableFallback,
 		FallbackConfig:       result.FallbackConfig,
+		BatchRatio:           result.BatchRatio,
+		EmbeddingConfig:      result.EmbeddingConfig,
+		IsEnableCache:        result.IsEnableCache,
+		CacheConfig:          result.CacheConfig,
+		IsEnableJsonSchema:   result.IsEnableJsonSchema,
+		JsonSchemaConfig:     result.JsonSchemaConfig,
+		IsEnableEmulate:      result.IsEnableEmulate,
+		EmulateConfig:        result.EmulateConfig,
 		Remark:               result.Remark,
 		Status:               result.Status,
 	}, nil
@@ -515,6 +535,8 @@ func (s *sModel) List(ctx context.Context, ids []string) ([]*model.Model, error)
 			Path:                 result.Path,
 			IsEnablePresetConfig: result.IsEnablePresetConfig,
 			PresetConfig:         result.PresetConfig,
+			ParamRules:           result.ParamRules,
+			ContextLength:        result.ContextLength,
 			TextQuota:            result.TextQuota,
 			ImageQuotas:          result.ImageQuotas,
 			AudioQuota:           result.AudioQuota,
@@ -529,6 +551,14 @@ func (s *sModel) List(ctx context.Context, ids []string) ([]*model.Model, error)
 			ForwardConfig:        result.ForwardConfig,
 			IsEnableFallback:     result.IsEnableFallback,
 			FallbackConfig:       result.FallbackConfig,
+			BatchRatio:           result.BatchRatio,
+			EmbeddingConfig:      result.EmbeddingConfig,
+			IsEnableCache:        result.IsEnableCache,
+			CacheConfig:          result.CacheConfig,
+			IsEnableJsonSchema:   result.IsEnableJsonSchema,
+			JsonSchemaConfig:     result.JsonSchemaConfig,
+			IsEnableEmulate:      result.IsEnableEmulate,
+			EmulateConfig:        result.EmulateConfig,
 			Remark:               result.Remark,
 			Status:               result.Status,
 			CreatedAt:            result.CreatedAt,
@@ -566,6 +596,8 @@ func (s *sModel) ListAll(ctx context.Context) ([]*model.Model, error) {
 			Path:                 result.Path,
 			IsEnablePresetConfig: result.IsEnablePresetConfig,
 			PresetConfig:         result.PresetConfig,
+			ParamRules:           result.ParamRules,
+			ContextLength:        result.ContextLength,
 			TextQuota:            result.TextQuota,
 			ImageQuotas:          result.ImageQuotas,
 			AudioQuota:           result.AudioQuota,
@@ -580,6 +612,14 @@ func (s *sModel) ListAll(ctx context.Context) ([]*model.Model, error) {
 			ForwardConfig:        result.ForwardConfig,
 			IsEnableFallback:     result.IsEnableFallback,
 			FallbackConfig:       result.FallbackConfig,
+			BatchRatio:           result.BatchRatio,
+			EmbeddingConfig:      result.EmbeddingConfig,
+			IsEnableCache:        result.IsEnableCache,
+			CacheConfig:          result.CacheConfig,
+			IsEnableJsonSchema:   result.IsEnableJsonSchema,
+			JsonSchemaConfig:     result.JsonSchemaConfig,
+			IsEnableEmulate:      result.IsEnableEmulate,
+			EmulateConfig:        result.EmulateConfig,
 			Remark:               result.Remark,
 			Status:               result.Status,
 			CreatedAt:            result.CreatedAt,
@@ -777,6 +817,8 @@ func (s *sModel) UpdateCacheModel(ctx context.Context, oldData *entity.Model, ne
 		Path:                 newData.Path,
 		IsEnablePresetConfig: newData.IsEnablePresetConfig,
 		PresetConfig:         newData.PresetConfig,
+		ParamRules:           newData.ParamRules,
+		ContextLength:        newData.ContextLength,
 		TextQuota:            newData.TextQuota,
 		ImageQuotas:          newData.ImageQuotas,
 		AudioQuota:           newData.AudioQuota,
@@ -791,6 +833,14 @@ func (s *sModel) UpdateCacheModel(ctx context.Context, oldData *entity.Model, ne
 		ForwardConfig:        newData.ForwardConfig,
 		IsEnableFallback:     newData.IsEnableFallback,
 		FallbackConfig:       newData.FallbackConfig,
+		BatchRatio:           newData.BatchRatio,
+		EmbeddingConfig:      newData.EmbeddingConfig,
+		IsEnableCache:        newData.IsEnableCache,
+		CacheConfig:          newData.CacheConfig,
+		IsEnableJsonSchema:   newData.IsEnableJsonSchema,
+		JsonSchemaConfig:     newData.JsonSchemaConfig,
+		IsEnableEmulate:      newData.IsEnableEmulate,
+		EmulateConfig:        newData.EmulateConfig,
 		Status:               newData.Status,
 	}); err != nil {
 		logger.Error(ctx, err)
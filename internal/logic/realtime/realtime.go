@@ -2,6 +2,7 @@ package audio
 
 import (
 	"context"
+	"github.com/gogf/gf/v2/encoding/gjson"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/net/ghttp"
 	"github.com/gogf/gf/v2/os/gctx"
@@ -11,16 +12,21 @@ import (
 	"github.com/gorilla/websocket"
 	sdk "github.com/iimeta/fastapi-sdk"
 	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/config"
 	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/dao"
 	"github.com/iimeta/fastapi/internal/errors"
 	"github.com/iimeta/fastapi/internal/logic/common"
 	"github.com/iimeta/fastapi/internal/model"
 	mcommon "github.com/iimeta/fastapi/internal/model/common"
+	"github.com/iimeta/fastapi/internal/model/do"
 	"github.com/iimeta/fastapi/internal/service"
 	"github.com/iimeta/fastapi/utility/logger"
-	"github.com/iimeta/tiktoken-go"
+	"github.com/iimeta/fastapi/utility/util"
 	"io"
-	"math"
+	"slices"
+	"sync"
+	"time"
 )
 
 type sRealtime struct {
@@ -58,122 +64,58 @@ func (s *sRealtime) Realtime(ctx context.Context, r *ghttp.Request, params model
 	}
 
 	var (
-		client      *sdk.RealtimeClient
-		reqModel    *model.Model
-		realModel   = new(model.Model)
-		k           *model.Key
-		modelAgent  *model.ModelAgent
-		key         string
-		baseUrl     string
-		path        string
-		completion  string
-		agentTotal  int
-		keyTotal    int
-		connTime    int64
-		duration    int64
-		totalTime   int64
-		textTokens  int
-		imageTokens int
-		totalTokens int
-		usage       *sdkm.Usage
-		retryInfo   *mcommon.Retry
+		client     *sdk.RealtimeClient
+		reqModel   *model.Model
+		realModel  = new(model.Model)
+		k          *model.Key
+		modelAgent *model.ModelAgent
+		key        string
+		baseUrl    string
+		path       string
+		prompt     string
+		completion string
+		agentTotal int
+		keyTotal   int
+		connTime   int64
+		totalTime  int64
+		usages     []mcommon.RealtimeUsage
+		mutex      sync.Mutex
+		retryInfo  *mcommon.Retry
+		// 上游转发协程中发生的错误, 由mutex保护
+		upstreamErr error
 	)
 
 	defer func() {
 
 		enterTime := g.RequestFromCtx(ctx).EnterTime.TimestampMilli()
-		internalTime := gtime.TimestampMilli() - enterTime - totalTime
-
-		if err := grpool.Add(gctx.NeverDone(ctx), func(ctx context.Context) {
-			if retryInfo == nil && completion != "" && (usage == nil || usage.PromptTokens == 0 || usage.CompletionTokens == 0) {
-
-				if usage == nil {
-					usage = new(sdkm.Usage)
-				}
-
-				model := reqModel.Model
-				if !tiktoken.IsEncodingForModel(model) {
-					model = consts.DEFAULT_MODEL
-				}
-
-				//if content, ok := params.Messages[len(params.Messages)-1].Content.([]interface{}); ok {
-				//	textTokens, imageTokens = common.GetMultimodalTokens(ctx, model, content, reqModel)
-				//	usage.PromptTokens = textTokens + imageTokens
-				//} else {
-				if usage.PromptTokens == 0 {
-					usage.PromptTokens = common.GetPromptTokens(ctx, model, params.Messages)
-				}
-				//}
-
-				if usage.CompletionTokens == 0 {
-					usage.CompletionTokens = common.GetCompletionTokens(ctx, model, completion)
-				}
-
-				if reqModel.Type == 100 { // 多模态
-					usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
-					totalTokens = imageTokens + int(math.Ceil(float64(textTokens)*reqModel.MultimodalQuota.TextQuota.PromptRatio)) + int(math.Ceil(float64(usage.CompletionTokens)*reqModel.MultimodalQuota.TextQuota.CompletionRatio))
-				} else {
-					if reqModel.TextQuota.BillingMethod == 1 {
-						usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
-						totalTokens = int(math.Ceil(float64(usage.PromptTokens)*reqModel.TextQuota.PromptRatio + float64(usage.CompletionTokens)*reqModel.TextQuota.CompletionRatio))
-					} else {
-						usage.TotalTokens = reqModel.TextQuota.FixedQuota
-						totalTokens = reqModel.TextQuota.FixedQuota
-					}
-				}
-
-			} else if retryInfo == nil && usage != nil {
 
-				if reqModel.Type == 100 { // 多模态
-					usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
-					totalTokens = int(math.Ceil(float64(usage.PromptTokens)*reqModel.MultimodalQuota.TextQuota.PromptRatio)) + int(math.Ceil(float64(usage.CompletionTokens)*reqModel.MultimodalQuota.TextQuota.CompletionRatio))
-				} else {
-					if reqModel.TextQuota.BillingMethod == 1 {
-						usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
-						totalTokens = int(math.Ceil(float64(usage.PromptTokens)*reqModel.TextQuota.PromptRatio + float64(usage.CompletionTokens)*reqModel.TextQuota.CompletionRatio))
-					} else {
-						usage.TotalTokens = reqModel.TextQuota.FixedQuota
-						totalTokens = reqModel.TextQuota.FixedQuota
-					}
-				}
-			}
-
-			if retryInfo == nil && (err == nil || common.IsAborted(err)) {
-				if err := grpool.Add(ctx, func(ctx context.Context) {
-					if err := service.Common().RecordUsage(ctx, totalTokens, k.Key); err != nil {
-						logger.Error(ctx, err)
-						panic(err)
-					}
-				}); err != nil {
-					logger.Error(ctx, err)
-				}
-			}
-
-			if err := grpool.Add(ctx, func(ctx context.Context) {
-
-				realModel.ModelAgent = modelAgent
+		mutex.Lock()
+		internalTime := gtime.TimestampMilli() - enterTime - totalTime
+		realtimeRes := &model.RealtimeRes{
+			Prompt:       prompt,
+			Completion:   completion,
+			Usages:       slices.Clone(usages),
+			Error:        err,
+			ConnTime:     connTime,
+			Duration:     gtime.TimestampMilli() - now, // 会话时长
+			TotalTime:    totalTime,
+			InternalTime: internalTime,
+			EnterTime:    enterTime,
+		}
+		mutex.Unlock()
 
-				completionsRes := &model.CompletionsRes{
-					Completion:   completion,
-					Error:        err,
-					ConnTime:     connTime,
-					Duration:     duration,
-					TotalTime:    totalTime,
-					InternalTime: internalTime,
-					EnterTime:    enterTime,
-				}
+		// 汇总每轮用量, 额度已在每轮结束时扣除
+		for _, usage := range realtimeRes.Usages {
+			realtimeRes.Usage.PromptTokens += usage.InputTextTokens + usage.InputAudioTokens
+			realtimeRes.Usage.CompletionTokens += usage.OutputTextTokens + usage.OutputAudioTokens
+			realtimeRes.Usage.TotalTokens += usage.Quota
+		}
 
-				if usage != nil {
-					completionsRes.Usage = *usage
-					completionsRes.Usage.TotalTokens = totalTokens
-				}
+		if err := grpool.Add(gctx.NeverDone(ctx), func(ctx context.Context) {
 
-				//s.SaveLog(ctx, reqModel, realModel, fallbackModel, k, &params, completionsRes, retryInfo, false)
+			realModel.ModelAgent = modelAgent
 
-			}); err != nil {
-				logger.Error(ctx, err)
-				panic(err)
-			}
+			s.SaveLog(ctx, reqModel, realModel, fallbackModel, k, &params, realtimeRes, retryInfo)
 
 		}); err != nil {
 			logger.Error(ctx, err)
@@ -275,7 +217,7 @@ func (s *sRealtime) Realtime(ctx context.Context, r *ghttp.Request, params model
 	}
 
 	// 替换预设提示词
-	if reqModel.IsEnablePresetConfig && reqModel.PresetConfig.IsSupportSystemRole && reqModel.PresetConfig.SystemRolePrompt != "" {
+	if reqModel.IsEnablePresetConfig && reqModel.PresetConfig.IsSupportSystemRole && reqModel.PresetConfig.SystemRolePrompt != "" && len(request.Messages) > 0 {
 		if request.Messages[0].Role == consts.ROLE_SYSTEM {
 			request.Messages = append([]sdkm.ChatCompletionMessage{{
 				Role:    consts.ROLE_SYSTEM,
@@ -357,10 +299,21 @@ func (s *sRealtime) Realtime(ctx context.Context, r *ghttp.Request, params model
 		return err
 	}
 
+	// 上游事件转发结束
+	done := make(chan struct{})
+
 	if err := grpool.AddWithRecover(ctx, func(ctx context.Context) {
 
+		defer close(done)
 		defer close(response)
 
+		// 上游结束后关闭客户端连接, 使客户端读取循环退出
+		defer func() {
+			if err := conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second)); err != nil && !errors.Is(err, websocket.ErrCloseSent) {
+				logger.Error(ctx, err)
+			}
+		}()
+
 		for {
 
 			response := <-response
@@ -369,39 +322,22 @@ func (s *sRealtime) Realtime(ctx context.Context, r *ghttp.Request, params model
 				return
 			}
 
+			mutex.Lock()
 			connTime = response.ConnTime
-			duration = response.Duration
 			totalTime = response.TotalTime
+			mutex.Unlock()
 
 			if response.Error != nil {
 
 				if errors.Is(response.Error, io.EOF) {
-
-					if response.Usage != nil {
-						if usage == nil {
-							usage = response.Usage
-						} else {
-							if response.Usage.PromptTokens != 0 {
-								usage.PromptTokens = response.Usage.PromptTokens
-							}
-							if response.Usage.CompletionTokens != 0 {
-								usage.CompletionTokens = response.Usage.CompletionTokens
-							}
-							if response.Usage.CompletionTokensDetails.ReasoningTokens != 0 {
-								usage.CompletionTokensDetails.ReasoningTokens = response.Usage.CompletionTokensDetails.ReasoningTokens
-							}
-							if response.Usage.TotalTokens != 0 {
-								usage.TotalTokens = response.Usage.TotalTokens
-							} else {
-								usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
-							}
-						}
-					}
-
 					return
 				}
 
-				err = response.Error
+				logger.Error(ctx, response.Error)
+
+				mutex.Lock()
+				upstreamErr = response.Error
+				mutex.Unlock()
 
 				// 记录错误次数和禁用
 				service.Common().RecordError(ctx, realModel, k, modelAgent)
@@ -409,38 +345,35 @@ func (s *sRealtime) Realtime(ctx context.Context, r *ghttp.Request, params model
 				return
 			}
 
-			//if len(response.Choices) > 0 && response.Choices[0].Delta != nil {
-			//	completion += response.Choices[0].Delta.Content
-			//}
-			//
-			//if len(response.Choices) > 0 && response.Choices[0].Delta != nil && len(response.Choices[0].Delta.ToolCalls) > 0 {
-			//	completion += response.Choices[0].Delta.ToolCalls[0].Function.Arguments
-			//}
-
-			if response.Usage != nil {
-				if usage == nil {
-					usage = response.Usage
-				} else {
-					if response.Usage.PromptTokens != 0 {
-						usage.PromptTokens = response.Usage.PromptTokens
-					}
-					if response.Usage.CompletionTokens != 0 {
-						usage.CompletionTokens = response.Usage.CompletionTokens
-					}
-					if response.Usage.TotalTokens != 0 {
-						usage.TotalTokens = response.Usage.TotalTokens
-					} else {
-						usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
-					}
-				}
-			}
+			if response.MessageType == websocket.TextMessage && len(response.Message) > 0 {
 
-			// OpenAI官方格式
-			if len(response.Message) > 0 {
-				if err = conn.WriteMessage(response.MessageType, response.Message); err != nil {
+				realtimeResponse := new(model.RealtimeResponse)
+				if err := gjson.Unmarshal(response.Message, realtimeResponse); err != nil {
 					logger.Error(ctx, err)
-					return
 				}
+
+				switch realtimeResponse.Type {
+				case "conversation.item.input_audio_transcription.completed":
+					mutex.Lock()
+					prompt += realtimeResponse.Transcript
+					mutex.Unlock()
+				case "response.audio_transcript.done":
+					mutex.Lock()
+					completion += realtimeResponse.Transcript
+					mutex.Unlock()
+				case "response.text.done":
+					mutex.Lock()
+					completion += realtimeResponse.Text
+					mutex.Unlock()
+				case "response.done":
+					s.billing(ctx, reqModel, k, realtimeResponse, &mutex, &usages)
+				}
+			}
+
+			// 原样转发上游事件
+			if err := conn.WriteMessage(response.MessageType, response.Message); err != nil {
+				logger.Error(ctx, err)
+				return
 			}
 		}
 
@@ -456,9 +389,29 @@ func (s *sRealtime) Realtime(ctx context.Context, r *ghttp.Request, params model
 		messageType, message, err := conn.ReadMessage()
 		if err != nil {
 
-			requestChan <- nil
+			// 上游写入协程可能已退出, 避免阻塞
+			select {
+			case requestChan <- nil:
+			case <-done:
+			case <-time.After(5 * time.Second):
+			}
+
+			// 等待最后一轮用量统计完成
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+			}
+
+			// 上游错误导致的会话结束, 以上游错误为准
+			mutex.Lock()
+			sessionErr := upstreamErr
+			mutex.Unlock()
 
-			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			if sessionErr != nil {
+				return sessionErr
+			}
+
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
 				return nil
 			}
 
@@ -466,9 +419,185 @@ func (s *sRealtime) Realtime(ctx context.Context, r *ghttp.Request, params model
 			return err
 		}
 
-		requestChan <- &sdkm.RealtimeRequest{
+		// 原样转发客户端事件
+		select {
+		case requestChan <- &sdkm.RealtimeRequest{
 			MessageType: messageType,
 			Message:     message,
+		}:
+		case <-done:
+		}
+	}
+}
+
+// 按每轮response.done用量计费
+func (s *sRealtime) billing(ctx context.Context, reqModel *model.Model, key *model.Key, realtimeResponse *model.RealtimeResponse, mutex *sync.Mutex, usages *[]mcommon.RealtimeUsage) {
+
+	responseUsage := realtimeResponse.Response.Usage
+
+	usage := mcommon.RealtimeUsage{
+		ResponseId:        realtimeResponse.Response.Id,
+		InputTextTokens:   responseUsage.InputTokenDetails.TextTokens,
+		InputAudioTokens:  responseUsage.InputTokenDetails.AudioTokens,
+		CachedTokens:      responseUsage.InputTokenDetails.CachedTokens,
+		OutputTextTokens:  responseUsage.OutputTokenDetails.TextTokens,
+		OutputAudioTokens: responseUsage.OutputTokenDetails.AudioTokens,
+		TotalTokens:       responseUsage.TotalTokens,
+	}
+
+	// 上游未返回明细时按文本计
+	if usage.InputTextTokens+usage.InputAudioTokens == 0 {
+		usage.InputTextTokens = responseUsage.InputTokens
+	}
+
+	if usage.OutputTextTokens+usage.OutputAudioTokens == 0 {
+		usage.OutputTextTokens = responseUsage.OutputTokens
+	}
+
+	usage.Quota = common.GetRealtimeQuota(reqModel.RealtimeQuota, usage)
+
+	mutex.Lock()
+	*usages = append(*usages, usage)
+	mutex.Unlock()
+
+	if err := grpool.Add(gctx.NeverDone(ctx), func(ctx context.Context) {
+		if err := service.Common().RecordUsage(ctx, usage.Quota, key.Key); err != nil {
+			logger.Error(ctx, err)
+			panic(err)
 		}
+	}); err != nil {
+		logger.Error(ctx, err)
+	}
+}
+
+// 保存日志
+func (s *sRealtime) SaveLog(ctx context.Context, reqModel, realModel, fallbackModel *model.Model, key *model.Key, realtimeReq *model.RealtimeRequest, realtimeRes *model.RealtimeRes, retryInfo *mcommon.Retry, retry ...int) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sRealtime SaveLog time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	// 不记录此错误日志
+	if realtimeRes.Error != nil && (errors.Is(realtimeRes.Error, errors.ERR_MODEL_NOT_FOUND) || errors.Is(realtimeRes.Error, errors.ERR_MODEL_DISABLED)) {
+		return
+	}
+
+	chat := do.Chat{
+		TraceId:          gctx.CtxId(ctx),
+		UserId:           service.Session().GetUserId(ctx),
+		AppId:            service.Session().GetAppId(ctx),
+		PromptTokens:     realtimeRes.Usage.PromptTokens,
+		CompletionTokens: realtimeRes.Usage.CompletionTokens,
+		TotalTokens:      realtimeRes.Usage.TotalTokens,
+		Turns:            len(realtimeRes.Usages),
+		RealtimeUsages:   realtimeRes.Usages,
+		ConnTime:         realtimeRes.ConnTime,
+		Duration:         realtimeRes.Duration,
+		TotalTime:        realtimeRes.TotalTime,
+		InternalTime:     realtimeRes.InternalTime,
+		ReqTime:          realtimeRes.EnterTime,
+		ReqDate:          gtime.NewFromTimeStamp(realtimeRes.EnterTime).Format("Y-m-d"),
+		ClientIp:         g.RequestFromCtx(ctx).GetClientIp(),
+		RemoteIp:         g.RequestFromCtx(ctx).GetRemoteIp(),
+		LocalIp:          util.GetLocalIp(),
+		Status:           1,
+		Host:             g.RequestFromCtx(ctx).GetHost(),
+	}
+
+	if slices.Contains(config.Cfg.RecordLogs, "prompt") {
+		chat.Prompt = realtimeRes.Prompt
+	}
+
+	if slices.Contains(config.Cfg.RecordLogs, "completion") {
+		chat.Completion = realtimeRes.Completion
+	}
+
+	if reqModel != nil {
+		chat.Corp = reqModel.Corp
+		chat.ModelId = reqModel.Id
+		chat.Name = reqModel.Name
+		chat.Model = reqModel.Model
+		chat.Type = reqModel.Type
+		chat.RealtimeQuota = reqModel.RealtimeQuota
+	} else {
+		chat.Model = realtimeReq.Model
+	}
+
+	if realModel != nil {
+
+		chat.IsEnablePresetConfig = realModel.IsEnablePresetConfig
+		chat.PresetConfig = realModel.PresetConfig
+		chat.IsEnableForward = realModel.IsEnableForward
+		chat.ForwardConfig = realModel.ForwardConfig
+		chat.IsEnableModelAgent = realModel.IsEnableModelAgent
+		chat.RealModelId = realModel.Id
+		chat.RealModelName = realModel.Name
+		chat.RealModel = realModel.Model
+
+		if chat.IsEnableModelAgent && realModel.ModelAgent != nil {
+			chat.ModelAgentId = realModel.ModelAgent.Id
+			chat.ModelAgent = &do.ModelAgent{
+				Corp:    realModel.ModelAgent.Corp,
+				Name:    realModel.ModelAgent.Name,
+				BaseUrl: realModel.ModelAgent.BaseUrl,
+				Path:    realModel.ModelAgent.Path,
+				Weight:  realModel.ModelAgent.Weight,
+				Remark:  realModel.ModelAgent.Remark,
+				Status:  realModel.ModelAgent.Status,
+			}
+		}
+	}
+
+	if fallbackModel != nil {
+		chat.IsEnableFallback = true
+		chat.FallbackConfig = &mcommon.FallbackConfig{
+			FallbackModel:     fallbackModel.Model,
+			FallbackModelName: fallbackModel.Name,
+		}
+	}
+
+	if key != nil {
+		chat.Key = key.Key
+	}
+
+	if realtimeRes.Error != nil {
+		chat.ErrMsg = realtimeRes.Error.Error()
+		if common.IsAborted(realtimeRes.Error) {
+			chat.Status = 2
+		} else {
+			chat.Status = -1
+		}
+	}
+
+	if retryInfo != nil {
+
+		chat.IsRetry = retryInfo.IsRetry
+		chat.Retry = &mcommon.Retry{
+			IsRetry:    retryInfo.IsRetry,
+			RetryCount: retryInfo.RetryCount,
+			ErrMsg:     retryInfo.ErrMsg,
+		}
+
+		if chat.IsRetry {
+			chat.Status = 3
+			chat.ErrMsg = retryInfo.ErrMsg
+		}
+	}
+
+	if _, err := dao.Chat.Insert(ctx, chat); err != nil {
+		logger.Error(ctx, err)
+
+		if len(retry) == 5 {
+			panic(err)
+		}
+
+		retry = append(retry, 1)
+
+		time.Sleep(time.Duration(len(retry)*5) * time.Second)
+
+		logger.Errorf(ctx, "sRealtime SaveLog retry: %d", len(retry))
+
+		s.SaveLog(ctx, reqModel, realModel, fallbackModel, key, realtimeReq, realtimeRes, retryInfo, retry...)
 	}
 }
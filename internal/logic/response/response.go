@@ -0,0 +1,290 @@
+package response
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/dao"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/internal/logic/common"
+	"github.com/iimeta/fastapi/internal/model"
+	mcommon "github.com/iimeta/fastapi/internal/model/common"
+	"github.com/iimeta/fastapi/internal/model/do"
+	"github.com/iimeta/fastapi/internal/model/entity"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/logger"
+	"github.com/iimeta/fastapi/utility/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"slices"
+)
+
+type sResponse struct{}
+
+func init() {
+	service.RegisterResponse(New())
+}
+
+func New() service.IResponse {
+	return &sResponse{}
+}
+
+// 回溯上一轮响应的最大轮数
+const MAX_HISTORY = 1000
+
+// Create
+func (s *sResponse) Create(ctx context.Context, params model.ResponsesReq) (response *model.Response, err error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sResponse Create time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	input, request, err := s.buildRequest(ctx, params)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	// 计费和日志与Chat保持一致, 每轮单独计费
+	res, err := service.Chat().Completions(ctx, request, nil)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	response = newResponse(params)
+	response.Status = "completed"
+	response.Output = common.ConvChatCompletionResponseToResponseItems(&res)
+
+	if len(res.Choices) > 0 && string(res.Choices[0].FinishReason) == "length" {
+		response.Status = "incomplete"
+		response.IncompleteDetails = map[string]interface{}{"reason": "max_output_tokens"}
+	}
+
+	if res.Usage != nil {
+		response.Usage = &mcommon.ResponseUsage{
+			InputTokens:  res.Usage.PromptTokens,
+			OutputTokens: res.Usage.CompletionTokens,
+			TotalTokens:  res.Usage.PromptTokens + res.Usage.CompletionTokens,
+		}
+	}
+
+	if err = s.save(ctx, response, input); err != nil {
+		logger.Error(ctx, err)
+	}
+
+	return response, nil
+}
+
+// CreateStream
+func (s *sResponse) CreateStream(ctx context.Context, params model.ResponsesReq) (err error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sResponse CreateStream time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	input, request, err := s.buildRequest(ctx, params)
+	if err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	response := newResponse(params)
+	response.Status = "in_progress"
+
+	stream := common.NewResponsesStream(response)
+	stream.PromptTokens = common.GetPromptTokens(ctx, params.Model, request.Messages)
+
+	// 以Responses语义事件输出流式响应
+	g.RequestFromCtx(ctx).SetCtxVar(consts.PROTOCOL_KEY, consts.PROTOCOL_RESPONSES)
+	g.RequestFromCtx(ctx).SetCtxVar(consts.RESPONSES_STREAM_KEY, stream)
+
+	if err = service.Chat().CompletionsStream(ctx, request, nil); err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	if err = s.save(ctx, stream.Response(), input); err != nil {
+		logger.Error(ctx, err)
+	}
+
+	return nil
+}
+
+// Response详情
+func (s *sResponse) Retrieve(ctx context.Context, responseId string) (*model.Response, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sResponse Retrieve time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	result, err := s.getResponse(ctx, responseId)
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	return &model.Response{
+		Id:                 result.ResponseId,
+		Object:             "response",
+		CreatedAt:          result.CreatedAt / 1000,
+		Status:             result.Status,
+		Instructions:       result.Instructions,
+		Model:              result.Model,
+		Output:             result.Output,
+		ParallelToolCalls:  true,
+		PreviousResponseId: result.PreviousResponseId,
+		Store:              true,
+		Tools:              make([]model.ResponseTool, 0),
+		Usage:              &result.Usage,
+		Metadata:           result.Metadata,
+	}, nil
+}
+
+// 删除Response
+func (s *sResponse) Delete(ctx context.Context, responseId string) (*model.ResponseDeleteRes, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sResponse Delete time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	deletedCount, err := dao.Response.DeleteOne(ctx, bson.M{
+		"response_id": responseId,
+		"user_id":     service.Session().GetUserId(ctx),
+		"app_id":      service.Session().GetAppId(ctx),
+	})
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	if deletedCount == 0 {
+		return nil, errors.ERR_RESPONSE_NOT_FOUND
+	}
+
+	return &model.ResponseDeleteRes{
+		Id:      responseId,
+		Object:  "response",
+		Deleted: true,
+	}, nil
+}
+
+// 根据上一轮响应还原历史消息并转换为Chat请求
+func (s *sResponse) buildRequest(ctx context.Context, params model.ResponsesReq) ([]mcommon.ResponseItem, sdkm.ChatCompletionRequest, error) {
+
+	if params.Model == "" || params.Input == nil {
+		return nil, sdkm.ChatCompletionRequest{}, errors.ERR_INVALID_PARAMETER
+	}
+
+	input := common.ConvResponsesInput(params.Input)
+	if len(input) == 0 {
+		return nil, sdkm.ChatCompletionRequest{}, errors.ERR_INVALID_PARAMETER
+	}
+
+	items := make([]mcommon.ResponseItem, 0)
+
+	// 上一轮的系统指令不会延续到本轮
+	previousResponseId := params.PreviousResponseId
+	for i := 0; previousResponseId != "" && i < MAX_HISTORY; i++ {
+
+		previous, err := s.getResponse(ctx, previousResponseId)
+		if err != nil {
+			logger.Error(ctx, err)
+			return nil, sdkm.ChatCompletionRequest{}, err
+		}
+
+		items = append(slices.Concat(previous.Input, previous.Output), items...)
+		previousResponseId = previous.PreviousResponseId
+	}
+
+	messages := common.ConvResponseItemsToMessages(append(items, input...))
+
+	common.SetTemperatureFlag(ctx, params.Temperature != nil)
+
+	return input, common.ConvResponsesToChatCompletionRequest(params, messages), nil
+}
+
+// 保存本轮输入输出, 用于后续previous_response_id还原上下文
+func (s *sResponse) save(ctx context.Context, response *model.Response, input []mcommon.ResponseItem) error {
+
+	if !response.Store {
+		return nil
+	}
+
+	var usage mcommon.ResponseUsage
+	if response.Usage != nil {
+		usage = *response.Usage
+	}
+
+	if _, err := dao.Response.Insert(ctx, &do.Response{
+		ResponseId:         response.Id,
+		UserId:             service.Session().GetUserId(ctx),
+		AppId:              service.Session().GetAppId(ctx),
+		Model:              response.Model,
+		PreviousResponseId: response.PreviousResponseId,
+		Instructions:       response.Instructions,
+		Input:              input,
+		Output:             response.Output,
+		Usage:              usage,
+		Status:             response.Status,
+		Metadata:           response.Metadata,
+		CreatedAt:          response.CreatedAt * 1000,
+	}); err != nil {
+		logger.Error(ctx, err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *sResponse) getResponse(ctx context.Context, responseId string) (*entity.Response, error) {
+
+	result, err := dao.Response.FindOne(ctx, bson.M{
+		"response_id": responseId,
+		"user_id":     service.Session().GetUserId(ctx),
+		"app_id":      service.Session().GetAppId(ctx),
+	})
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, errors.ERR_RESPONSE_NOT_FOUND
+	}
+
+	return result, nil
+}
+
+func newResponse(params model.ResponsesReq) *model.Response {
+
+	response := &model.Response{
+		Id:                 "resp_" + util.GenerateId(),
+		Object:             "response",
+		CreatedAt:          gtime.Timestamp(),
+		Instructions:       params.Instructions,
+		MaxOutputTokens:    params.MaxOutputTokens,
+		Model:              params.Model,
+		Output:             make([]mcommon.ResponseItem, 0),
+		ParallelToolCalls:  params.ParallelToolCalls == nil || *params.ParallelToolCalls,
+		PreviousResponseId: params.PreviousResponseId,
+		Store:              params.Store == nil || *params.Store,
+		Temperature:        params.Temperature,
+		TopP:               params.TopP,
+		ToolChoice:         params.ToolChoice,
+		Tools:              params.Tools,
+		Metadata:           params.Metadata,
+		User:               params.User,
+	}
+
+	if response.Tools == nil {
+		response.Tools = make([]model.ResponseTool, 0)
+	}
+
+	if response.ToolChoice == nil {
+		response.ToolChoice = "auto"
+	}
+
+	return response
+}
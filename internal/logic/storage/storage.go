@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"fmt"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gfile"
+	"github.com/gogf/gf/v2/os/grpool"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/iimeta/fastapi/internal/config"
+	"github.com/iimeta/fastapi/internal/dao"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/internal/model/do"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/crypto"
+	"github.com/iimeta/fastapi/utility/logger"
+	"github.com/iimeta/fastapi/utility/storage"
+	"github.com/iimeta/fastapi/utility/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+)
+
+type sStorage struct{}
+
+func init() {
+	service.RegisterStorage(New())
+}
+
+func New() service.IStorage {
+	return &sStorage{}
+}
+
+// 转存文件默认大小上限(MB)
+const DEFAULT_MAX_SIZE = 50
+
+// 转存远程文件, 返回对象ID
+func (s *sStorage) Rehost(ctx context.Context, sourceUrl string) (string, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sStorage Rehost time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	// 未配置签名密钥时任何人都可伪造签名地址, 不转存
+	if config.Cfg.Storage.Secret == "" {
+		return "", errors.New("sStorage Rehost storage secret is not configured")
+	}
+
+	client := g.Client().Timeout(config.Cfg.Http.Timeout * time.Second)
+	if config.Cfg.Http.ProxyUrl != "" {
+		client.SetProxy(config.Cfg.Http.ProxyUrl)
+	}
+
+	res, err := client.Get(ctx, sourceUrl)
+	if err != nil {
+		logger.Error(ctx, err)
+		return "", err
+	}
+	defer func() {
+		if err := res.Close(); err != nil {
+			logger.Error(ctx, err)
+		}
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		err = errors.Newf("sStorage Rehost sourceUrl: %s, statusCode: %d", sourceUrl, res.StatusCode)
+		logger.Error(ctx, err)
+		return "", err
+	}
+
+	maxSize := config.Cfg.Storage.MaxSize
+	if maxSize <= 0 {
+		maxSize = DEFAULT_MAX_SIZE
+	}
+	maxSize *= 1024 * 1024
+
+	if res.ContentLength > maxSize {
+		err = errors.Newf("sStorage Rehost sourceUrl: %s, size %d exceeds limit %d", sourceUrl, res.ContentLength, maxSize)
+		logger.Error(ctx, err)
+		return "", err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxSize+1))
+	if err != nil {
+		logger.Error(ctx, err)
+		return "", err
+	}
+
+	if int64(len(data)) > maxSize {
+		err = errors.Newf("sStorage Rehost sourceUrl: %s, size exceeds limit %d", sourceUrl, maxSize)
+		logger.Error(ctx, err)
+		return "", err
+	}
+
+	contentType := res.Header.Get("Content-Type")
+
+	ext := gfile.Ext(gstr.Split(sourceUrl, "?")[0])
+	if extensions, _ := mime.ExtensionsByType(contentType); ext == "" && len(extensions) > 0 {
+		ext = extensions[0]
+	}
+
+	path := fmt.Sprintf("%s/%s%s", gtime.Now().Format("Ymd"), util.GenerateId(), ext)
+
+	if err = newStorage().Put(ctx, path, data, contentType); err != nil {
+		logger.Error(ctx, err)
+		return "", err
+	}
+
+	object := &do.StorageObject{
+		UserId:      service.Session().GetUserId(ctx),
+		AppId:       service.Session().GetAppId(ctx),
+		Storage:     getStorageType(),
+		Path:        path,
+		SourceUrl:   sourceUrl,
+		ContentType: contentType,
+		Bytes:       int64(len(data)),
+		Status:      1,
+	}
+
+	// 保留天数优先使用应用配置
+	retention := config.Cfg.Storage.Retention
+	if app, err := service.App().GetCacheApp(ctx, object.AppId); err == nil && app != nil && app.Retention > 0 {
+		retention = app.Retention
+	}
+
+	if retention > 0 {
+		object.ExpiresAt = gtime.Now().AddDate(0, 0, retention).TimestampMilli()
+	}
+
+	id, err := dao.StorageObject.Insert(ctx, object)
+	if err != nil {
+		logger.Error(ctx, err)
+		return "", err
+	}
+
+	return id, nil
+}
+
+// 生成对象签名地址
+func (s *sStorage) SignUrl(ctx context.Context, objectId string) string {
+
+	expiresIn := config.Cfg.Storage.Expires * time.Second
+	if expiresIn <= 0 {
+		expiresIn = 24 * time.Hour
+	}
+
+	expires := time.Now().Add(expiresIn).Unix()
+
+	return fmt.Sprintf("%s/storage/%s?expires=%d&signature=%s", gstr.TrimRight(config.Cfg.Storage.BaseUrl, "/"), objectId, expires, sign(objectId, expires))
+}
+
+// 校验签名并读取对象
+func (s *sStorage) Download(ctx context.Context, objectId string, expires int64, signature string) (string, []byte, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sStorage Download time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	if config.Cfg.Storage.Secret == "" || expires < time.Now().Unix() || !hmac.Equal([]byte(signature), []byte(sign(objectId, expires))) {
+		return "", nil, errors.ERR_NOT_AUTHORIZED
+	}
+
+	object, err := dao.StorageObject.FindOne(ctx, bson.M{"_id": objectId, "status": 1})
+	if err != nil {
+		logger.Error(ctx, err)
+		return "", nil, errors.ERR_FILE_NOT_FOUND
+	}
+
+	data, err := newStorage(object.Storage).Get(ctx, object.Path)
+	if err != nil {
+		logger.Error(ctx, err)
+		return "", nil, errors.ERR_FILE_NOT_FOUND
+	}
+
+	return object.ContentType, data, nil
+}
+
+// 启动过期对象清理任务
+func (s *sStorage) Start(ctx context.Context) {
+
+	if !config.Cfg.Storage.Open {
+		return
+	}
+
+	// 签名密钥为空时签名地址可被伪造, 拒绝启动
+	if config.Cfg.Storage.Secret == "" {
+		panic(errors.New("storage.secret must be configured when storage is open"))
+	}
+
+	interval := config.Cfg.Storage.Interval * time.Second
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	if err := grpool.AddWithRecover(ctx, func(ctx context.Context) {
+		for {
+
+			time.Sleep(interval)
+
+			results, err := dao.StorageObject.Find(ctx, bson.M{
+				"status":     1,
+				"expires_at": bson.M{"$gt": 0, "$lt": gtime.TimestampMilli()},
+			})
+			if err != nil {
+				logger.Error(ctx, err)
+				continue
+			}
+
+			for _, result := range results {
+
+				if err = newStorage(result.Storage).Delete(ctx, result.Path); err != nil {
+					logger.Error(ctx, err)
+					continue
+				}
+
+				if err = dao.StorageObject.UpdateById(ctx, result.Id, bson.M{
+					"status":  -1,
+					"updater": "retention",
+				}); err != nil {
+					logger.Error(ctx, err)
+				}
+			}
+		}
+	}, nil); err != nil {
+		panic(err)
+	}
+}
+
+func newStorage(storageType ...string) storage.Storage {
+
+	t := getStorageType()
+	if len(storageType) > 0 && storageType[0] != "" {
+		t = storageType[0]
+	}
+
+	switch t {
+	case "s3":
+		return &storage.S3{
+			Endpoint:  config.Cfg.Storage.S3.Endpoint,
+			Region:    config.Cfg.Storage.S3.Region,
+			Bucket:    config.Cfg.Storage.S3.Bucket,
+			AccessKey: config.Cfg.Storage.S3.AccessKey,
+			SecretKey: config.Cfg.Storage.S3.SecretKey,
+			PathStyle: config.Cfg.Storage.S3.PathStyle,
+			Client:    &http.Client{Timeout: config.Cfg.Http.Timeout * time.Second},
+		}
+	default:
+		return &storage.Local{Path: getLocalPath()}
+	}
+}
+
+func getStorageType() string {
+
+	if config.Cfg.Storage.Type != "" {
+		return config.Cfg.Storage.Type
+	}
+
+	return "local"
+}
+
+func getLocalPath() string {
+
+	if config.Cfg.Storage.LocalPath != "" {
+		return config.Cfg.Storage.LocalPath
+	}
+
+	return "./resource/storage"
+}
+
+func sign(objectId string, expires int64) string {
+	return crypto.HmacSHA256(config.Cfg.Storage.Secret, fmt.Sprintf("%s.%d", objectId, expires))
+}
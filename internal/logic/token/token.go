@@ -0,0 +1,192 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/util/gconv"
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/consts"
+	"github.com/iimeta/fastapi/internal/errors"
+	"github.com/iimeta/fastapi/internal/logic/common"
+	"github.com/iimeta/fastapi/internal/model"
+	"github.com/iimeta/fastapi/internal/service"
+	"github.com/iimeta/fastapi/utility/logger"
+	"math"
+	"strings"
+)
+
+type sToken struct{}
+
+func init() {
+	service.RegisterToken(New())
+}
+
+func New() service.IToken {
+	return &sToken{}
+}
+
+// Tokenize
+func (s *sToken) Tokenize(ctx context.Context, params sdkm.ChatCompletionRequest) (*model.TokenizeRes, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sToken Tokenize time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	if len(params.Messages) == 0 {
+		return nil, errors.ERR_INVALID_PARAMETER
+	}
+
+	reqModel, err := service.Model().GetModelBySecretKey(ctx, params.Model, service.Session().GetSecretKey(ctx))
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	res := &model.TokenizeRes{
+		Model: reqModel.Model,
+	}
+
+	model := reqModel.Model
+
+	// 与计费逻辑保持一致
+	if reqModel.Type == 100 { // 多模态
+
+		res.TextTokens, res.ImageTokens = common.GetMultimodalTokens(ctx, model, params.Messages, reqModel)
+		res.TextTokens += common.GetToolsTokens(ctx, model, params)
+
+		res.PromptTokens = res.TextTokens + res.ImageTokens
+		res.Quota = res.ImageTokens + int(math.Ceil(float64(res.TextTokens)*reqModel.MultimodalQuota.TextQuota.PromptRatio))
+		res.CompletionRatio = reqModel.MultimodalQuota.TextQuota.CompletionRatio
+
+	} else {
+
+		res.TextTokens = common.GetPromptTokens(ctx, model, params.Messages) + common.GetToolsTokens(ctx, model, params)
+		res.PromptTokens = res.TextTokens
+
+		if reqModel.TextQuota.BillingMethod == 1 {
+			res.Quota = int(math.Ceil(float64(res.PromptTokens) * reqModel.TextQuota.PromptRatio))
+			res.CompletionRatio = reqModel.TextQuota.CompletionRatio
+		} else {
+			res.Quota = reqModel.TextQuota.FixedQuota
+		}
+	}
+
+	return res, nil
+}
+
+// Anthropic格式CountTokens
+func (s *sToken) CountTokens(ctx context.Context, params model.CountTokensReq) (*model.CountTokensRes, error) {
+
+	now := gtime.TimestampMilli()
+	defer func() {
+		logger.Debugf(ctx, "sToken CountTokens time: %d", gtime.TimestampMilli()-now)
+	}()
+
+	res, err := s.Tokenize(ctx, convAnthropicToChatCompletionRequest(params))
+	if err != nil {
+		logger.Error(ctx, err)
+		return nil, err
+	}
+
+	return &model.CountTokensRes{
+		InputTokens: res.PromptTokens,
+	}, nil
+}
+
+// Anthropic消息转换为Chat请求
+func convAnthropicToChatCompletionRequest(params model.CountTokensReq) sdkm.ChatCompletionRequest {
+
+	request := sdkm.ChatCompletionRequest{
+		Model: params.Model,
+	}
+
+	if system, ok := params.System.(string); ok && system != "" {
+		request.Messages = append(request.Messages, sdkm.ChatCompletionMessage{
+			Role:    consts.ROLE_SYSTEM,
+			Content: system,
+		})
+	} else if params.System != nil {
+
+		var texts []string
+		for _, block := range gconv.Maps(params.System) {
+			texts = append(texts, gconv.String(block["text"]))
+		}
+
+		request.Messages = append(request.Messages, sdkm.ChatCompletionMessage{
+			Role:    consts.ROLE_SYSTEM,
+			Content: strings.Join(texts, "\n"),
+		})
+	}
+
+	for _, message := range params.Messages {
+
+		if text, ok := message.Content.(string); ok {
+			request.Messages = append(request.Messages, sdkm.ChatCompletionMessage{
+				Role:    message.Role,
+				Content: text,
+			})
+			continue
+		}
+
+		var (
+			texts        []string
+			multiContent []interface{}
+			isMultimodal bool
+		)
+
+		for _, block := range gconv.Maps(message.Content) {
+
+			var text string
+
+			switch block["type"] {
+			case "text":
+				text = gconv.String(block["text"])
+			case "image":
+
+				source := gconv.Map(block["source"])
+
+				url := gconv.String(source["url"])
+				if source["type"] == "base64" {
+					url = fmt.Sprintf("data:%s;base64,%s", source["media_type"], source["data"])
+				}
+
+				isMultimodal = true
+				multiContent = append(multiContent, map[string]interface{}{
+					"type": "image_url",
+					"image_url": map[string]interface{}{
+						"url": url,
+					},
+				})
+
+				continue
+			case "tool_use":
+				text = gconv.String(block["name"]) + gjson.MustEncodeString(block["input"])
+			case "tool_result":
+				text = gconv.String(block["content"])
+			}
+
+			texts = append(texts, text)
+			multiContent = append(multiContent, map[string]interface{}{
+				"type": "text",
+				"text": text,
+			})
+		}
+
+		chatMessage := sdkm.ChatCompletionMessage{
+			Role: message.Role,
+		}
+
+		if isMultimodal {
+			chatMessage.Content = multiContent
+		} else {
+			chatMessage.Content = strings.Join(texts, "\n")
+		}
+
+		request.Messages = append(request.Messages, chatMessage)
+	}
+
+	return request
+}
@@ -1,21 +1,33 @@
 package model
 
+import "github.com/iimeta/fastapi/internal/model/common"
+
 type App struct {
-	Id             string   `json:"id,omitempty"`               // ID
-	AppId          int      `json:"app_id,omitempty"`           // 应用ID
-	Name           string   `json:"name,omitempty"`             // 应用名称
-	Models         []string `json:"models,omitempty"`           // 模型权限
-	IsLimitQuota   bool     `json:"is_limit_quota,omitempty"`   // 是否限制额度
-	Quota          int      `json:"quota,omitempty"`            // 剩余额度
-	UsedQuota      int      `json:"used_quota,omitempty"`       // 已用额度
-	QuotaExpiresAt int64    `json:"quota_expires_at,omitempty"` // 额度过期时间
-	IpWhitelist    []string `json:"ip_whitelist,omitempty"`     // IP白名单
-	IpBlacklist    []string `json:"ip_blacklist,omitempty"`     // IP黑名单
-	Remark         string   `json:"remark,omitempty"`           // 备注
-	Status         int      `json:"status,omitempty"`           // 状态[1:正常, 2:禁用, -1:删除]
-	UserId         int      `json:"user_id,omitempty"`          // 用户ID
-	Creator        string   `json:"creator,omitempty"`          // 创建人
-	Updater        string   `json:"updater,omitempty"`          // 更新人
-	CreatedAt      string   `json:"created_at,omitempty"`       // 创建时间
-	UpdatedAt      string   `json:"updated_at,omitempty"`       // 更新时间
+	Id                    string                     `json:"id,omitempty"`                       // ID
+	AppId                 int                        `json:"app_id,omitempty"`                   // 应用ID
+	Name                  string                     `json:"name,omitempty"`                     // 应用名称
+	Models                []string                   `json:"models,omitempty"`                   // 模型权限
+	IsLimitQuota          bool                       `json:"is_limit_quota,omitempty"`           // 是否限制额度
+	Quota                 int                        `json:"quota,omitempty"`                    // 剩余额度
+	UsedQuota             int                        `json:"used_quota,omitempty"`               // 已用额度
+	QuotaExpiresAt        int64                      `json:"quota_expires_at,omitempty"`         // 额度过期时间
+	IpWhitelist           []string                   `json:"ip_whitelist,omitempty"`             // IP白名单
+	IpBlacklist           []string                   `json:"ip_blacklist,omitempty"`             // IP黑名单
+	NotifyHook            string                     `json:"notify_hook,omitempty"`              // 任务回调地址
+	Retention             int                        `json:"retention,omitempty"`                // 存储保留天数
+	IsEnableCache         bool                       `json:"is_enable_cache,omitempty"`          // 是否启用响应缓存
+	CacheConfig           common.CacheConfig         `json:"cache_config,omitempty"`             // 响应缓存配置
+	IsEnableSemanticCache bool                       `json:"is_enable_semantic_cache,omitempty"` // 是否启用语义缓存
+	SemanticCacheConfig   common.SemanticCacheConfig `json:"semantic_cache_config,omitempty"`    // 语义缓存配置
+	IsEnableContext       bool                       `json:"is_enable_context,omitempty"`        // 是否启用上下文管理
+	ContextConfig         common.ContextConfig       `json:"context_config,omitempty"`           // 上下文管理配置
+	IsEnableCompaction    bool                       `json:"is_enable_compaction,omitempty"`     // 是否启用对话压缩
+	CompactionConfig      common.CompactionConfig    `json:"compaction_config,omitempty"`        // 对话压缩配置
+	Remark                string                     `json:"remark,omitempty"`                   // 备注
+	Status                int                        `json:"status,omitempty"`                   // 状态[1:正常, 2:禁用, -1:删除]
+	UserId                int                        `json:"user_id,omitempty"`                  // 用户ID
+	Creator               string                     `json:"creator,omitempty"`                  // 创建人
+	Updater               string                     `json:"updater,omitempty"`                  // 更新人
+	CreatedAt             string                     `json:"created_at,omitempty"`               // 创建时间
+	UpdatedAt             string                     `json:"updated_at,omitempty"`               // 更新时间
 }
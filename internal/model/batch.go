@@ -0,0 +1,73 @@
+package model
+
+import (
+	"github.com/iimeta/fastapi/internal/model/common"
+)
+
+type BatchCreateReq struct {
+	InputFileId      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata"`
+}
+
+type Batch struct {
+	Id               string                    `json:"id"`                       // ID
+	Object           string                    `json:"object"`                   // 对象类型
+	Endpoint         string                    `json:"endpoint"`                 // 接口地址
+	Errors           *BatchErrors              `json:"errors"`                   // 错误信息
+	InputFileId      string                    `json:"input_file_id"`            // 输入文件ID
+	CompletionWindow string                    `json:"completion_window"`        // 完成时间窗口
+	Status           string                    `json:"status"`                   // 状态
+	OutputFileId     string                    `json:"output_file_id,omitempty"` // 输出文件ID
+	ErrorFileId      string                    `json:"error_file_id,omitempty"`  // 错误文件ID
+	CreatedAt        int64                     `json:"created_at"`               // 创建时间, 单位秒
+	InProgressAt     int64                     `json:"in_progress_at,omitempty"` // 开始处理时间, 单位秒
+	ExpiresAt        int64                     `json:"expires_at,omitempty"`     // 过期时间, 单位秒
+	FinalizingAt     int64                     `json:"finalizing_at,omitempty"`  // 开始汇总时间, 单位秒
+	CompletedAt      int64                     `json:"completed_at,omitempty"`   // 完成时间, 单位秒
+	FailedAt         int64                     `json:"failed_at,omitempty"`      // 失败时间, 单位秒
+	ExpiredAt        int64                     `json:"expired_at,omitempty"`     // 已过期时间, 单位秒
+	CancellingAt     int64                     `json:"cancelling_at,omitempty"`  // 开始取消时间, 单位秒
+	CancelledAt      int64                     `json:"cancelled_at,omitempty"`   // 已取消时间, 单位秒
+	RequestCounts    common.BatchRequestCounts `json:"request_counts"`           // 请求数统计
+	Metadata         map[string]string         `json:"metadata,omitempty"`       // 元数据
+	UserId           int                       `json:"-"`                        // 用户ID
+	AppId            int                       `json:"-"`                        // 应用ID
+	Creator          string                    `json:"-"`                        // 创建人
+}
+
+type BatchErrors struct {
+	Object string              `json:"object"`
+	Data   []common.BatchError `json:"data"`
+}
+
+type BatchListRes struct {
+	Object  string   `json:"object"`
+	Data    []*Batch `json:"data"`
+	FirstId string   `json:"first_id,omitempty"`
+	LastId  string   `json:"last_id,omitempty"`
+	HasMore bool     `json:"has_more"`
+}
+
+// 批处理输入行
+type BatchRequestLine struct {
+	CustomId string                 `json:"custom_id"`
+	Method   string                 `json:"method"`
+	Url      string                 `json:"url"`
+	Body     map[string]interface{} `json:"body"`
+}
+
+// 批处理输出行
+type BatchResponseLine struct {
+	Id       string             `json:"id"`
+	CustomId string             `json:"custom_id"`
+	Response *BatchResponseBody `json:"response"`
+	Error    *common.BatchError `json:"error"`
+}
+
+type BatchResponseBody struct {
+	StatusCode int         `json:"status_code"`
+	RequestId  string      `json:"request_id"`
+	Body       interface{} `json:"body"`
+}
@@ -13,12 +13,21 @@ type CompletionsReq struct {
 }
 
 type CompletionsRes struct {
-	Completion   string     `json:"completion"`
-	Usage        sdkm.Usage `json:"usage"`
-	Error        error      `json:"err"`
-	ConnTime     int64      `json:"-"`
-	Duration     int64      `json:"-"`
-	TotalTime    int64      `json:"-"`
-	InternalTime int64      `json:"-"`
-	EnterTime    int64      `json:"-"`
+	Completion    string                 `json:"completion"`
+	Usage         sdkm.Usage             `json:"usage"`
+	Error         error                  `json:"err"`
+	ConnTime      int64                  `json:"-"`
+	Duration      int64                  `json:"-"`
+	TotalTime     int64                  `json:"-"`
+	InternalTime  int64                  `json:"-"`
+	EnterTime     int64                  `json:"-"`
+	CacheHits     int                    `json:"-"` // 缓存命中数
+	CacheMisses   int                    `json:"-"` // 缓存未命中数
+	IsCacheHit    bool                   `json:"-"` // 是否命中响应缓存
+	CacheType     int                    `json:"-"` // 缓存类型[1:精确匹配, 2:语义匹配]
+	Similarity    float64                `json:"-"` // 语义缓存相似度
+	SavedQuota    int                    `json:"-"` // 缓存节省额度
+	SchemaAttempt int                    `json:"-"` // 结构化输出校验第几次尝试
+	SchemaError   string                 `json:"-"` // 结构化输出校验错误
+	Params        map[string]interface{} `json:"-"` // 实际生效的请求参数
 }
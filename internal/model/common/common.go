@@ -30,8 +30,10 @@ type AudioQuota struct {
 }
 
 type MultimodalQuota struct {
-	TextQuota   TextQuota    `bson:"text_quota,omitempty"   json:"text_quota,omitempty"`   // 文本额度
-	ImageQuotas []ImageQuota `bson:"image_quotas,omitempty" json:"image_quotas,omitempty"` // 图像额度
+	TextQuota          TextQuota    `bson:"text_quota,omitempty"           json:"text_quota,omitempty"`           // 文本额度
+	ImageQuotas        []ImageQuota `bson:"image_quotas,omitempty"         json:"image_quotas,omitempty"`         // 图像额度
+	ImageBillingMethod int          `bson:"image_billing_method,omitempty" json:"image_billing_method,omitempty"` // 图像计费方式[1:固定额度, 2:OpenAI分块, 3:Claude像素, 4:Gemini固定]
+	ImageFetchSize     int          `bson:"image_fetch_size,omitempty"     json:"image_fetch_size,omitempty"`     // 远程图片获取大小上限(KB), 0:不获取
 }
 
 type RealtimeQuota struct {
@@ -40,6 +42,54 @@ type RealtimeQuota struct {
 	FixedQuota int        `bson:"fixed_quota,omitempty" json:"fixed_quota,omitempty"` // 固定额度
 }
 
+type EmbeddingConfig struct {
+	MaxBatchSize   int     `bson:"max_batch_size,omitempty"   json:"max_batch_size,omitempty"`   // 单次请求最大输入条数, 0表示使用公司默认值
+	MaxBatchTokens int     `bson:"max_batch_tokens,omitempty" json:"max_batch_tokens,omitempty"` // 单次请求最大令牌数, 0表示不限制
+	Concurrency    int     `bson:"concurrency,omitempty"      json:"concurrency,omitempty"`      // 拆分后的并发请求数, 0表示默认值
+	IsEnableCache  bool    `bson:"is_enable_cache,omitempty"  json:"is_enable_cache,omitempty"`  // 是否启用缓存
+	CacheTtl       int     `bson:"cache_ttl,omitempty"        json:"cache_ttl,omitempty"`        // 缓存时长(秒), 0表示默认1天
+	CacheMaxSize   int     `bson:"cache_max_size,omitempty"   json:"cache_max_size,omitempty"`   // 最大缓存条数, 超出后淘汰最早写入的, 0表示不限制
+	CacheRatio     float64 `bson:"cache_ratio,omitempty"      json:"cache_ratio,omitempty"`      // 缓存命中计费倍率, 0表示不计费
+}
+
+type CacheConfig struct {
+	Ttl           int     `bson:"ttl,omitempty"            json:"ttl,omitempty"`            // 缓存时长(秒), 0表示默认1小时
+	BillingPolicy int     `bson:"billing_policy,omitempty" json:"billing_policy,omitempty"` // 命中计费策略[1:全额计费, 2:按倍率计费, 3:不计费]
+	Ratio         float64 `bson:"ratio,omitempty"          json:"ratio,omitempty"`          // 计费策略为2时的计费倍率
+}
+
+type JsonSchemaConfig struct {
+	MaxRetries    int    `bson:"max_retries,omitempty"    json:"max_retries,omitempty"`    // 校验失败后的最大重试次数, 0表示默认2次
+	FallbackModel string `bson:"fallback_model,omitempty" json:"fallback_model,omitempty"` // 重试使用的后备模型, 为空时使用原模型
+}
+
+type EmulateConfig struct {
+	IsEmulateTools bool `bson:"is_emulate_tools,omitempty" json:"is_emulate_tools,omitempty"` // 是否模拟工具调用
+	IsEmulateJson  bool `bson:"is_emulate_json,omitempty"  json:"is_emulate_json,omitempty"`  // 是否模拟JSON模式
+}
+
+type ContextConfig struct {
+	Strategy       int `bson:"strategy,omitempty"        json:"strategy,omitempty"`        // 超出上下文长度时的策略[1:裁剪最早的对话, 2:裁剪中间的对话, 3:直接拒绝]
+	ReservedTokens int `bson:"reserved_tokens,omitempty" json:"reserved_tokens,omitempty"` // 为回复预留的令牌数, 请求指定max_tokens时以请求为准
+}
+
+type CompactionConfig struct {
+	Model     string `bson:"model,omitempty"      json:"model,omitempty"`      // 摘要模型
+	ModelName string `bson:"model_name,omitempty" json:"model_name,omitempty"` // 摘要模型名称
+	Threshold int    `bson:"threshold,omitempty"  json:"threshold,omitempty"`  // 触发压缩的提示令牌数
+	KeepTurns int    `bson:"keep_turns,omitempty" json:"keep_turns,omitempty"` // 保留最近的对话轮数, 0表示默认4轮
+	Ttl       int    `bson:"ttl,omitempty"        json:"ttl,omitempty"`        // 摘要缓存时长(秒), 0表示默认1天
+}
+
+type SemanticCacheConfig struct {
+	EmbeddingModel string  `bson:"embedding_model,omitempty" json:"embedding_model,omitempty"` // 向量模型
+	Threshold      float64 `bson:"threshold,omitempty"       json:"threshold,omitempty"`       // 相似度阈值, 0表示默认0.95
+	Ttl            int     `bson:"ttl,omitempty"             json:"ttl,omitempty"`             // 缓存时长(秒), 0表示默认1天
+	MaxSize        int     `bson:"max_size,omitempty"        json:"max_size,omitempty"`        // 每个模型最大缓存条数, 0表示使用全局配置
+	BillingPolicy  int     `bson:"billing_policy,omitempty"  json:"billing_policy,omitempty"`  // 命中计费策略[1:全额计费, 2:按倍率计费, 3:不计费]
+	Ratio          float64 `bson:"ratio,omitempty"           json:"ratio,omitempty"`           // 计费策略为2时的计费倍率
+}
+
 type MidjourneyQuota struct {
 	Name       string `bson:"name,omitempty"        json:"name,omitempty"`        // 名称
 	Action     string `bson:"action,omitempty"      json:"action,omitempty"`      // 动作[IMAGINE, UPSCALE, VARIATION, ZOOM, PAN, DESCRIBE, BLEND, SHORTEN, SWAP_FACE]
@@ -47,6 +97,13 @@ type MidjourneyQuota struct {
 	FixedQuota int    `bson:"fixed_quota,omitempty" json:"fixed_quota,omitempty"` // 固定额度
 }
 
+type ParamRule struct {
+	Action int         `bson:"action,omitempty" json:"action,omitempty"` // 动作[1:删除, 2:重命名, 3:默认值, 4:强制值, 5:拒绝]
+	Param  string      `bson:"param,omitempty"  json:"param,omitempty"`  // 参数名
+	Target string      `bson:"target,omitempty" json:"target,omitempty"` // 动作为2时的目标参数名
+	Value  interface{} `bson:"value,omitempty"  json:"value,omitempty"`  // 动作为3和4时的参数值
+}
+
 type ForwardConfig struct {
 	ForwardRule   int      `bson:"forward_rule,omitempty"   json:"forward_rule,omitempty"`   // 转发规则[1:全部转发, 2:按关键字, 3:内容长度]
 	MatchRule     []int    `bson:"match_rule,omitempty"     json:"match_rule,omitempty"`     // 转发规则为2时的匹配规则[1:智能匹配, 2:正则匹配]
@@ -78,3 +135,52 @@ type ImageData struct {
 	B64JSON       string `bson:"b64_json,omitempty"`
 	RevisedPrompt string `bson:"revised_prompt,omitempty"`
 }
+
+type BatchRequestCounts struct {
+	Total     int `bson:"total"     json:"total"`     // 总数
+	Completed int `bson:"completed" json:"completed"` // 已完成数
+	Failed    int `bson:"failed"    json:"failed"`    // 失败数
+}
+
+type BatchError struct {
+	Code    string `bson:"code,omitempty"    json:"code,omitempty"`    // 错误码
+	Message string `bson:"message,omitempty" json:"message,omitempty"` // 错误信息
+	Line    int    `bson:"line,omitempty"    json:"line,omitempty"`    // 行号
+}
+
+type ResponseItem struct {
+	Type      string            `bson:"type,omitempty"      json:"type,omitempty"`      // 类型[message, function_call, function_call_output]
+	Id        string            `bson:"id,omitempty"        json:"id,omitempty"`        // ID
+	Role      string            `bson:"role,omitempty"      json:"role,omitempty"`      // 角色
+	Status    string            `bson:"status,omitempty"    json:"status,omitempty"`    // 状态
+	Content   []ResponseContent `bson:"content,omitempty"   json:"content,omitempty"`   // 内容
+	CallId    string            `bson:"call_id,omitempty"   json:"call_id,omitempty"`   // 调用ID
+	Name      string            `bson:"name,omitempty"      json:"name,omitempty"`      // 函数名
+	Arguments string            `bson:"arguments,omitempty" json:"arguments,omitempty"` // 函数参数
+	Output    string            `bson:"output,omitempty"    json:"output,omitempty"`    // 函数结果
+}
+
+type ResponseContent struct {
+	Type     string `bson:"type,omitempty"      json:"type,omitempty"`      // 类型[input_text, input_image, output_text, refusal]
+	Text     string `bson:"text,omitempty"      json:"text,omitempty"`      // 文本
+	ImageUrl string `bson:"image_url,omitempty" json:"image_url,omitempty"` // 图片地址
+	Detail   string `bson:"detail,omitempty"    json:"detail,omitempty"`    // 图片精度
+	Refusal  string `bson:"refusal,omitempty"   json:"refusal,omitempty"`   // 拒绝内容
+}
+
+type ResponseUsage struct {
+	InputTokens  int `bson:"input_tokens"  json:"input_tokens"`  // 输入令牌数
+	OutputTokens int `bson:"output_tokens" json:"output_tokens"` // 输出令牌数
+	TotalTokens  int `bson:"total_tokens"  json:"total_tokens"`  // 总令牌数
+}
+
+type RealtimeUsage struct {
+	ResponseId        string `bson:"response_id,omitempty"         json:"response_id,omitempty"`         // 响应ID
+	InputTextTokens   int    `bson:"input_text_tokens,omitempty"   json:"input_text_tokens,omitempty"`   // 输入文本令牌数
+	InputAudioTokens  int    `bson:"input_audio_tokens,omitempty"  json:"input_audio_tokens,omitempty"`  // 输入音频令牌数
+	CachedTokens      int    `bson:"cached_tokens,omitempty"       json:"cached_tokens,omitempty"`       // 缓存令牌数
+	OutputTextTokens  int    `bson:"output_text_tokens,omitempty"  json:"output_text_tokens,omitempty"`  // 输出文本令牌数
+	OutputAudioTokens int    `bson:"output_audio_tokens,omitempty" json:"output_audio_tokens,omitempty"` // 输出音频令牌数
+	TotalTokens       int    `bson:"total_tokens,omitempty"        json:"total_tokens,omitempty"`        // 总令牌数
+	Quota             int    `bson:"quota,omitempty"               json:"quota,omitempty"`               // 额度
+}
@@ -36,7 +36,7 @@ type FastAPI struct {
 	Corp             string                   `json:"corp,omitempty"`              // 公司名称
 	Code             string                   `json:"code,omitempty"`              // 公司代码
 	Model            string                   `json:"model,omitempty"`             // 模型
-	Type             int                      `json:"type,omitempty"`              // 模型类型[1:文生文, 2:文生图, 3:图生文, 4:图生图, 5:文生语音, 6:语音生文, 100:多模态, 101:多模态实时]
+	Type             int                      `json:"type,omitempty"`              // 模型类型[1:文生文, 2:文生图, 3:图生文, 4:图生图, 5:文生语音, 6:语音生文, 7:重排序, 100:多模态, 101:多模态实时]
 	BaseUrl          string                   `json:"base_url,omitempty"`          // 模型地址
 	Path             string                   `json:"path,omitempty"`              // 模型路径
 	TextQuota        common.TextQuota         `json:"text_quota,omitempty"`        // 文本额度
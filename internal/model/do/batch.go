@@ -0,0 +1,38 @@
+package do
+
+import (
+	"github.com/gogf/gf/v2/util/gmeta"
+	"github.com/iimeta/fastapi/internal/model/common"
+)
+
+const (
+	BATCH_COLLECTION = "batch"
+)
+
+type Batch struct {
+	gmeta.Meta       `collection:"batch" bson:"-"`
+	UserId           int                       `bson:"user_id,omitempty"`           // 用户ID
+	AppId            int                       `bson:"app_id,omitempty"`            // 应用ID
+	Endpoint         string                    `bson:"endpoint,omitempty"`          // 接口地址[/v1/chat/completions, /v1/embeddings]
+	InputFileId      string                    `bson:"input_file_id,omitempty"`     // 输入文件ID
+	CompletionWindow string                    `bson:"completion_window,omitempty"` // 完成时间窗口
+	Status           string                    `bson:"status,omitempty"`            // 状态[validating, failed, in_progress, finalizing, completed, expired, cancelling, cancelled]
+	OutputFileId     string                    `bson:"output_file_id,omitempty"`    // 输出文件ID
+	ErrorFileId      string                    `bson:"error_file_id,omitempty"`     // 错误文件ID
+	Errors           []common.BatchError       `bson:"errors,omitempty"`            // 错误信息
+	RequestCounts    common.BatchRequestCounts `bson:"request_counts,omitempty"`    // 请求数统计
+	Metadata         map[string]string         `bson:"metadata,omitempty"`          // 元数据
+	TotalTokens      int                       `bson:"total_tokens,omitempty"`      // 总令牌数
+	InProgressAt     int64                     `bson:"in_progress_at,omitempty"`    // 开始处理时间
+	FinalizingAt     int64                     `bson:"finalizing_at,omitempty"`     // 开始汇总时间
+	CompletedAt      int64                     `bson:"completed_at,omitempty"`      // 完成时间
+	FailedAt         int64                     `bson:"failed_at,omitempty"`         // 失败时间
+	ExpiresAt        int64                     `bson:"expires_at,omitempty"`        // 过期时间
+	ExpiredAt        int64                     `bson:"expired_at,omitempty"`        // 已过期时间
+	CancellingAt     int64                     `bson:"cancelling_at,omitempty"`     // 开始取消时间
+	CancelledAt      int64                     `bson:"cancelled_at,omitempty"`      // 已取消时间
+	Creator          string                    `bson:"creator,omitempty"`           // 创建人
+	Updater          string                    `bson:"updater,omitempty"`           // 更新人
+	CreatedAt        int64                     `bson:"created_at,omitempty"`        // 创建时间
+	UpdatedAt        int64                     `bson:"updated_at,omitempty"`        // 更新时间
+}
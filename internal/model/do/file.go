@@ -0,0 +1,25 @@
+package do
+
+import (
+	"github.com/gogf/gf/v2/util/gmeta"
+)
+
+const (
+	FILE_COLLECTION = "file"
+)
+
+type File struct {
+	gmeta.Meta `collection:"file" bson:"-"`
+	UserId     int    `bson:"user_id,omitempty"`    // 用户ID
+	AppId      int    `bson:"app_id,omitempty"`     // 应用ID
+	Filename   string `bson:"filename,omitempty"`   // 文件名
+	Purpose    string `bson:"purpose,omitempty"`    // 用途[batch, batch_output]
+	Bytes      int64  `bson:"bytes,omitempty"`      // 文件大小
+	Storage    string `bson:"storage,omitempty"`    // 存储方式[local:本地磁盘, gridfs:GridFS]
+	Path       string `bson:"path,omitempty"`       // 存储路径, GridFS时为文件ID
+	Status     int    `bson:"status,omitempty"`     // 状态[1:正常, -1:删除]
+	Creator    string `bson:"creator,omitempty"`    // 创建人
+	Updater    string `bson:"updater,omitempty"`    // 更新人
+	CreatedAt  int64  `bson:"created_at,omitempty"` // 创建时间
+	UpdatedAt  int64  `bson:"updated_at,omitempty"` // 更新时间
+}
@@ -19,7 +19,7 @@ type Midjourney struct {
 	ModelId              string                   `bson:"model_id,omitempty"`                // 模型ID
 	Name                 string                   `bson:"name,omitempty"`                    // 模型名称
 	Model                string                   `bson:"model,omitempty"`                   // 模型
-	Type                 int                      `bson:"type,omitempty"`                    // 模型类型[1:文生文, 2:文生图, 3:图生文, 4:图生图, 5:文生语音, 6:语音生文, 100:多模态, 101:多模态实时]
+	Type                 int                      `bson:"type,omitempty"`                    // 模型类型[1:文生文, 2:文生图, 3:图生文, 4:图生图, 5:文生语音, 6:语音生文, 7:重排序, 100:多模态, 101:多模态实时]
 	Key                  string                   `bson:"key,omitempty"`                     // 密钥
 	IsEnablePresetConfig bool                     `bson:"is_enable_preset_config,omitempty"` // 是否启用预设配置
 	PresetConfig         common.PresetConfig      `bson:"preset_config,omitempty"`           // 预设配置
@@ -44,6 +44,9 @@ type Midjourney struct {
 	Response             interface{}              `bson:"response,omitempty"`                // 响应结果
 	MidjourneyQuotas     []common.MidjourneyQuota `bson:"midjourney_quotas,omitempty"`       // Midjourney额度
 	TotalTokens          int                      `bson:"total_tokens,omitempty"`            // 总令牌数
+	RefundQuota          int                      `bson:"refund_quota,omitempty"`            // 退还额度
+	RefundReason         string                   `bson:"refund_reason,omitempty"`           // 退还原因
+	RefundAt             int64                    `bson:"refund_at,omitempty"`               // 退还时间
 	ConnTime             int64                    `bson:"conn_time,omitempty"`               // 连接时间
 	Duration             int64                    `bson:"duration,omitempty"`                // 持续时间
 	TotalTime            int64                    `bson:"total_time,omitempty"`              // 总时间
@@ -0,0 +1,29 @@
+package do
+
+import (
+	"github.com/gogf/gf/v2/util/gmeta"
+	"github.com/iimeta/fastapi/internal/model/common"
+)
+
+const (
+	RESPONSE_COLLECTION = "response"
+)
+
+type Response struct {
+	gmeta.Meta         `collection:"response" bson:"-"`
+	ResponseId         string                `bson:"response_id,omitempty"`          // 响应ID
+	UserId             int                   `bson:"user_id,omitempty"`              // 用户ID
+	AppId              int                   `bson:"app_id,omitempty"`               // 应用ID
+	Model              string                `bson:"model,omitempty"`                // 模型
+	PreviousResponseId string                `bson:"previous_response_id,omitempty"` // 上一轮响应ID
+	Instructions       string                `bson:"instructions,omitempty"`         // 系统指令
+	Input              []common.ResponseItem `bson:"input,omitempty"`                // 输入项
+	Output             []common.ResponseItem `bson:"output,omitempty"`               // 输出项
+	Usage              common.ResponseUsage  `bson:"usage,omitempty"`                // 用量
+	Status             string                `bson:"status,omitempty"`               // 状态[completed, incomplete, failed]
+	Metadata           map[string]string     `bson:"metadata,omitempty"`             // 元数据
+	Creator            string                `bson:"creator,omitempty"`              // 创建人
+	Updater            string                `bson:"updater,omitempty"`              // 更新人
+	CreatedAt          int64                 `bson:"created_at,omitempty"`           // 创建时间
+	UpdatedAt          int64                 `bson:"updated_at,omitempty"`           // 更新时间
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	sdkm "github.com/iimeta/fastapi-sdk/model"
+)
+
+type EmbeddingBase64 struct {
+	Object    string `json:"object"`
+	Embedding string `json:"embedding"`
+	Index     int    `json:"index"`
+}
+
+type EmbeddingBase64Res struct {
+	Object string            `json:"object"`
+	Data   []EmbeddingBase64 `json:"data"`
+	Model  string            `json:"model"`
+	Usage  *sdkm.Usage       `json:"usage"`
+}
@@ -1,23 +1,35 @@
 package entity
 
+import "github.com/iimeta/fastapi/internal/model/common"
+
 type App struct {
-	Id             string   `bson:"_id,omitempty"`              // ID
-	AppId          int      `bson:"app_id,omitempty"`           // 应用ID
-	Name           string   `bson:"name,omitempty"`             // 应用名称
-	Models         []string `bson:"models,omitempty"`           // 模型权限
-	IsLimitQuota   bool     `bson:"is_limit_quota,omitempty"`   // 是否限制额度
-	Quota          int      `bson:"quota,omitempty"`            // 剩余额度
-	UsedQuota      int      `bson:"used_quota,omitempty"`       // 已用额度
-	QuotaExpiresAt int64    `bson:"quota_expires_at,omitempty"` // 额度过期时间
-	RPM            int      `bson:"rpm,omitempty"`              // 每分钟请求数
-	RPD            int      `bson:"rpd,omitempty"`              // 每天的请求数
-	IpWhitelist    []string `bson:"ip_whitelist,omitempty"`     // IP白名单
-	IpBlacklist    []string `bson:"ip_blacklist,omitempty"`     // IP黑名单
-	Remark         string   `bson:"remark,omitempty"`           // 备注
-	Status         int      `bson:"status,omitempty"`           // 状态[1:正常, 2:禁用, -1:删除]
-	UserId         int      `bson:"user_id,omitempty"`          // 用户ID
-	Creator        string   `bson:"creator,omitempty"`          // 创建人
-	Updater        string   `bson:"updater,omitempty"`          // 更新人
-	CreatedAt      int64    `bson:"created_at,omitempty"`       // 创建时间
-	UpdatedAt      int64    `bson:"updated_at,omitempty"`       // 更新时间
+	Id                    string                     `bson:"_id,omitempty"`                      // ID
+	AppId                 int                        `bson:"app_id,omitempty"`                   // 应用ID
+	Name                  string                     `bson:"name,omitempty"`                     // 应用名称
+	Models                []string                   `bson:"models,omitempty"`                   // 模型权限
+	IsLimitQuota          bool                       `bson:"is_limit_quota,omitempty"`           // 是否限制额度
+	Quota                 int                        `bson:"quota,omitempty"`                    // 剩余额度
+	UsedQuota             int                        `bson:"used_quota,omitempty"`               // 已用额度
+	QuotaExpiresAt        int64                      `bson:"quota_expires_at,omitempty"`         // 额度过期时间
+	RPM                   int                        `bson:"rpm,omitempty"`                      // 每分钟请求数
+	RPD                   int                        `bson:"rpd,omitempty"`                      // 每天的请求数
+	IpWhitelist           []string                   `bson:"ip_whitelist,omitempty"`             // IP白名单
+	IpBlacklist           []string                   `bson:"ip_blacklist,omitempty"`             // IP黑名单
+	NotifyHook            string                     `bson:"notify_hook,omitempty"`              // 任务回调地址
+	Retention             int                        `bson:"retention,omitempty"`                // 存储保留天数
+	IsEnableCache         bool                       `bson:"is_enable_cache,omitempty"`          // 是否启用响应缓存
+	CacheConfig           common.CacheConfig         `bson:"cache_config,omitempty"`             // 响应缓存配置
+	IsEnableSemanticCache bool                       `bson:"is_enable_semantic_cache,omitempty"` // 是否启用语义缓存
+	SemanticCacheConfig   common.SemanticCacheConfig `bson:"semantic_cache_config,omitempty"`    // 语义缓存配置
+	IsEnableContext       bool                       `bson:"is_enable_context,omitempty"`        // 是否启用上下文管理
+	ContextConfig         common.ContextConfig       `bson:"context_config,omitempty"`           // 上下文管理配置
+	IsEnableCompaction    bool                       `bson:"is_enable_compaction,omitempty"`     // 是否启用对话压缩
+	CompactionConfig      common.CompactionConfig    `bson:"compaction_config,omitempty"`        // 对话压缩配置
+	Remark                string                     `bson:"remark,omitempty"`                   // 备注
+	Status                int                        `bson:"status,omitempty"`                   // 状态[1:正常, 2:禁用, -1:删除]
+	UserId                int                        `bson:"user_id,omitempty"`                  // 用户ID
+	Creator               string                     `bson:"creator,omitempty"`                  // 创建人
+	Updater               string                     `bson:"updater,omitempty"`                  // 更新人
+	CreatedAt             int64                      `bson:"created_at,omitempty"`               // 创建时间
+	UpdatedAt             int64                      `bson:"updated_at,omitempty"`               // 更新时间
 }
@@ -11,10 +11,11 @@ type Chat struct {
 	ModelId              string                 `bson:"model_id,omitempty"`                // 模型ID
 	Name                 string                 `bson:"name,omitempty"`                    // 模型名称
 	Model                string                 `bson:"model,omitempty"`                   // 模型
-	Type                 int                    `bson:"type,omitempty"`                    // 模型类型[1:文生文, 2:文生图, 3:图生文, 4:图生图, 5:文生语音, 6:语音生文, 100:多模态, 101:多模态实时]
+	Type                 int                    `bson:"type,omitempty"`                    // 模型类型[1:文生文, 2:文生图, 3:图生文, 4:图生图, 5:文生语音, 6:语音生文, 7:重排序, 100:多模态, 101:多模态实时]
 	Key                  string                 `bson:"key,omitempty"`                     // 密钥
 	IsEnablePresetConfig bool                   `bson:"is_enable_preset_config,omitempty"` // 是否启用预设配置
 	PresetConfig         common.PresetConfig    `bson:"preset_config,omitempty"`           // 预设配置
+	Params               map[string]interface{} `bson:"params,omitempty"`                  // 实际生效的请求参数(不含消息)
 	IsEnableModelAgent   bool                   `bson:"is_enable_model_agent,omitempty"`   // 是否启用模型代理
 	ModelAgentId         string                 `bson:"model_agent_id,omitempty"`          // 模型代理ID
 	ModelAgent           *ModelAgent            `bson:"model_agent,omitempty"`             // 模型代理信息
@@ -36,6 +37,16 @@ type Chat struct {
 	PromptTokens         int                    `bson:"prompt_tokens,omitempty"`           // 提示令牌数(提问令牌数)
 	CompletionTokens     int                    `bson:"completion_tokens,omitempty"`       // 补全令牌数(回答令牌数)
 	TotalTokens          int                    `bson:"total_tokens,omitempty"`            // 总令牌数
+	Turns                int                    `bson:"turns,omitempty"`                   // 实时会话轮数
+	RealtimeUsages       []common.RealtimeUsage `bson:"realtime_usages,omitempty"`         // 实时会话每轮用量
+	CacheHits            int                    `bson:"cache_hits,omitempty"`              // 缓存命中数
+	CacheMisses          int                    `bson:"cache_misses,omitempty"`            // 缓存未命中数
+	IsCacheHit           bool                   `bson:"is_cache_hit,omitempty"`            // 是否命中响应缓存
+	CacheType            int                    `bson:"cache_type,omitempty"`              // 缓存类型[1:精确匹配, 2:语义匹配]
+	Similarity           float64                `bson:"similarity,omitempty"`              // 语义缓存相似度
+	SavedQuota           int                    `bson:"saved_quota,omitempty"`             // 缓存节省额度
+	SchemaAttempt        int                    `bson:"schema_attempt,omitempty"`          // 结构化输出校验第几次尝试
+	SchemaError          string                 `bson:"schema_error,omitempty"`            // 结构化输出校验错误
 	ConnTime             int64                  `bson:"conn_time,omitempty"`               // 连接时间
 	Duration             int64                  `bson:"duration,omitempty"`                // 持续时间
 	TotalTime            int64                  `bson:"total_time,omitempty"`              // 总时间
@@ -0,0 +1,33 @@
+package entity
+
+type MidjourneyTask struct {
+	Id          string                 `bson:"_id,omitempty"`          // ID
+	TaskId      string                 `bson:"task_id,omitempty"`      // 任务ID
+	UserId      int                    `bson:"user_id,omitempty"`      // 用户ID
+	AppId       int                    `bson:"app_id,omitempty"`       // 应用ID
+	Model       string                 `bson:"model,omitempty"`        // 模型
+	Key         string                 `bson:"key,omitempty"`          // 密钥
+	BaseUrl     string                 `bson:"base_url,omitempty"`     // 接口地址
+	Action      string                 `bson:"action,omitempty"`       // 动作[IMAGINE, UPSCALE, VARIATION, ZOOM, PAN, DESCRIBE, BLEND, SHORTEN, SWAP_FACE]
+	Prompt      string                 `bson:"prompt,omitempty"`       // 提示(提问)
+	PromptEn    string                 `bson:"prompt_en,omitempty"`    // 英文提示(提问)
+	Description string                 `bson:"description,omitempty"`  // 描述
+	State       string                 `bson:"state,omitempty"`        // 自定义参数
+	Status      string                 `bson:"status,omitempty"`       // 任务状态[NOT_START, SUBMITTED, MODAL, IN_PROGRESS, FAILURE, SUCCESS, CANCEL]
+	Progress    string                 `bson:"progress,omitempty"`     // 进度
+	ImageUrl    string                 `bson:"image_url,omitempty"`    // 图像地址
+	ObjectId    string                 `bson:"object_id,omitempty"`    // 转存对象ID
+	FailReason  string                 `bson:"fail_reason,omitempty"`  // 失败原因
+	NotifyHook  string                 `bson:"notify_hook,omitempty"`  // 回调地址
+	SubmitTime  int64                  `bson:"submit_time,omitempty"`  // 提交时间
+	StartTime   int64                  `bson:"start_time,omitempty"`   // 开始时间
+	FinishTime  int64                  `bson:"finish_time,omitempty"`  // 结束时间
+	Properties  map[string]interface{} `bson:"properties,omitempty"`   // 扩展属性
+	Buttons     interface{}            `bson:"buttons,omitempty"`      // 按钮
+	TotalTokens int                    `bson:"total_tokens,omitempty"` // 总令牌数
+	RefundQuota int                    `bson:"refund_quota,omitempty"` // 退还额度
+	Creator     string                 `bson:"creator,omitempty"`      // 创建人
+	Updater     string                 `bson:"updater,omitempty"`      // 更新人
+	CreatedAt   int64                  `bson:"created_at,omitempty"`   // 创建时间
+	UpdatedAt   int64                  `bson:"updated_at,omitempty"`   // 更新时间
+}
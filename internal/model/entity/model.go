@@ -7,11 +7,13 @@ type Model struct {
 	Corp                 string                   `bson:"corp,omitempty"`                    // 公司
 	Name                 string                   `bson:"name,omitempty"`                    // 模型名称
 	Model                string                   `bson:"model,omitempty"`                   // 模型
-	Type                 int                      `bson:"type,omitempty"`                    // 模型类型[1:文生文, 2:文生图, 3:图生文, 4:图生图, 5:文生语音, 6:语音生文, 100:多模态, 101:多模态实时]
+	Type                 int                      `bson:"type,omitempty"`                    // 模型类型[1:文生文, 2:文生图, 3:图生文, 4:图生图, 5:文生语音, 6:语音生文, 7:重排序, 100:多模态, 101:多模态实时]
 	BaseUrl              string                   `bson:"base_url,omitempty"`                // 模型地址
 	Path                 string                   `bson:"path,omitempty"`                    // 模型路径
 	IsEnablePresetConfig bool                     `bson:"is_enable_preset_config,omitempty"` // 是否启用预设配置
 	PresetConfig         common.PresetConfig      `bson:"preset_config,omitempty"`           // 预设配置
+	ParamRules           []common.ParamRule       `bson:"param_rules,omitempty"`             // 参数规则
+	ContextLength        int                      `bson:"context_length,omitempty"`          // 上下文长度, 0表示不限制
 	TextQuota            common.TextQuota         `bson:"text_quota,omitempty"`              // 文本额度
 	ImageQuotas          []common.ImageQuota      `bson:"image_quotas,omitempty"`            // 图像额度
 	AudioQuota           common.AudioQuota        `bson:"audio_quota,omitempty"`             // 音频额度
@@ -26,6 +28,14 @@ type Model struct {
 	ForwardConfig        *common.ForwardConfig    `bson:"forward_config,omitempty"`          // 模型转发配置
 	IsEnableFallback     bool                     `bson:"is_enable_fallback,omitempty"`      // 是否启用后备模型
 	FallbackConfig       *common.FallbackConfig   `bson:"fallback_config,omitempty"`         // 后备模型配置
+	BatchRatio           float64                  `bson:"batch_ratio,omitempty"`             // 批处理折扣倍率, 0表示使用全局配置
+	EmbeddingConfig      common.EmbeddingConfig   `bson:"embedding_config,omitempty"`        // 向量配置
+	IsEnableCache        bool                     `bson:"is_enable_cache,omitempty"`         // 是否启用响应缓存
+	CacheConfig          common.CacheConfig       `bson:"cache_config,omitempty"`            // 响应缓存配置
+	IsEnableJsonSchema   bool                     `bson:"is_enable_json_schema,omitempty"`   // 是否启用结构化输出校验
+	JsonSchemaConfig     common.JsonSchemaConfig  `bson:"json_schema_config,omitempty"`      // 结构化输出校验配置
+	IsEnableEmulate      bool                     `bson:"is_enable_emulate,omitempty"`       // 是否启用能力模拟
+	EmulateConfig        common.EmulateConfig     `bson:"emulate_config,omitempty"`          // 能力模拟配置
 	Remark               string                   `bson:"remark,omitempty"`                  // 备注
 	Status               int                      `bson:"status,omitempty"`                  // 状态[1:正常, 2:禁用, -1:删除]
 	Creator              string                   `bson:"creator,omitempty"`                 // 创建人
@@ -0,0 +1,18 @@
+package entity
+
+type StorageObject struct {
+	Id          string `bson:"_id,omitempty"`          // ID
+	UserId      int    `bson:"user_id,omitempty"`      // 用户ID
+	AppId       int    `bson:"app_id,omitempty"`       // 应用ID
+	Storage     string `bson:"storage,omitempty"`      // 存储方式[local:本地磁盘, s3:S3兼容存储]
+	Path        string `bson:"path,omitempty"`         // 存储路径
+	SourceUrl   string `bson:"source_url,omitempty"`   // 源地址
+	ContentType string `bson:"content_type,omitempty"` // 内容类型
+	Bytes       int64  `bson:"bytes,omitempty"`        // 文件大小
+	ExpiresAt   int64  `bson:"expires_at,omitempty"`   // 过期时间, 0表示永久保留
+	Status      int    `bson:"status,omitempty"`       // 状态[1:正常, -1:删除]
+	Creator     string `bson:"creator,omitempty"`      // 创建人
+	Updater     string `bson:"updater,omitempty"`      // 更新人
+	CreatedAt   int64  `bson:"created_at,omitempty"`   // 创建时间
+	UpdatedAt   int64  `bson:"updated_at,omitempty"`   // 更新时间
+}
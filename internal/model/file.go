@@ -0,0 +1,26 @@
+package model
+
+type File struct {
+	Id        string `json:"id"`         // ID
+	Object    string `json:"object"`     // 对象类型
+	Bytes     int64  `json:"bytes"`      // 文件大小
+	CreatedAt int64  `json:"created_at"` // 创建时间, 单位秒
+	Filename  string `json:"filename"`   // 文件名
+	Purpose   string `json:"purpose"`    // 用途[batch, batch_output]
+	UserId    int    `json:"-"`          // 用户ID
+	AppId     int    `json:"-"`          // 应用ID
+	Storage   string `json:"-"`          // 存储方式[local:本地磁盘, gridfs:GridFS]
+	Path      string `json:"-"`          // 存储路径, GridFS时为文件ID
+	Creator   string `json:"-"`          // 创建人
+}
+
+type FileListRes struct {
+	Object string  `json:"object"`
+	Data   []*File `json:"data"`
+}
+
+type FileDeleteRes struct {
+	Id      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
@@ -21,3 +21,21 @@ type MidjourneyResponse struct {
 	InternalTime int64      `json:"-"`
 	EnterTime    int64      `json:"-"`
 }
+
+type MidjourneyTask struct {
+	Id          string                 `json:"id"`                   // 任务ID
+	Action      string                 `json:"action"`               // 动作
+	Prompt      string                 `json:"prompt"`               // 提示(提问)
+	PromptEn    string                 `json:"promptEn"`             // 英文提示(提问)
+	Description string                 `json:"description"`          // 描述
+	State       string                 `json:"state"`                // 自定义参数
+	Status      string                 `json:"status"`               // 任务状态
+	Progress    string                 `json:"progress"`             // 进度
+	ImageUrl    string                 `json:"imageUrl"`             // 图像地址
+	FailReason  string                 `json:"failReason"`           // 失败原因
+	SubmitTime  int64                  `json:"submitTime"`           // 提交时间
+	StartTime   int64                  `json:"startTime"`            // 开始时间
+	FinishTime  int64                  `json:"finishTime"`           // 结束时间
+	Properties  map[string]interface{} `json:"properties,omitempty"` // 扩展属性
+	Buttons     interface{}            `json:"buttons,omitempty"`    // 按钮
+}
@@ -7,11 +7,13 @@ type Model struct {
 	Corp                 string                   `json:"corp,omitempty"`                    // 公司
 	Name                 string                   `json:"name,omitempty"`                    // 模型名称
 	Model                string                   `json:"model,omitempty"`                   // 模型
-	Type                 int                      `json:"type,omitempty"`                    // 模型类型[1:文生文, 2:文生图, 3:图生文, 4:图生图, 5:文生语音, 6:语音生文, 100:多模态, 101:多模态实时]
+	Type                 int                      `json:"type,omitempty"`                    // 模型类型[1:文生文, 2:文生图, 3:图生文, 4:图生图, 5:文生语音, 6:语音生文, 7:重排序, 100:多模态, 101:多模态实时]
 	BaseUrl              string                   `json:"base_url,omitempty"`                // 模型地址
 	Path                 string                   `json:"path,omitempty"`                    // 模型路径
 	IsEnablePresetConfig bool                     `json:"is_enable_preset_config,omitempty"` // 是否启用预设配置
 	PresetConfig         common.PresetConfig      `json:"preset_config,omitempty"`           // 预设配置
+	ParamRules           []common.ParamRule       `json:"param_rules,omitempty"`             // 参数规则
+	ContextLength        int                      `json:"context_length,omitempty"`          // 上下文长度, 0表示不限制
 	TextQuota            common.TextQuota         `json:"text_quota,omitempty"`              // 文本额度
 	ImageQuotas          []common.ImageQuota      `json:"image_quotas,omitempty"`            // 图像额度
 	AudioQuota           common.AudioQuota        `json:"audio_quota,omitempty"`             // 音频额度
@@ -28,6 +30,14 @@ type Model struct {
 	ForwardConfig        *common.ForwardConfig    `json:"forward_config,omitempty"`          // 模型转发配置
 	IsEnableFallback     bool                     `json:"is_enable_fallback,omitempty"`      // 是否启用后备模型
 	FallbackConfig       *common.FallbackConfig   `json:"fallback_config,omitempty"`         // 后备模型配置
+	BatchRatio           float64                  `json:"batch_ratio,omitempty"`             // 批处理折扣倍率, 0表示使用全局配置
+	EmbeddingConfig      common.EmbeddingConfig   `json:"embedding_config,omitempty"`        // 向量配置
+	IsEnableCache        bool                     `json:"is_enable_cache,omitempty"`         // 是否启用响应缓存
+	CacheConfig          common.CacheConfig       `json:"cache_config,omitempty"`            // 响应缓存配置
+	IsEnableJsonSchema   bool                     `json:"is_enable_json_schema,omitempty"`   // 是否启用结构化输出校验
+	JsonSchemaConfig     common.JsonSchemaConfig  `json:"json_schema_config,omitempty"`      // 结构化输出校验配置
+	IsEnableEmulate      bool                     `json:"is_enable_emulate,omitempty"`       // 是否启用能力模拟
+	EmulateConfig        common.EmulateConfig     `json:"emulate_config,omitempty"`          // 能力模拟配置
 	Remark               string                   `json:"remark,omitempty"`                  // 备注
 	Status               int                      `json:"status,omitempty"`                  // 状态[1:正常, 2:禁用, -1:删除]
 	Creator              string                   `json:"creator,omitempty"`                 // 创建人
@@ -2,6 +2,7 @@ package model
 
 import (
 	sdkm "github.com/iimeta/fastapi-sdk/model"
+	"github.com/iimeta/fastapi/internal/model/common"
 )
 
 type RealtimeRequest struct {
@@ -9,12 +10,26 @@ type RealtimeRequest struct {
 	Messages []sdkm.ChatCompletionMessage `json:"messages"`
 }
 
+type RealtimeRes struct {
+	Prompt       string                 `json:"prompt"`
+	Completion   string                 `json:"completion"`
+	Usage        sdkm.Usage             `json:"usage"`
+	Usages       []common.RealtimeUsage `json:"usages"`
+	Error        error                  `json:"err"`
+	ConnTime     int64                  `json:"-"`
+	Duration     int64                  `json:"-"`
+	TotalTime    int64                  `json:"-"`
+	InternalTime int64                  `json:"-"`
+	EnterTime    int64                  `json:"-"`
+}
+
 type RealtimeResponse struct {
 	Type         string `json:"type"`
 	EventId      string `json:"event_id"`
 	ItemId       string `json:"item_id"`
 	ContentIndex int    `json:"content_index"`
 	Transcript   string `json:"transcript"`
+	Text         string `json:"text"`
 	ResponseId   string `json:"response_id"`
 	OutputIndex  int    `json:"output_index"`
 	Delta        string `json:"delta"`
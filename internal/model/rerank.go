@@ -0,0 +1,31 @@
+package model
+
+type RerankReq struct {
+	Model           string        `json:"model"`
+	Query           string        `json:"query"`
+	Documents       []interface{} `json:"documents"` // 字符串或{"text": ""}
+	TopN            int           `json:"top_n,omitempty"`
+	ReturnDocuments *bool         `json:"return_documents,omitempty"`
+}
+
+type RerankRes struct {
+	Id        string         `json:"id,omitempty"`
+	Model     string         `json:"model"`
+	Results   []RerankResult `json:"results"`
+	Usage     *RerankUsage   `json:"usage,omitempty"`
+	TotalTime int64          `json:"-"`
+}
+
+type RerankResult struct {
+	Index          int             `json:"index"`
+	RelevanceScore float64         `json:"relevance_score"`
+	Document       *RerankDocument `json:"document,omitempty"`
+}
+
+type RerankDocument struct {
+	Text string `json:"text"`
+}
+
+type RerankUsage struct {
+	TotalTokens int `json:"total_tokens"`
+}
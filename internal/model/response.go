@@ -0,0 +1,87 @@
+package model
+
+import (
+	"github.com/iimeta/fastapi/internal/model/common"
+)
+
+type ResponsesReq struct {
+	Model              string            `json:"model"`
+	Input              interface{}       `json:"input"`
+	Instructions       string            `json:"instructions,omitempty"`
+	PreviousResponseId string            `json:"previous_response_id,omitempty"`
+	Store              *bool             `json:"store,omitempty"`
+	Stream             bool              `json:"stream,omitempty"`
+	Temperature        *float32          `json:"temperature,omitempty"`
+	TopP               *float32          `json:"top_p,omitempty"`
+	MaxOutputTokens    int               `json:"max_output_tokens,omitempty"`
+	Tools              []ResponseTool    `json:"tools,omitempty"`
+	ToolChoice         interface{}       `json:"tool_choice,omitempty"`
+	ParallelToolCalls  *bool             `json:"parallel_tool_calls,omitempty"`
+	Text               *ResponseText     `json:"text,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	User               string            `json:"user,omitempty"`
+}
+
+type ResponseTool struct {
+	Type        string      `json:"type"`
+	Name        string      `json:"name,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Parameters  interface{} `json:"parameters,omitempty"`
+	Strict      bool        `json:"strict,omitempty"`
+}
+
+type ResponseText struct {
+	Format *ResponseTextFormat `json:"format,omitempty"`
+}
+
+type ResponseTextFormat struct {
+	Type        string      `json:"type"`
+	Name        string      `json:"name,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Schema      interface{} `json:"schema,omitempty"`
+	Strict      bool        `json:"strict,omitempty"`
+}
+
+type Response struct {
+	Id                 string                `json:"id"`                             // ID
+	Object             string                `json:"object"`                         // 对象类型
+	CreatedAt          int64                 `json:"created_at"`                     // 创建时间, 单位秒
+	Status             string                `json:"status"`                         // 状态[in_progress, completed, incomplete, failed]
+	Error              interface{}           `json:"error"`                          // 错误信息
+	IncompleteDetails  interface{}           `json:"incomplete_details"`             // 未完成原因
+	Instructions       string                `json:"instructions,omitempty"`         // 系统指令
+	MaxOutputTokens    int                   `json:"max_output_tokens,omitempty"`    // 最大输出令牌数
+	Model              string                `json:"model"`                          // 模型
+	Output             []common.ResponseItem `json:"output"`                         // 输出项
+	ParallelToolCalls  bool                  `json:"parallel_tool_calls"`            // 是否并行调用工具
+	PreviousResponseId string                `json:"previous_response_id,omitempty"` // 上一轮响应ID
+	Store              bool                  `json:"store"`                          // 是否存储
+	Temperature        *float32              `json:"temperature,omitempty"`          // 温度
+	TopP               *float32              `json:"top_p,omitempty"`                // 核采样
+	ToolChoice         interface{}           `json:"tool_choice,omitempty"`          // 工具选择
+	Tools              []ResponseTool        `json:"tools"`                          // 工具
+	Usage              *common.ResponseUsage `json:"usage,omitempty"`                // 用量
+	Metadata           map[string]string     `json:"metadata,omitempty"`             // 元数据
+	User               string                `json:"user,omitempty"`                 // 用户标识
+}
+
+type ResponseDeleteRes struct {
+	Id      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
+
+// 流式语义事件
+type ResponseStreamEvent struct {
+	Type           string                  `json:"type"`
+	SequenceNumber int                     `json:"sequence_number"`
+	Response       *Response               `json:"response,omitempty"`
+	OutputIndex    *int                    `json:"output_index,omitempty"`
+	ItemId         string                  `json:"item_id,omitempty"`
+	ContentIndex   *int                    `json:"content_index,omitempty"`
+	Item           *common.ResponseItem    `json:"item,omitempty"`
+	Part           *common.ResponseContent `json:"part,omitempty"`
+	Delta          *string                 `json:"delta,omitempty"`
+	Text           *string                 `json:"text,omitempty"`
+	Arguments      *string                 `json:"arguments,omitempty"`
+}
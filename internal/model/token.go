@@ -0,0 +1,26 @@
+package model
+
+type TokenizeRes struct {
+	Model           string  `json:"model"`            // 模型
+	PromptTokens    int     `json:"prompt_tokens"`    // 提示令牌数
+	TextTokens      int     `json:"text_tokens"`      // 文本令牌数
+	ImageTokens     int     `json:"image_tokens"`     // 图像令牌数
+	Quota           int     `json:"quota"`            // 预估提示消耗额度
+	CompletionRatio float64 `json:"completion_ratio"` // 补全倍率, 用于预估补全消耗额度
+}
+
+type CountTokensReq struct {
+	Model    string             `json:"model"`
+	System   interface{}        `json:"system,omitempty"` // 字符串或文本块数组
+	Messages []AnthropicMessage `json:"messages"`
+	Tools    []interface{}      `json:"tools,omitempty"`
+}
+
+type AnthropicMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"` // 字符串或内容块数组
+}
+
+type CountTokensRes struct {
+	InputTokens int `json:"input_tokens"`
+}
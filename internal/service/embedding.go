@@ -17,6 +17,8 @@ type (
 	IEmbedding interface {
 		// Embeddings
 		Embeddings(ctx context.Context, params sdkm.EmbeddingRequest, fallbackModel *model.Model, retry ...int) (response sdkm.EmbeddingResponse, err error)
+		// 向量转换为base64编码输出
+		ConvBase64(ctx context.Context, response sdkm.EmbeddingResponse) model.EmbeddingBase64Res
 		// 保存日志
 		SaveLog(ctx context.Context, reqModel *model.Model, realModel *model.Model, fallbackModel *model.Model, key *model.Key, completionsReq *sdkm.EmbeddingRequest, completionsRes *model.CompletionsRes, retryInfo *mcommon.Retry, retry ...int)
 	}
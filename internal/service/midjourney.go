@@ -20,6 +20,12 @@ type (
 		Submit(ctx context.Context, request *ghttp.Request, fallbackModel *model.Model, retry ...int) (response sdkm.MidjourneyResponse, err error)
 		// 任务查询
 		Task(ctx context.Context, request *ghttp.Request, fallbackModel *model.Model, retry ...int) (response sdkm.MidjourneyResponse, err error)
+		// 启动未结束任务同步, 回调丢失或客户端不再查询时, 主动查询任务状态, 失败的任务退还额度
+		Start(ctx context.Context)
+		// 任务回调
+		Notify(ctx context.Context, request *ghttp.Request) error
+		// 任务详情
+		Fetch(ctx context.Context, taskId string) (*model.MidjourneyTask, error)
 		// 保存日志
 		SaveLog(ctx context.Context, reqModel *model.Model, realModel *model.Model, fallbackModel *model.Model, key *model.Key, response model.MidjourneyResponse, retryInfo *mcommon.Retry, retry ...int)
 	}
@@ -10,12 +10,15 @@ import (
 
 	"github.com/gogf/gf/v2/net/ghttp"
 	"github.com/iimeta/fastapi/internal/model"
+	mcommon "github.com/iimeta/fastapi/internal/model/common"
 )
 
 type (
 	IRealtime interface {
 		// Realtime
 		Realtime(ctx context.Context, r *ghttp.Request, params model.RealtimeRequest, fallbackModel *model.Model, retry ...int) (err error)
+		// 保存日志
+		SaveLog(ctx context.Context, reqModel *model.Model, realModel *model.Model, fallbackModel *model.Model, key *model.Key, realtimeReq *model.RealtimeRequest, realtimeRes *model.RealtimeRes, retryInfo *mcommon.Retry, retry ...int)
 	}
 )
 
@@ -1,6 +1,8 @@
 package crypto
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/hex"
 	"github.com/tjfoc/gmsm/sm3"
 )
@@ -18,3 +20,9 @@ func EncryptPassword(data string) string {
 func VerifyPassword(cipherPwd, plainPwd string) bool {
 	return cipherPwd == SM3(plainPwd)
 }
+
+func HmacSHA256(key, data string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+)
+
+type GridFS struct {
+	Database string
+}
+
+func (g *GridFS) Upload(ctx context.Context, id, filename string, data []byte) error {
+
+	bucket, err := gridfs.NewBucket(client.Database(g.Database))
+	if err != nil {
+		return err
+	}
+
+	return bucket.UploadFromStreamWithID(id, filename, bytes.NewReader(data))
+}
+
+func (g *GridFS) Download(ctx context.Context, id string) ([]byte, error) {
+
+	bucket, err := gridfs.NewBucket(client.Database(g.Database))
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bytes.Buffer{}
+	if _, err = bucket.DownloadToStream(id, &buffer); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (g *GridFS) Delete(ctx context.Context, id string) error {
+
+	bucket, err := gridfs.NewBucket(client.Database(g.Database))
+	if err != nil {
+		return err
+	}
+
+	return bucket.DeleteContext(ctx, id)
+}
@@ -0,0 +1,75 @@
+package img
+
+import (
+	"bufio"
+	"encoding/binary"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+)
+
+// OpenAI: 先缩放至2048x2048以内, 再将短边缩放至768, 每个512x512分块170令牌, 另加基础85令牌, low模式固定85令牌
+func GetOpenAITokens(width, height int, detail string) int {
+
+	if detail == "low" {
+		return 85
+	}
+
+	w, h := float64(width), float64(height)
+
+	if longest := math.Max(w, h); longest > 2048 {
+		w, h = w*2048/longest, h*2048/longest
+	}
+
+	if shortest := math.Min(w, h); shortest > 768 {
+		w, h = w*768/shortest, h*768/shortest
+	}
+
+	return 85 + 170*int(math.Ceil(w/512)*math.Ceil(h/512))
+}
+
+// Claude: 长边超过1568或超过约115万像素时等比缩放, 令牌数为 宽*高/750
+func GetClaudeTokens(width, height int) int {
+
+	w, h := float64(width), float64(height)
+
+	if longest := math.Max(w, h); longest > 1568 {
+		w, h = w*1568/longest, h*1568/longest
+	}
+
+	if pixels := w * h; pixels > 1150000 {
+		scale := math.Sqrt(1150000 / pixels)
+		w, h = w*scale, h*scale
+	}
+
+	return int(math.Ceil(w * h / 750))
+}
+
+// 获取图片尺寸, 仅解析图片头部
+func DecodeSize(reader io.Reader) (width, height int, err error) {
+
+	br := bufio.NewReader(reader)
+
+	// 标准库不支持webp, 直接解析文件头
+	if header, _ := br.Peek(30); len(header) == 30 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP" {
+		switch string(header[12:16]) {
+		case "VP8 ":
+			return int(binary.LittleEndian.Uint16(header[26:28]) & 0x3fff), int(binary.LittleEndian.Uint16(header[28:30]) & 0x3fff), nil
+		case "VP8L":
+			bits := binary.LittleEndian.Uint32(header[21:25])
+			return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+		case "VP8X":
+			return int(uint32(header[24])|uint32(header[25])<<8|uint32(header[26])<<16) + 1, int(uint32(header[27])|uint32(header[28])<<8|uint32(header[29])<<16) + 1, nil
+		}
+	}
+
+	imageConfig, _, err := image.DecodeConfig(br)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return imageConfig.Width, imageConfig.Height, nil
+}
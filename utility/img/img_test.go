@@ -0,0 +1,99 @@
+package img
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestGetOpenAITokens(t *testing.T) {
+
+	tests := []struct {
+		width, height int
+		detail        string
+		want          int
+	}{
+		{1024, 1024, "high", 765},
+		{2048, 4096, "high", 1105},
+		{512, 512, "auto", 255},
+		{4096, 4096, "low", 85},
+	}
+
+	for _, test := range tests {
+		if got := GetOpenAITokens(test.width, test.height, test.detail); got != test.want {
+			t.Errorf("GetOpenAITokens(%d, %d, %s) = %d, want %d", test.width, test.height, test.detail, got, test.want)
+		}
+	}
+}
+
+func TestGetClaudeTokens(t *testing.T) {
+
+	tests := []struct {
+		width, height int
+		want          int
+	}{
+		{1000, 1000, 1334},
+		{4000, 3000, 1534},
+		{200, 200, 54},
+	}
+
+	for _, test := range tests {
+		if got := GetClaudeTokens(test.width, test.height); got != test.want {
+			t.Errorf("GetClaudeTokens(%d, %d) = %d, want %d", test.width, test.height, got, test.want)
+		}
+	}
+}
+
+func TestDecodeImageSize(t *testing.T) {
+
+	webp := func(chunk string, fill func(header []byte)) []byte {
+		header := make([]byte, 30)
+		copy(header[0:4], "RIFF")
+		copy(header[8:12], "WEBP")
+		copy(header[12:16], chunk)
+		fill(header)
+		return header
+	}
+
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 320, 240))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		data          []byte
+		width, height int
+	}{
+		{"VP8", webp("VP8 ", func(header []byte) {
+			copy(header[23:26], []byte{0x9d, 0x01, 0x2a})
+			binary.LittleEndian.PutUint16(header[26:28], 640)
+			binary.LittleEndian.PutUint16(header[28:30], 480)
+		}), 640, 480},
+		{"VP8L", webp("VP8L", func(header []byte) {
+			header[20] = 0x2f
+			binary.LittleEndian.PutUint32(header[21:25], uint32(1024-1)|uint32(768-1)<<14)
+		}), 1024, 768},
+		{"VP8X", webp("VP8X", func(header []byte) {
+			width, height := 2000-1, 100-1
+			header[24], header[25], header[26] = byte(width), byte(width>>8), byte(width>>16)
+			header[27], header[28], header[29] = byte(height), byte(height>>8), byte(height>>16)
+		}), 2000, 100},
+		{"PNG", buf.Bytes(), 320, 240},
+	}
+
+	for _, test := range tests {
+
+		width, height, err := DecodeSize(bytes.NewReader(test.data))
+		if err != nil {
+			t.Errorf("DecodeSize %s error: %v", test.name, err)
+			continue
+		}
+
+		if width != test.width || height != test.height {
+			t.Errorf("DecodeSize %s = %dx%d, want %dx%d", test.name, width, height, test.width, test.height)
+		}
+	}
+}
@@ -118,6 +118,10 @@ func Get(ctx context.Context, key string) (*gvar.Var, error) {
 	return slave.Get(ctx, key)
 }
 
+func MGet(ctx context.Context, keys ...string) (map[string]*gvar.Var, error) {
+	return slave.MGet(ctx, keys...)
+}
+
 func GetInt(ctx context.Context, key string) (int, error) {
 	reply, err := slave.Get(ctx, key)
 	if err != nil {
@@ -245,6 +249,10 @@ func LRange(ctx context.Context, key string, start, stop int64) (gvar.Vars, erro
 	return slave.LRange(ctx, key, start, stop)
 }
 
+func ZPopMin(ctx context.Context, key string, count int64) ([]redis.Z, error) {
+	return Client.ZPopMin(ctx, key, count).Result()
+}
+
 func TTL(ctx context.Context, key string) (int64, error) {
 	return slave.TTL(ctx, key)
 }
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gfile"
+)
+
+// 本地磁盘存储
+type Local struct {
+	Path string
+}
+
+func (l *Local) Put(ctx context.Context, name string, data []byte, contentType string) error {
+	return gfile.PutBytes(gfile.Join(l.Path, name), data)
+}
+
+func (l *Local) Get(ctx context.Context, name string) ([]byte, error) {
+
+	path := gfile.Join(l.Path, name)
+	if !gfile.Exists(path) {
+		return nil, gerror.Newf("object not found: %s", name)
+	}
+
+	return gfile.GetBytes(path), nil
+}
+
+func (l *Local) Delete(ctx context.Context, name string) error {
+	return gfile.Remove(gfile.Join(l.Path, name))
+}
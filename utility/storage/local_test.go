@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+
+	var (
+		ctx   = context.Background()
+		local = &Local{Path: t.TempDir()}
+		name  = "20240101/test.png"
+		data  = []byte("fastapi")
+	)
+
+	if err := local.Put(ctx, name, data, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := local.Get(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != string(data) {
+		t.Fatalf("got %q, want %q", got, data)
+	}
+
+	if err = local.Delete(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = local.Get(ctx, name); err == nil {
+		t.Fatal("expected error after delete")
+	}
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3兼容存储, 使用AWS Signature V4签名, 兼容MinIO等
+type S3 struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+	Client    *http.Client
+}
+
+func (s *S3) Put(ctx context.Context, name string, data []byte, contentType string) error {
+
+	res, err := s.do(ctx, http.MethodPut, name, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return gerror.Newf("s3 put object: %s, statusCode: %d, response: %s", name, res.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, name string) ([]byte, error) {
+
+	res, err := s.do(ctx, http.MethodGet, name, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, gerror.Newf("s3 get object: %s, statusCode: %d, response: %s", name, res.StatusCode, string(body))
+	}
+
+	return body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, name string) error {
+
+	res, err := s.do(ctx, http.MethodDelete, name, nil, "")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(res.Body)
+		return gerror.Newf("s3 delete object: %s, statusCode: %d, response: %s", name, res.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+func (s *S3) do(ctx context.Context, method, name string, data []byte, contentType string) (*http.Response, error) {
+
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := strings.Split(strings.TrimPrefix(name, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	host := endpoint.Host
+	path := "/" + strings.Join(segments, "/")
+
+	if s.PathStyle {
+		path = "/" + s.Bucket + path
+	} else {
+		host = s.Bucket + "." + host
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s://%s%s", endpoint.Scheme, host, path), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	s.sign(req, host, path, data, time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return client.Do(req)
+}
+
+// 签名的请求头
+const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+
+func (s *S3) sign(req *http.Request, host, path string, data []byte, now time.Time) {
+
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	payloadHash := hashSHA256(data)
+
+	req.Host = host
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	canonicalRequest := getCanonicalRequest(req.Method, path, req.URL.Query(), host, payloadHash, amzDate)
+	scope, signature := s.getSignature(canonicalRequest, now)
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.AccessKey, scope, signedHeaders, signature))
+}
+
+// 规范请求, 查询参数按名称排序, 空格编码为%20
+func getCanonicalRequest(method, path string, query url.Values, host, payloadHash, amzDate string) string {
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", host, payloadHash, amzDate)
+	return strings.Join([]string{method, path, strings.ReplaceAll(query.Encode(), "+", "%20"), canonicalHeaders, signedHeaders, payloadHash}, "\n")
+}
+
+// 计算签名, 返回凭证范围和签名
+func (s *S3) getSignature(canonicalRequest string, now time.Time) (string, string) {
+
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hashSHA256([]byte(canonicalRequest))}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	return scope, hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+}
+
+func hashSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,12 @@
+package storage
+
+import (
+	"context"
+)
+
+// 对象存储
+type Storage interface {
+	Put(ctx context.Context, name string, data []byte, contentType string) error
+	Get(ctx context.Context, name string) ([]byte, error)
+	Delete(ctx context.Context, name string) error
+}
@@ -79,3 +79,55 @@ func SSEServer(ctx context.Context, data string) error {
 
 	return nil
 }
+
+// SSE心跳, 以注释行保持连接
+func SSEPing(ctx context.Context) error {
+
+	r := g.RequestFromCtx(ctx)
+	rw := r.Response.RawWriter()
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "Streaming unsupported", http.StatusInternalServerError)
+		return gerror.New("Streaming unsupported")
+	}
+
+	r.Response.Header().Set("Trace-Id", gctx.CtxId(ctx))
+	r.Response.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	r.Response.Header().Set("Cache-Control", "no-cache")
+	r.Response.Header().Set("Connection", "keep-alive")
+
+	if _, err := fmt.Fprint(rw, ": ping\n\n"); err != nil {
+		logger.Errorf(ctx, "SSEPing err: %v", err)
+		return err
+	}
+
+	flusher.Flush()
+
+	return nil
+}
+
+// 带事件类型的SSE输出
+func SSEServerEvent(ctx context.Context, event, data string) error {
+
+	r := g.RequestFromCtx(ctx)
+	rw := r.Response.RawWriter()
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "Streaming unsupported", http.StatusInternalServerError)
+		return gerror.New("Streaming unsupported")
+	}
+
+	r.Response.Header().Set("Trace-Id", gctx.CtxId(ctx))
+	r.Response.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	r.Response.Header().Set("Cache-Control", "no-cache")
+	r.Response.Header().Set("Connection", "keep-alive")
+
+	if _, err := fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		logger.Errorf(ctx, "SSEServerEvent event: %s, data: %s, err: %v", event, data, err)
+		return err
+	}
+
+	flusher.Flush()
+
+	return nil
+}